@@ -72,9 +72,13 @@ if not cached_data then
 
     cached_data = body
 
-    -- Cache the result
+    -- Cache the result, indexed by key id like the reporting API does so
+    -- POST /api/v1/admin/unkey/keys/:keyId/purge can evict it
     if ok then
         red:setex(cache_key, CACHE_TTL, cjson.encode(body))
+        if body.keyId then
+            red:setex("unkey:keyid:" .. body.keyId, CACHE_TTL, cache_key)
+        end
     end
 end
 
@@ -7,6 +7,8 @@ USE telemetry;
 -- ============================================================================
 -- Chain Health Metrics (for monitoring upstreams)
 -- ============================================================================
+-- Superseded by chain_health_minutely (04_chain_health_minutely.sql): merges
+-- of this ReplacingMergeTree drop partial rows, so its counts undercount.
 CREATE TABLE IF NOT EXISTS chain_health (
     timestamp DateTime CODEC(DoubleDelta, LZ4),
 
@@ -67,6 +69,8 @@ GROUP BY timestamp, chain_slug, upstream_host;
 -- ============================================================================
 -- Method Usage by Chain (for analytics)
 -- ============================================================================
+-- Superseded by chain_method_daily (05_chain_method_daily.sql): summing the
+-- stored uniques, averages and rates of partial rows gives wrong values.
 CREATE TABLE IF NOT EXISTS chain_method_usage (
     date Date CODEC(DoubleDelta, LZ4),
 
@@ -137,7 +141,7 @@ CREATE TABLE IF NOT EXISTS chain_org_usage (
     -- Bandwidth
     total_response_size UInt64 CODEC(T64, LZ4),
 
-    -- Cost estimation (will be calculated based on pricing)
+    -- USD list price of the usage, from chain_prices (03_chain_prices.sql)
     estimated_cost Float32 CODEC(Delta, LZ4)
 )
 ENGINE = SummingMergeTree()
@@ -160,7 +164,7 @@ SELECT
     sum(compute_units) as compute_units_used,
     sum(response_size) as total_response_size,
 
-    0 as estimated_cost  -- Will be calculated in application layer
+    0 as estimated_cost  -- Priced by the view in 03_chain_prices.sql
 FROM requests_raw
 GROUP BY date, organization_id, chain_slug, plan_slug;
 
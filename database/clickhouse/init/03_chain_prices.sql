@@ -0,0 +1,77 @@
+-- ============================================================================
+-- Usage Cost Estimates - Chain prices synced from Postgres
+-- ============================================================================
+-- chain_prices is a copy of the Postgres chain_prices table
+-- (database/postgresql/init/07_chain_prices.sql) that the reporting-api
+-- writes every billing.pricesyncinterval seconds. chain_prices_dict reads it
+-- from this server through the telemetry_local named collection
+-- (config/clickhouse/config.d/telemetry_local.xml), so ingest keeps pricing
+-- with the last synced prices while Postgres is down. The chain_org_usage
+-- materialized view prices usage with it as it is ingested.
+--
+-- Existing deployments can apply this file with clickhouse-client; it is
+-- safe to run again. Rows ingested before it keep an estimated_cost of 0.
+
+USE telemetry;
+
+-- Each sync writes every price with a new synced_at; rows of older syncs
+-- are prices deleted since and are merged away or ignored
+CREATE TABLE IF NOT EXISTS chain_prices (
+    chain_slug String CODEC(ZSTD(1)),
+    plan_slug String CODEC(ZSTD(1)),
+
+    price_per_million_compute_units Float64,
+    price_per_million_requests Float64,
+    price_per_gb Float64,
+
+    synced_at DateTime64(3) CODEC(DoubleDelta, LZ4)
+)
+ENGINE = ReplacingMergeTree(synced_at)
+ORDER BY (chain_slug, plan_slug);
+
+-- Reloaded within one to five minutes of a sync, or at once when the sync
+-- asks for it. An empty table loads as an empty dictionary: usage is then
+-- priced at 0 rather than failing requests_raw inserts.
+CREATE DICTIONARY IF NOT EXISTS chain_prices_dict (
+    chain_slug String,
+    plan_slug String,
+    price_per_million_compute_units Float64 DEFAULT 0,
+    price_per_million_requests Float64 DEFAULT 0,
+    price_per_gb Float64 DEFAULT 0
+)
+PRIMARY KEY chain_slug, plan_slug
+SOURCE(CLICKHOUSE(
+    NAME telemetry_local
+    QUERY 'SELECT chain_slug, plan_slug, price_per_million_compute_units, price_per_million_requests, price_per_gb FROM telemetry.chain_prices WHERE synced_at = (SELECT max(synced_at) FROM telemetry.chain_prices)'
+    INVALIDATE_QUERY 'SELECT max(synced_at) FROM telemetry.chain_prices'
+))
+LIFETIME(MIN 60 MAX 300)
+LAYOUT(COMPLEX_KEY_HASHED());
+
+-- Prices usage at the chain's price for the plan, else its price for every
+-- plan, else 0. Costs are USD list prices without plan allowances.
+-- MODIFY QUERY swaps the query of the view created in 02_chain_analytics.sql
+-- in place, so no insert into requests_raw goes unaggregated.
+SET allow_experimental_alter_materialized_view_structure = 1;
+
+ALTER TABLE chain_org_usage_mv MODIFY QUERY
+SELECT
+    toDate(timestamp) as date,
+    organization_id,
+    chain_slug,
+    plan_slug,
+
+    count() as request_count,
+    sum(compute_units) as compute_units_used,
+    sum(response_size) as total_response_size,
+
+    toFloat32(
+        sum(compute_units) / 1000000 * dictGetOrDefault('telemetry.chain_prices_dict', 'price_per_million_compute_units', (chain_slug, plan_slug),
+            dictGetOrDefault('telemetry.chain_prices_dict', 'price_per_million_compute_units', (chain_slug, ''), toFloat64(0)))
+        + count() / 1000000 * dictGetOrDefault('telemetry.chain_prices_dict', 'price_per_million_requests', (chain_slug, plan_slug),
+            dictGetOrDefault('telemetry.chain_prices_dict', 'price_per_million_requests', (chain_slug, ''), toFloat64(0)))
+        + sum(response_size) / 1073741824 * dictGetOrDefault('telemetry.chain_prices_dict', 'price_per_gb', (chain_slug, plan_slug),
+            dictGetOrDefault('telemetry.chain_prices_dict', 'price_per_gb', (chain_slug, ''), toFloat64(0)))
+    ) as estimated_cost
+FROM requests_raw
+GROUP BY date, organization_id, chain_slug, plan_slug;
@@ -0,0 +1,105 @@
+-- ============================================================================
+-- Chain Health - Mergeable per-minute upstream metrics
+-- ============================================================================
+-- chain_health (02_chain_analytics.sql) is a ReplacingMergeTree: every insert
+-- block writes a partial row per minute and upstream, and merges keep only
+-- one of them, so its counts shrink as parts merge. chain_health_minutely
+-- keeps sums and quantile states that merge into exact per-minute totals.
+-- The reporting-api chain health, status page and uptime read this table;
+-- chain_health is no longer read and may be dropped.
+--
+-- Existing deployments can apply this file with clickhouse-client; it is
+-- safe to run again. The first run backfills every request before the view
+-- was created from requests_raw (14 days), including the part of the
+-- creation minute before it; the view counts the rest of that minute.
+-- schema_backfills records that it ran.
+
+USE telemetry;
+
+CREATE TABLE IF NOT EXISTS chain_health_minutely (
+    timestamp DateTime CODEC(DoubleDelta, LZ4),
+
+    chain_slug String CODEC(ZSTD(1)),
+    upstream_host String CODEC(ZSTD(1)),
+
+    request_count SimpleAggregateFunction(sum, UInt64),
+    error_count SimpleAggregateFunction(sum, UInt64),
+    -- Responses below 500; an upstream with any is healthy that minute
+    healthy_count SimpleAggregateFunction(sum, UInt64),
+    server_error_count SimpleAggregateFunction(sum, UInt64),
+
+    latency_sum_ms SimpleAggregateFunction(sum, UInt64),
+    latency_quantiles AggregateFunction(quantiles(0.95, 0.99), UInt32)
+)
+ENGINE = AggregatingMergeTree()
+PARTITION BY toYYYYMMDD(timestamp)
+ORDER BY (timestamp, chain_slug, upstream_host)
+TTL timestamp + INTERVAL 30 DAY
+SETTINGS index_granularity = 8192;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS chain_health_minutely_mv
+TO chain_health_minutely
+AS
+SELECT
+    toStartOfMinute(timestamp) AS timestamp,
+    chain_slug,
+    upstream_host,
+    count() AS request_count,
+    countIf(is_error = 1) AS error_count,
+    countIf(status_code < 500) AS healthy_count,
+    countIf(status_code >= 500) AS server_error_count,
+    sum(latency_ms) AS latency_sum_ms,
+    quantilesState(0.95, 0.99)(latency_ms) AS latency_quantiles
+FROM requests_raw
+GROUP BY timestamp, chain_slug, upstream_host;
+
+-- One row per backfill that ran, so reruns do not count requests twice
+CREATE TABLE IF NOT EXISTS schema_backfills (
+    name String,
+    completed_at DateTime DEFAULT now()
+)
+ENGINE = ReplacingMergeTree(completed_at)
+ORDER BY name;
+
+-- An earlier version of this file backfilled only the minutes before the
+-- first one the view wrote. When the view wrote its creation minute, the
+-- requests of that minute before it was created are still missing, and
+-- only those are backfilled.
+INSERT INTO chain_health_minutely
+WITH
+    (
+        SELECT metadata_modification_time
+        FROM system.tables
+        WHERE database = 'telemetry' AND name = 'chain_health_minutely_mv'
+    ) AS created_at,
+    toStartOfMinute(created_at) AS created_minute,
+    (
+        SELECT count() > 0
+        FROM chain_health_minutely
+        WHERE timestamp < created_minute
+    ) AS backfilled_before,
+    (
+        SELECT count() > 0
+        FROM requests_raw
+        WHERE timestamp >= created_at
+          AND timestamp < created_minute + INTERVAL 1 MINUTE
+    ) AS view_wrote_created_minute
+SELECT
+    toStartOfMinute(timestamp) AS minute,
+    chain_slug,
+    upstream_host,
+    count(),
+    countIf(is_error = 1),
+    countIf(status_code < 500),
+    countIf(status_code >= 500),
+    sum(latency_ms),
+    quantilesState(0.95, 0.99)(latency_ms)
+FROM requests_raw
+WHERE timestamp < created_at
+  AND (NOT backfilled_before OR (view_wrote_created_minute AND timestamp >= created_minute))
+  AND (SELECT count() FROM schema_backfills WHERE name = 'chain_health_minutely') = 0
+GROUP BY minute, chain_slug, upstream_host;
+
+INSERT INTO schema_backfills (name)
+SELECT 'chain_health_minutely'
+WHERE (SELECT count() FROM schema_backfills WHERE name = 'chain_health_minutely') = 0;
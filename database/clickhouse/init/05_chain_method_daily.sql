@@ -0,0 +1,104 @@
+-- ============================================================================
+-- Method Usage by Chain - Mergeable daily method analytics
+-- ============================================================================
+-- chain_method_usage (02_chain_analytics.sql) stores uniques, averages and
+-- rates per insert block, and its SummingMergeTree adds them up on merge.
+-- chain_method_daily keeps uniques and latencies as aggregate states, and
+-- averages and rates are derived at query time from the summed counters.
+-- The reporting-api method analytics read this table; chain_method_usage is
+-- no longer read and may be dropped (DROP VIEW chain_method_usage_mv, then
+-- DROP TABLE chain_method_usage).
+--
+-- Existing deployments can apply this file with clickhouse-client; it is
+-- safe to run again. The first run backfills from requests_raw (14 days)
+-- every request before the view was created, up to and including the
+-- creation day. schema_backfills records that it ran.
+
+USE telemetry;
+
+CREATE TABLE IF NOT EXISTS chain_method_daily (
+    date Date CODEC(DoubleDelta, LZ4),
+
+    chain_slug String CODEC(ZSTD(1)),
+    rpc_method String CODEC(ZSTD(1)),
+
+    -- Usage metrics
+    request_count UInt64 CODEC(T64, LZ4),
+    unique_consumers AggregateFunction(uniq, String),
+    unique_organizations AggregateFunction(uniq, String),
+
+    -- Compute units
+    total_compute_units UInt64 CODEC(T64, LZ4),
+
+    -- Performance
+    latency_ms_avg AggregateFunction(avg, UInt32),
+    latency_ms_p95 AggregateFunction(quantile(0.95), UInt32),
+
+    -- Errors
+    error_count UInt64 CODEC(T64, LZ4)
+)
+ENGINE = SummingMergeTree()
+PARTITION BY toYYYYMM(date)
+ORDER BY (date, chain_slug, rpc_method)
+TTL date + INTERVAL 180 DAY
+SETTINGS index_granularity = 8192;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS chain_method_daily_mv
+TO chain_method_daily
+AS
+SELECT
+    toDate(timestamp) as date,
+    chain_slug,
+    rpc_method,
+
+    count() as request_count,
+    uniqState(consumer_id) as unique_consumers,
+    uniqState(organization_id) as unique_organizations,
+
+    sum(compute_units) as total_compute_units,
+
+    avgState(toUInt32(latency_ms)) as latency_ms_avg,
+    quantileState(0.95)(toUInt32(latency_ms)) as latency_ms_p95,
+
+    countIf(is_error = 1) as error_count
+FROM requests_raw
+GROUP BY date, chain_slug, rpc_method;
+
+-- One row per backfill that ran, so reruns do not count requests twice
+CREATE TABLE IF NOT EXISTS schema_backfills (
+    name String,
+    completed_at DateTime DEFAULT now()
+)
+ENGINE = ReplacingMergeTree(completed_at)
+ORDER BY name;
+
+-- Requests before the view was created; the view counts the rest of the
+-- creation day. Days before the creation day are only there when an earlier
+-- version of this file already backfilled, which it did up to the same
+-- point, so those deployments are not backfilled again.
+INSERT INTO chain_method_daily
+WITH (
+    SELECT metadata_modification_time
+    FROM system.tables
+    WHERE database = 'telemetry' AND name = 'chain_method_daily_mv'
+) AS created_at
+SELECT
+    toDate(timestamp) as date,
+    chain_slug,
+    rpc_method,
+    count(),
+    uniqState(consumer_id),
+    uniqState(organization_id),
+    sum(compute_units),
+    avgState(toUInt32(latency_ms)),
+    quantileState(0.95)(toUInt32(latency_ms)),
+    countIf(is_error = 1)
+FROM requests_raw
+WHERE timestamp < created_at
+  AND (SELECT count() FROM schema_backfills WHERE name = 'chain_method_daily') = 0
+  AND (SELECT count() FROM chain_method_daily WHERE date < toDate(created_at)) = 0
+GROUP BY date, chain_slug, rpc_method;
+
+INSERT INTO schema_backfills (name)
+SELECT 'chain_method_daily'
+WHERE (SELECT count() FROM schema_backfills WHERE name = 'chain_method_daily') = 0;
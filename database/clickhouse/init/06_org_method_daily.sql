@@ -0,0 +1,103 @@
+-- ============================================================================
+-- Billable Usage by Method - Daily method usage per organization
+-- ============================================================================
+-- Plans whose rating rules weigh usage by RPC method are rated from this
+-- table. usage_hourly also splits usage by method but is kept for 90 days,
+-- which does not cover yearly periods or late invoicing; this table is kept
+-- as long as usage_daily.
+--
+-- Existing deployments can apply this file with clickhouse-client; it is
+-- safe to run again. The first run backfills every request before the view
+-- was created: whole hours from usage_hourly (90 days), the rest of the
+-- creation hour from requests_raw. schema_backfills records that it ran.
+
+USE telemetry;
+
+CREATE TABLE IF NOT EXISTS org_method_daily (
+    date Date CODEC(DoubleDelta, LZ4),
+
+    organization_id String CODEC(ZSTD(1)),
+    chain_slug String CODEC(ZSTD(1)),
+    rpc_method String CODEC(ZSTD(1)),
+
+    request_count UInt64 CODEC(T64, LZ4),
+    compute_units_used UInt64 CODEC(T64, LZ4),
+    total_response_size UInt64 CODEC(T64, LZ4)
+)
+ENGINE = SummingMergeTree()
+PARTITION BY toYYYYMM(date)
+ORDER BY (organization_id, date, chain_slug, rpc_method)
+TTL date + INTERVAL 540 DAY  -- Same retention as usage_daily
+SETTINGS index_granularity = 8192;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS org_method_daily_mv
+TO org_method_daily
+AS
+SELECT
+    toDate(timestamp) as date,
+    organization_id,
+    chain_slug,
+    rpc_method,
+
+    count() as request_count,
+    sum(compute_units) as compute_units_used,
+    sum(response_size) as total_response_size
+FROM requests_raw
+GROUP BY date, organization_id, chain_slug, rpc_method;
+
+-- One row per backfill that ran, so reruns do not count usage twice
+CREATE TABLE IF NOT EXISTS schema_backfills (
+    name String,
+    completed_at DateTime DEFAULT now()
+)
+ENGINE = ReplacingMergeTree(completed_at)
+ORDER BY name;
+
+INSERT INTO org_method_daily
+WITH (
+    SELECT metadata_modification_time
+    FROM system.tables
+    WHERE database = 'telemetry' AND name = 'org_method_daily_mv'
+) AS created_at
+SELECT
+    date,
+    organization_id,
+    chain_slug,
+    rpc_method,
+    sum(requests),
+    sum(compute_units),
+    sum(egress_bytes)
+FROM (
+    SELECT
+        toDate(hour) as date,
+        organization_id,
+        chain_slug,
+        rpc_method,
+        sumMerge(request_count) as requests,
+        sumMerge(compute_units_used) as compute_units,
+        sumMerge(total_response_size) as egress_bytes
+    FROM usage_hourly
+    WHERE hour < toStartOfHour(created_at)
+    GROUP BY date, organization_id, chain_slug, rpc_method
+
+    UNION ALL
+
+    SELECT
+        toDate(timestamp) as date,
+        organization_id,
+        chain_slug,
+        rpc_method,
+        count() as requests,
+        toUInt64(sum(compute_units)) as compute_units,
+        toUInt64(sum(response_size)) as egress_bytes
+    FROM requests_raw
+    WHERE timestamp >= toStartOfHour(created_at)
+      AND timestamp < created_at
+    GROUP BY date, organization_id, chain_slug, rpc_method
+)
+WHERE (SELECT count() FROM schema_backfills WHERE name = 'org_method_daily') = 0
+GROUP BY date, organization_id, chain_slug, rpc_method;
+
+INSERT INTO schema_backfills (name)
+SELECT 'org_method_daily'
+WHERE (SELECT count() FROM schema_backfills WHERE name = 'org_method_daily') = 0;
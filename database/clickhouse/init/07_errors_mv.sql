@@ -0,0 +1,51 @@
+-- ============================================================================
+-- Errors Explorer - Error types, chain and millisecond timestamps
+-- ============================================================================
+-- errors_mv (01_schema.sql) wrote every error as 'http_error' without its
+-- chain or millisecond timestamp. The errors explorer groups by error type,
+-- filters by chain and pages on (timestamp_ms, request_id), so the view now
+-- fills them in.
+--
+-- Existing deployments can apply this file with clickhouse-client; it is
+-- safe to run again. Errors written before it get their timestamp_ms from
+-- their timestamp. They keep the 'http_error' type, which is part of the
+-- sorting key and cannot be updated, and an empty chain_slug until they
+-- expire after 30 days.
+
+USE telemetry;
+
+-- MODIFY QUERY swaps the query of the view in place, so no error inserted
+-- into requests_raw meanwhile is lost
+SET allow_experimental_alter_materialized_view_structure = 1;
+
+ALTER TABLE errors_mv MODIFY QUERY
+SELECT
+    timestamp,
+    timestamp_ms,
+    request_id,
+    organization_id,
+    consumer_id,
+    chain_slug,
+    multiIf(
+        status_code = 429, 'rate_limited',
+        status_code >= 500, 'server_error',
+        status_code >= 400, 'client_error',
+        error_message != '', 'rpc_error',
+        'http_error'
+    ) as error_type,
+    error_message,
+    '' as error_stack,
+    method,
+    path,
+    status_code,
+    rpc_method,
+    client_ip,
+    metadata
+FROM requests_raw
+WHERE is_error = 1;
+
+-- Rows of the old view have no timestamp_ms; rows of the new one never have
+-- a timestamp_ms before their timestamp, so a rerun updates nothing
+ALTER TABLE errors
+UPDATE timestamp_ms = toDateTime64(timestamp, 3)
+WHERE timestamp_ms < timestamp;
@@ -0,0 +1,18 @@
+-- ============================================================================
+-- Request Traces - Skip index on request_id
+-- ============================================================================
+-- GET /api/v1/requests/:requestId looks a request up by id. requests_raw is
+-- ordered by timestamp, so without this bloom filter the lookup reads all 14
+-- days of raw requests.
+--
+-- Existing deployments can apply this file with clickhouse-client; it is
+-- safe to run again. ADD INDEX only covers parts written after it, so the
+-- index is also built for the existing parts. MATERIALIZE INDEX runs as a
+-- mutation in the background; a rerun rebuilds the index again.
+
+USE telemetry;
+
+ALTER TABLE requests_raw
+ADD INDEX IF NOT EXISTS idx_request_id request_id TYPE bloom_filter(0.01);
+
+ALTER TABLE requests_raw MATERIALIZE INDEX idx_request_id;
@@ -0,0 +1,41 @@
+-- ============================================================================
+-- Public Status Page - Incidents
+-- ============================================================================
+
+-- ============================================================================
+-- Status incidents (detected by the reporting-api status monitor)
+-- ============================================================================
+CREATE TABLE status_incidents (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    chain_id UUID NOT NULL REFERENCES chains(id) ON DELETE CASCADE,
+
+    -- Worst state reached during the incident
+    status VARCHAR(20) NOT NULL CHECK (status IN ('degraded', 'outage')),
+    title VARCHAR(255) NOT NULL,
+
+    -- Timeline (resolved_at is NULL while the incident is ongoing)
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+
+    -- Worst metrics observed
+    peak_error_rate_pct NUMERIC(6, 2) DEFAULT 0,
+    peak_p95_latency_ms NUMERIC(10, 2) DEFAULT 0,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK (resolved_at IS NULL OR resolved_at >= started_at)
+);
+
+CREATE INDEX idx_status_incidents_chain ON status_incidents(chain_id, started_at DESC);
+CREATE INDEX idx_status_incidents_started ON status_incidents(started_at DESC);
+
+-- At most one open incident per chain (monitors on several replicas race safely)
+CREATE UNIQUE INDEX idx_status_incidents_open ON status_incidents(chain_id) WHERE resolved_at IS NULL;
+
+-- ============================================================================
+-- Triggers
+-- ============================================================================
+CREATE TRIGGER update_status_incidents_updated_at BEFORE UPDATE ON status_incidents FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE status_incidents IS 'Chain incidents shown on the public status page and feeds';
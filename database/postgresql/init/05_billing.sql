@@ -0,0 +1,43 @@
+-- ============================================================================
+-- Billing - Plan overage pricing and invoice numbering
+-- ============================================================================
+-- Used by the reporting-api billing engine (cmd/billing). Existing
+-- deployments can apply this file with psql; it is safe to run again.
+
+-- ============================================================================
+-- Plan allowances and overage rates, per billing month
+-- ============================================================================
+-- A meter with a zero overage rate is never charged. Yearly subscriptions get
+-- twelve months of allowance.
+ALTER TABLE plans
+    ADD COLUMN IF NOT EXISTS included_compute_units BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS included_requests BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS included_egress_gb DECIMAL(12,2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS overage_per_million_compute_units DECIMAL(10,4) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS overage_per_million_requests DECIMAL(10,4) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS overage_per_gb DECIMAL(10,4) NOT NULL DEFAULT 0;
+
+-- Free is hard limited by its rate limits; Enterprise is priced per contract
+UPDATE plans SET included_compute_units = 3000000, included_egress_gb = 5 WHERE slug = 'free';
+UPDATE plans SET included_compute_units = 25000000, included_egress_gb = 50,
+    overage_per_million_compute_units = 1.50, overage_per_gb = 0.10 WHERE slug = 'basic';
+UPDATE plans SET included_compute_units = 200000000, included_egress_gb = 500,
+    overage_per_million_compute_units = 1.00, overage_per_gb = 0.08 WHERE slug = 'pro';
+
+-- ============================================================================
+-- Invoice numbers
+-- ============================================================================
+-- Gapless counters per prefix (e.g. INV-2025). The counter row is locked by
+-- the transaction inserting the invoice, so a rolled back invoice does not
+-- use up a number.
+CREATE TABLE IF NOT EXISTS invoice_number_sequences (
+    prefix VARCHAR(40) PRIMARY KEY,
+    last_value BIGINT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A billing period is invoiced once; voiding an invoice allows a new one
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_subscription_period
+    ON invoices(subscription_id, period_start) WHERE status <> 'void';
+
+COMMENT ON TABLE invoice_number_sequences IS 'Last invoice number issued per prefix';
@@ -0,0 +1,80 @@
+-- ============================================================================
+-- Billing - Versioned rating rules
+-- ============================================================================
+-- Rating rules turn metered usage into charges (see docs/BILLING.md). Each
+-- plan has numbered versions of a JSON definition; a billing period is rated
+-- with the latest version effective at its start. Versions are never edited:
+-- a price change is a new version with a later effective_from. Plans without
+-- rules are rated from the allowance and overage columns of plans.
+--
+-- Definition format (amounts in the plan currency; allowances, tier bounds,
+-- minimums and caps per billing month):
+--
+-- {
+--   "minimum_commit": 0,        -- usage charges are topped up to this
+--   "maximum_charge": 0,        -- usage charges are credited down to this; 0 is no cap
+--   "meters": [{
+--     "meter": "compute_units", -- compute_units, requests or egress_gb
+--     "unit_size": 1000000,     -- units one unit_price buys
+--     "included": 25000000,     -- free units before the tiers
+--     "mode": "graduated",      -- graduated: each tier prices its own units
+--                               -- volume: the reached tier prices all units
+--     "tiers": [
+--       {"up_to": 100000000, "unit_price": 1.50},
+--       {"unit_price": 1.00, "flat_fee": 0}     -- last tier has no up_to
+--     ],
+--     "chain_coefficients": {"eth-mainnet": 1.0, "solana-mainnet": 0.5},
+--     "method_coefficients": {"debug_traceTransaction": 5},
+--     "minimum_charge": 0,
+--     "maximum_charge": 0
+--   }]
+-- }
+--
+-- Coefficients weight usage before the included units and tiers apply and
+-- default to 1. Tier bounds count billable units, i.e. after the included
+-- units.
+
+CREATE TABLE IF NOT EXISTS rating_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    plan_id UUID NOT NULL REFERENCES plans(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    definition JSONB NOT NULL,
+    description TEXT,
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (plan_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rating_rules_plan_effective ON rating_rules(plan_id, effective_from DESC);
+
+-- The rules version an invoice was rated with, for audits
+ALTER TABLE invoices
+    ADD COLUMN IF NOT EXISTS rating_rule_id UUID REFERENCES rating_rules(id);
+
+-- Version 1 of the paid plans matches their overage columns
+INSERT INTO rating_rules (plan_id, version, definition, description, effective_from)
+SELECT id, 1, '{
+    "meters": [
+        {"meter": "compute_units", "unit_size": 1000000, "included": 25000000, "mode": "graduated",
+         "tiers": [{"unit_price": 1.50}]},
+        {"meter": "egress_gb", "unit_size": 1, "included": 50, "mode": "graduated",
+         "tiers": [{"unit_price": 0.10}]}
+    ]
+}'::jsonb, 'Initial Basic pricing', '2025-01-01T00:00:00Z'
+FROM plans WHERE slug = 'basic'
+ON CONFLICT (plan_id, version) DO NOTHING;
+
+INSERT INTO rating_rules (plan_id, version, definition, description, effective_from)
+SELECT id, 1, '{
+    "meters": [
+        {"meter": "compute_units", "unit_size": 1000000, "included": 200000000, "mode": "graduated",
+         "tiers": [{"unit_price": 1.00}]},
+        {"meter": "egress_gb", "unit_size": 1, "included": 500, "mode": "graduated",
+         "tiers": [{"unit_price": 0.08}]}
+    ]
+}'::jsonb, 'Initial Pro pricing', '2025-01-01T00:00:00Z'
+FROM plans WHERE slug = 'pro'
+ON CONFLICT (plan_id, version) DO NOTHING;
+
+COMMENT ON TABLE rating_rules IS 'Versioned usage rating rules per plan';
+COMMENT ON COLUMN rating_rules.definition IS 'Meters with included units, tiers, coefficients, minimums and caps';
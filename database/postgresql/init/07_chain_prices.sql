@@ -0,0 +1,43 @@
+-- ============================================================================
+-- Billing - Per-chain list prices for usage cost estimates
+-- ============================================================================
+-- The reporting-api copies this table into ClickHouse chain_prices, which
+-- prices usage into chain_org_usage.estimated_cost as it is ingested
+-- (database/clickhouse/init/03_chain_prices.sql). Estimates are in USD and
+-- independent of invoicing: rating rules and plan allowances do not apply.
+--
+-- A row with an empty plan_slug is the chain's price for plans without their
+-- own row. Changes reach ClickHouse within the sync interval and apply to
+-- usage ingested from then on; past estimates are not repriced.
+
+CREATE TABLE IF NOT EXISTS chain_prices (
+    chain_slug VARCHAR(50) NOT NULL REFERENCES chains(slug) ON UPDATE CASCADE ON DELETE CASCADE,
+    plan_slug VARCHAR(50) NOT NULL DEFAULT '',
+    price_per_million_compute_units DECIMAL(12,6) NOT NULL DEFAULT 0 CHECK (price_per_million_compute_units >= 0),
+    price_per_million_requests DECIMAL(12,6) NOT NULL DEFAULT 0 CHECK (price_per_million_requests >= 0),
+    price_per_gb DECIMAL(12,6) NOT NULL DEFAULT 0 CHECK (price_per_gb >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chain_slug, plan_slug)
+);
+
+DROP TRIGGER IF EXISTS update_chain_prices_updated_at ON chain_prices;
+CREATE TRIGGER update_chain_prices_updated_at BEFORE UPDATE ON chain_prices
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Every chain starts at the Pro overage rates; paid plans at their own
+INSERT INTO chain_prices (chain_slug, plan_slug, price_per_million_compute_units, price_per_gb)
+SELECT c.slug, '', 1.00, 0.08
+FROM chains c
+ON CONFLICT (chain_slug, plan_slug) DO NOTHING;
+
+INSERT INTO chain_prices (chain_slug, plan_slug, price_per_million_compute_units, price_per_million_requests, price_per_gb)
+SELECT c.slug, p.slug, p.overage_per_million_compute_units, p.overage_per_million_requests, p.overage_per_gb
+FROM chains c
+CROSS JOIN plans p
+WHERE p.overage_per_million_compute_units > 0
+   OR p.overage_per_million_requests > 0
+   OR p.overage_per_gb > 0
+ON CONFLICT (chain_slug, plan_slug) DO NOTHING;
+
+COMMENT ON TABLE chain_prices IS 'USD list prices per chain and plan for ClickHouse usage cost estimates';
+COMMENT ON COLUMN chain_prices.plan_slug IS 'Plan the price applies to; empty for every plan without its own row';
@@ -0,0 +1,54 @@
+-- ============================================================================
+-- Billing - Stripe metered usage export and invoice webhooks
+-- ============================================================================
+-- Used by the reporting-api stripe-export command and the /webhooks/stripe
+-- endpoint. Existing deployments can apply this file with psql; it is safe to
+-- run again.
+
+-- ============================================================================
+-- Stripe identifiers
+-- ============================================================================
+-- Only subscriptions with a Stripe subscription get usage exported
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS stripe_subscription_id VARCHAR(255) UNIQUE;
+
+ALTER TABLE invoices
+    ADD COLUMN IF NOT EXISTS stripe_invoice_id VARCHAR(255) UNIQUE;
+
+-- Stripe invoices are keyed by stripe_invoice_id and may cover the same
+-- period as the engine's draft, which is kept for reconciliation
+DROP INDEX IF EXISTS idx_invoices_subscription_period;
+CREATE UNIQUE INDEX idx_invoices_subscription_period
+    ON invoices(subscription_id, period_start) WHERE status <> 'void' AND stripe_invoice_id IS NULL;
+
+-- ============================================================================
+-- Plan meters to Stripe metered prices
+-- ============================================================================
+-- Usage is reported in whole units of the meter: compute units, requests and
+-- bytes of egress. Set transform_quantity on the Stripe price to bill per
+-- million or per GB (divide_by 1073741824); Stripe rounds the period's total,
+-- so small hourly egress still adds up.
+CREATE TABLE IF NOT EXISTS stripe_prices (
+    plan_id UUID NOT NULL REFERENCES plans(id) ON DELETE CASCADE,
+    meter VARCHAR(30) NOT NULL CHECK (meter IN ('compute_units', 'requests', 'egress_gb')),
+    stripe_price_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (plan_id, meter)
+);
+
+-- ============================================================================
+-- Export watermarks
+-- ============================================================================
+-- Usage up to exported_through has been reported for the subscription's
+-- meter. The watermark only moves forward, and each usage record carries an
+-- idempotency key of its bucket, so reruns never report a bucket twice.
+CREATE TABLE IF NOT EXISTS stripe_usage_watermarks (
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    meter VARCHAR(30) NOT NULL,
+    exported_through TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (subscription_id, meter)
+);
+
+COMMENT ON TABLE stripe_prices IS 'Stripe metered price of each plan meter';
+COMMENT ON TABLE stripe_usage_watermarks IS 'End of the usage reported to Stripe per subscription meter';
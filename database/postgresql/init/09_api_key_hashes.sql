@@ -0,0 +1,16 @@
+-- ============================================================================
+-- API key hashes for Postgres key verification
+-- ============================================================================
+-- With Unkey disabled the reporting-api verifies customer keys against
+-- key_hash, the hex SHA-256 of the full key. Prefixes are not secret, so a key
+-- without a hash is rejected. Whoever issues a key stores its hash, e.g.
+--   UPDATE api_keys SET key_hash = encode(sha256('<full key>'::bytea), 'hex') WHERE id = ...;
+-- Existing deployments can apply this file with psql; it is safe to run again.
+
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS key_hash CHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash
+    ON api_keys(key_hash) WHERE key_hash IS NOT NULL;
+
+COMMENT ON COLUMN api_keys.key_hash IS 'Hex SHA-256 of the full API key; NULL keys only verify through Unkey';
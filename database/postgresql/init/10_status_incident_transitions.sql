@@ -0,0 +1,34 @@
+-- ============================================================================
+-- Status incident transitions
+-- ============================================================================
+-- status_incidents keeps the worst state an incident reached, so uptime
+-- computed from it counted a whole incident as outage once it escalated.
+-- The status monitor records every state change of an open incident here
+-- ('operational' while it waits to resolve), and uptime sums only the
+-- intervals spent in outage.
+-- Existing deployments can apply this file with psql; it is safe to run
+-- again. Incidents older than this table get one transition at their start
+-- with their worst state, i.e. they keep counting as before.
+
+CREATE TABLE IF NOT EXISTS status_incident_transitions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    incident_id UUID NOT NULL REFERENCES status_incidents(id) ON DELETE CASCADE,
+
+    -- State observed from changed_at until the next transition or resolution
+    status VARCHAR(20) NOT NULL CHECK (status IN ('degraded', 'outage', 'operational')),
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_incident_transitions_incident
+    ON status_incident_transitions(incident_id, changed_at);
+
+INSERT INTO status_incident_transitions (incident_id, status, changed_at)
+SELECT i.id, i.status, i.started_at
+FROM status_incidents i
+WHERE NOT EXISTS (
+    SELECT 1 FROM status_incident_transitions t WHERE t.incident_id = i.id
+);
+
+COMMENT ON TABLE status_incident_transitions IS 'State changes of status incidents; uptime counts only outage intervals';
@@ -0,0 +1,105 @@
+// Command billing closes due subscription periods into draft invoices.
+//
+//	billing -org <organization id>   close one organization's due periods
+//	billing -all                     close every organization's due periods
+//	billing -all -dry-run            print the invoices without writing them
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/billing"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+	"go.uber.org/zap"
+)
+
+func main() {
+	orgID := flag.String("org", "", "organization id to invoice")
+	all := flag.Bool("all", false, "invoice every organization with a due period")
+	dryRun := flag.Bool("dry-run", false, "print the invoices instead of writing them")
+	at := flag.String("at", "", "close periods ending by this RFC3339 time instead of now")
+	flag.Parse()
+
+	if (*orgID == "") == !*all {
+		log.Fatal("Exactly one of -org or -all is required")
+	}
+
+	now := time.Now().UTC()
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			log.Fatalf("Invalid -at: %v", err)
+		}
+		now = t
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
+	logger, err := zap.NewProduction()
+	if cfg.Logging.Format != "json" {
+		logger, err = zap.NewDevelopment()
+	}
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	chRepo, err := repository.NewClickHouseRepository(&cfg.ClickHouse)
+	if err != nil {
+		logger.Fatal("Failed to connect to ClickHouse", zap.Error(err))
+	}
+	defer chRepo.Close()
+
+	pgRepo, err := repository.NewPostgresRepository(&cfg.PostgreSQL)
+	if err != nil {
+		logger.Fatal("Failed to connect to PostgreSQL", zap.Error(err))
+	}
+	defer pgRepo.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	engine := billing.NewEngine(chRepo, pgRepo, &cfg.Billing, logger)
+	results, err := engine.Run(ctx, *orgID, now, *dryRun)
+	if err != nil {
+		logger.Fatal("Billing run failed", zap.Error(err))
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			logger.Error("Failed to close billing period",
+				zap.String("subscription_id", result.SubscriptionID),
+				zap.String("organization_id", result.OrganizationID),
+				zap.Error(result.Err),
+			)
+			continue
+		}
+		if *dryRun {
+			out, _ := json.MarshalIndent(result.Invoice, "", "  ")
+			os.Stdout.Write(append(out, '\n'))
+		}
+	}
+
+	logger.Info("Billing run finished",
+		zap.Int("invoices", len(results)-failed),
+		zap.Int("failed", failed),
+		zap.Bool("dry_run", *dryRun),
+	)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
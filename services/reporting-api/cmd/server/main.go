@@ -11,10 +11,15 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/billing"
 	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
 	"github.com/hoodrun/rpc-gateway/reporting-api/internal/handlers"
 	"github.com/hoodrun/rpc-gateway/reporting-api/internal/middleware"
 	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/status"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/stripe"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/unkey"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
@@ -56,9 +61,34 @@ func main() {
 	defer pgRepo.Close()
 	logger.Info("Connected to PostgreSQL", zap.String("host", cfg.PostgreSQL.Host))
 
+	var redisClient *redis.Client
+	if cfg.Redis.Enabled {
+		redisClient, err = repository.NewRedisClient(&cfg.Redis)
+		if err != nil {
+			logger.Fatal("Failed to connect to Redis", zap.Error(err))
+		}
+		defer redisClient.Close()
+		logger.Info("Connected to Redis", zap.String("host", cfg.Redis.Host))
+	}
+
+	// API keys resolve through Unkey when enabled, otherwise by hash in Postgres
+	var keyResolver middleware.APIKeyResolver = pgRepo
+	var unkeyClient *unkey.Client
+	if cfg.Unkey.Enabled {
+		var cache unkey.Cache = unkey.NewMemoryCache()
+		if redisClient != nil {
+			cache = unkey.NewRedisCache(redisClient)
+		}
+		unkeyClient = unkey.NewClient(&cfg.Unkey, cache)
+		keyResolver = unkeyClient
+		logger.Info("Unkey key verification enabled", zap.String("verify_url", cfg.Unkey.VerifyURL))
+	}
+
 	// Initialize handlers
 	healthHandler := handlers.NewHealthHandler(chRepo, pgRepo)
 	usageHandler := handlers.NewUsageHandler(chRepo, pgRepo)
+	chainsHandler := handlers.NewChainsHandler(chRepo, pgRepo)
+	billingHandler := handlers.NewBillingHandler(billing.NewEngine(chRepo, pgRepo, &cfg.Billing, logger))
 
 	// Setup Gin router
 	if cfg.Server.Environment == "production" {
@@ -91,21 +121,132 @@ func main() {
 	router.HEAD("/health/ready", healthHandler.ReadinessProbe)
 	router.GET("/metrics", handlers.PrometheusHandler())
 
+	// Public status page (no auth required)
+	if cfg.Status.Enabled {
+		statusService := status.NewService(chRepo, pgRepo, &cfg.Status)
+		statusHandler := handlers.NewStatusHandler(statusService, cfg.Status.PublicURL)
+		router.GET("/status", statusHandler.GetStatus)
+		router.GET("/status/incidents", statusHandler.ListIncidents)
+		// Feed links need a configured public URL; the Host header is
+		// client-controlled and the feeds are cached publicly
+		if cfg.Status.PublicURL != "" {
+			router.GET("/status/feed.atom", statusHandler.AtomFeed)
+			router.GET("/status/feed.rss", statusHandler.RSSFeed)
+		} else {
+			logger.Info("Status feeds disabled; set status.publicurl to serve them")
+		}
+
+		// Run the monitor on a single replica when scaling out; concurrent
+		// monitors are safe but duplicate the checks
+		if cfg.Status.MonitorEnabled {
+			monitor := status.NewMonitor(chRepo, pgRepo, &cfg.Status, logger)
+			monitor.Start()
+			defer monitor.Close()
+		}
+	}
+
+	// ClickHouse prices usage at ingest with the last synced chain prices
+	if cfg.Billing.PriceSyncInterval > 0 {
+		priceSync := billing.NewPriceSync(chRepo, pgRepo, &cfg.Billing, logger)
+		priceSync.Start()
+		defer priceSync.Close()
+	}
+
+	// Stripe webhooks authenticate with their signature, not an API key
+	if cfg.Stripe.Enabled {
+		stripeHandler := handlers.NewStripeHandler(stripe.NewWebhooks(pgRepo, &cfg.Stripe, logger))
+		router.POST("/webhooks/stripe", stripeHandler.Webhook)
+	}
+
 	// API v1 routes (with optional auth)
 	v1 := router.Group("/api/v1")
 	if cfg.Auth.Enabled {
-		v1.Use(middleware.AuthMiddleware(&cfg.Auth))
+		var tokenVerifier middleware.TokenVerifier
+		if cfg.Auth.JWT.Enabled {
+			jwks, err := middleware.NewJWKS(
+				cfg.Auth.JWT.JWKSURL,
+				time.Duration(cfg.Auth.JWT.RefreshInterval)*time.Second,
+				logger,
+			)
+			if err != nil {
+				logger.Fatal("Failed to load JWKS", zap.Error(err))
+			}
+			defer jwks.Close()
+			tokenVerifier = middleware.NewJWTVerifier(&cfg.Auth.JWT, jwks, pgRepo)
+			logger.Info("JWT authentication enabled", zap.String("issuer", cfg.Auth.JWT.Issuer))
+		}
+
+		v1.Use(middleware.AuthMiddleware(&cfg.Auth, keyResolver, tokenVerifier))
+		v1.Use(middleware.TenantScopeMiddleware(pgRepo))
 		logger.Info("Authentication enabled", zap.Bool("auth_enabled", true))
 	} else {
 		logger.Warn("Authentication DISABLED - not suitable for production!")
 	}
 
+	// Rate limiting runs after auth so tenants are limited by their plan
+	if cfg.RateLimit.Enabled {
+		var limiter middleware.Limiter = middleware.NewRateLimiter(cfg.RateLimit.DefaultPerMinute, time.Minute)
+		if cfg.RateLimit.Backend == "redis" {
+			limiter = middleware.NewRedisLimiter(redisClient, limiter, logger)
+		}
+
+		// A nil interface (not a nil *RateLimitEventWriter) disables recording
+		var recorder middleware.RateLimitRecorder
+		if cfg.RateLimit.RecordEvents {
+			eventWriter := repository.NewRateLimitEventWriter(chRepo, &cfg.RateLimit, logger)
+			defer eventWriter.Close()
+			recorder = eventWriter
+		}
+
+		// Validate has already rejected malformed route costs
+		routeCosts, _ := cfg.RateLimit.ParseRouteCosts()
+		v1.Use(middleware.RateLimitMiddleware(limiter, &cfg.RateLimit, pgRepo, routeCosts, recorder))
+		logger.Info("Rate limiting enabled",
+			zap.String("backend", cfg.RateLimit.Backend),
+			zap.Int("default_per_minute", cfg.RateLimit.DefaultPerMinute),
+			zap.Bool("record_events", cfg.RateLimit.RecordEvents),
+		)
+	}
+
+	usageRead := middleware.RequireScope(middleware.ScopeUsageRead)
+	keysRead := middleware.RequireScope(middleware.ScopeKeysRead)
+	billingRead := middleware.RequireScope(middleware.ScopeBillingRead)
+	chainsRead := middleware.RequireScope(middleware.ScopeChainsRead)
+	adminOnly := middleware.RequireScope(middleware.ScopeAdmin)
+
 	// Usage endpoints
-	v1.GET("/usage/organization/:orgId/summary", usageHandler.GetOrganizationUsageSummary)
-	v1.GET("/usage/organization/:orgId/daily", usageHandler.GetOrganizationDailyUsage)
-	v1.GET("/usage/organization/:orgId/hourly", usageHandler.GetOrganizationHourlyUsage)
-	v1.GET("/usage/organization/:orgId/by-chain", usageHandler.GetOrganizationUsageByChain)
-	v1.GET("/usage/key/:keyPrefix", usageHandler.GetAPIKeyUsage)
+	v1.GET("/usage/organization/:orgId/summary", usageRead, usageHandler.GetOrganizationUsageSummary)
+	v1.GET("/usage/organization/:orgId/daily", usageRead, usageHandler.GetOrganizationDailyUsage)
+	v1.GET("/usage/organization/:orgId/hourly", usageRead, usageHandler.GetOrganizationHourlyUsage)
+	v1.GET("/usage/organization/:orgId/by-chain", usageRead, usageHandler.GetOrganizationUsageByChain)
+	v1.GET("/usage/organization/:orgId/timeseries", usageRead, usageHandler.GetOrganizationTimeseries)
+	v1.GET("/usage/organization/:orgId/breakdown", usageRead, usageHandler.GetOrganizationBreakdown)
+	v1.GET("/usage/organization/:orgId/rate-limits", usageRead, usageHandler.GetOrganizationRateLimits)
+	v1.GET("/usage/organization/:orgId/errors", usageRead, usageHandler.GetOrganizationErrors)
+	v1.GET("/usage/organization/:orgId/errors/:requestId", usageRead, usageHandler.GetOrganizationErrorByRequestID)
+	v1.GET("/usage/organization/:orgId/requests", usageRead, usageHandler.SearchOrganizationRequests)
+	v1.GET("/usage/organization/:orgId/methods/:method/timeseries", usageRead, usageHandler.GetOrganizationMethodTimeseries)
+	v1.GET("/usage/organization/:orgId/expensive-methods", usageRead, usageHandler.GetOrganizationExpensiveMethods)
+	v1.GET("/requests/:requestId", usageRead, usageHandler.GetRequest)
+	v1.GET("/usage/key/:keyPrefix", keysRead, usageHandler.GetAPIKeyUsage)
+	v1.GET("/usage/organization/:orgId/keys", keysRead, usageHandler.GetOrganizationKeys)
+
+	// Billing endpoints
+	v1.GET("/billing/organization/:orgId/estimate", billingRead, billingHandler.GetOrganizationEstimate)
+
+	// Chain endpoints (upstream URLs may embed provider credentials: admin only)
+	v1.GET("/chains", chainsRead, chainsHandler.ListChains)
+	v1.GET("/chains/:slug/health", chainsRead, chainsHandler.GetChainHealth)
+	v1.GET("/chains/:slug/upstreams", adminOnly, chainsHandler.GetChainUpstreams)
+
+	// Admin endpoints
+	v1.GET("/admin/methods", adminOnly, usageHandler.GetMethodAnalytics)
+	v1.GET("/admin/expensive-methods", adminOnly, usageHandler.GetExpensiveMethodShares)
+	v1.GET("/admin/costs", adminOnly, usageHandler.GetChainCosts)
+	if unkeyClient != nil {
+		unkeyHandler := handlers.NewUnkeyHandler(unkeyClient)
+		v1.POST("/admin/unkey/keys/:keyId/purge", adminOnly, unkeyHandler.PurgeKey)
+	}
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -0,0 +1,108 @@
+// Command stripe-export reports settled metered usage of Stripe-billed
+// subscriptions to Stripe as usage records.
+//
+//	stripe-export -org <organization id>   export one organization's usage
+//	stripe-export -all                     export every organization's usage
+//	stripe-export -all -dry-run            print the usage records without sending them
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/stripe"
+	"go.uber.org/zap"
+)
+
+func main() {
+	orgID := flag.String("org", "", "organization id to export")
+	all := flag.Bool("all", false, "export every organization billed through Stripe")
+	dryRun := flag.Bool("dry-run", false, "print the usage records instead of sending them")
+	at := flag.String("at", "", "export usage settled by this RFC3339 time instead of now")
+	flag.Parse()
+
+	if (*orgID == "") == !*all {
+		log.Fatal("Exactly one of -org or -all is required")
+	}
+
+	now := time.Now().UTC()
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			log.Fatalf("Invalid -at: %v", err)
+		}
+		now = t
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if !cfg.Stripe.Enabled {
+		log.Fatal("Stripe is disabled; set REPORTING_API_STRIPE_ENABLED=true")
+	}
+
+	logger, err := zap.NewProduction()
+	if cfg.Logging.Format != "json" {
+		logger, err = zap.NewDevelopment()
+	}
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	chRepo, err := repository.NewClickHouseRepository(&cfg.ClickHouse)
+	if err != nil {
+		logger.Fatal("Failed to connect to ClickHouse", zap.Error(err))
+	}
+	defer chRepo.Close()
+
+	pgRepo, err := repository.NewPostgresRepository(&cfg.PostgreSQL)
+	if err != nil {
+		logger.Fatal("Failed to connect to PostgreSQL", zap.Error(err))
+	}
+	defer pgRepo.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	exporter := stripe.NewExporter(chRepo, pgRepo, stripe.NewClient(&cfg.Stripe), &cfg.Stripe, logger)
+	results, err := exporter.Run(ctx, *orgID, now, *dryRun)
+	if err != nil {
+		logger.Fatal("Stripe export failed", zap.Error(err))
+	}
+
+	failed, records, unreported := 0, 0, 0
+	for _, result := range results {
+		records += len(result.Records)
+		unreported += len(result.Unreported)
+		if result.Err != nil {
+			failed++
+			continue
+		}
+		if *dryRun {
+			out, _ := json.MarshalIndent(result, "", "  ")
+			os.Stdout.Write(append(out, '\n'))
+		}
+	}
+
+	logger.Info("Stripe export finished",
+		zap.Int("meters", len(results)-failed),
+		zap.Int("records", records),
+		zap.Int("unreported", unreported),
+		zap.Int("failed", failed),
+		zap.Bool("dry_run", *dryRun),
+	)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,38 @@
+// Command stripe-stub serves an in-memory fake of the Stripe metered billing
+// API for local development. Point REPORTING_API_STRIPE_APIURL at it.
+//
+//	stripe-stub -addr :12111 -item sub_123=price_456
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/stripe/stripestub"
+)
+
+type itemFlags []string
+
+func (f *itemFlags) String() string     { return strings.Join(*f, ",") }
+func (f *itemFlags) Set(v string) error { *f = append(*f, v); return nil }
+
+func main() {
+	addr := flag.String("addr", ":12111", "listen address")
+	var items itemFlags
+	flag.Var(&items, "item", "subscription item to create as <subscription id>=<price id>; repeatable")
+	flag.Parse()
+
+	server := stripestub.NewServer()
+	for _, item := range items {
+		subscriptionID, priceID, ok := strings.Cut(item, "=")
+		if !ok || subscriptionID == "" || priceID == "" {
+			log.Fatalf("Invalid -item %q, expected <subscription id>=<price id>", item)
+		}
+		log.Printf("Created subscription item %s (%s, %s)", server.AddSubscriptionItem(subscriptionID, priceID), subscriptionID, priceID)
+	}
+
+	log.Printf("Stripe stub listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
@@ -0,0 +1,200 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+	"go.uber.org/zap"
+)
+
+// maxCatchUpPeriods bounds how many overdue periods of one subscription a
+// run closes, so a bad period end cannot loop for long
+const maxCatchUpPeriods = 24
+
+// UsageSource reads the usage billing periods are rated on; the ClickHouse
+// repository implements it
+type UsageSource interface {
+	GetBillableUsage(ctx context.Context, orgID string, startDate, endDate time.Time, byMethod bool) ([]models.BillableUsage, error)
+}
+
+// Store holds subscriptions, rating rules and invoices; the Postgres
+// repository implements it
+type Store interface {
+	ListDueSubscriptions(ctx context.Context, orgID string, now time.Time) ([]models.BillableSubscription, error)
+	GetBillableSubscription(ctx context.Context, orgID string) (*models.BillableSubscription, error)
+	GetRatingRules(ctx context.Context, planID string, at time.Time) (*models.RatingRules, error)
+	ListChains(ctx context.Context, includeTestnets bool) ([]models.Chain, error)
+	CloseSubscriptionPeriod(ctx context.Context, sub models.BillableSubscription, invoice *models.Invoice, numberPrefix string, nextStart, nextEnd time.Time) error
+}
+
+// Engine closes subscription billing periods into draft invoices
+type Engine struct {
+	usage  UsageSource
+	store  Store
+	cfg    *config.BillingConfig
+	logger *zap.Logger
+}
+
+func NewEngine(usage UsageSource, store Store, cfg *config.BillingConfig, logger *zap.Logger) *Engine {
+	return &Engine{
+		usage:  usage,
+		store:  store,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// Result is the outcome of closing one billing period
+type Result struct {
+	SubscriptionID string
+	OrganizationID string
+	Invoice        *models.Invoice
+	Err            error
+}
+
+// Run closes the periods of active subscriptions that ended by now, for one
+// organization unless orgID is empty. A subscription several periods behind
+// gets one invoice per period. With dryRun invoices are rated but neither
+// written nor numbered. A failing subscription does not stop the others;
+// its error is in its Result.
+func (e *Engine) Run(ctx context.Context, orgID string, now time.Time, dryRun bool) ([]Result, error) {
+	subs, err := e.store.ListDueSubscriptions(ctx, orgID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	chainNames, err := e.chainNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, sub := range subs {
+		for i := 0; i < maxCatchUpPeriods && !sub.CurrentPeriodEnd.After(now); i++ {
+			result := Result{SubscriptionID: sub.ID, OrganizationID: sub.OrganizationID}
+			nextStart, nextEnd := nextPeriod(sub)
+
+			result.Invoice, result.Err = e.closePeriod(ctx, sub, chainNames, nextStart, nextEnd, dryRun)
+			if errors.Is(result.Err, repository.ErrPeriodClosed) {
+				e.logger.Info("Billing period closed by another run",
+					zap.String("subscription_id", sub.ID),
+					zap.Time("period_start", sub.CurrentPeriodStart),
+				)
+				break
+			}
+			results = append(results, result)
+			if result.Err != nil || sub.CancelAtPeriodEnd {
+				break
+			}
+
+			sub.CurrentPeriodStart, sub.CurrentPeriodEnd = nextStart, nextEnd
+		}
+	}
+
+	return results, nil
+}
+
+// closePeriod rates the subscription's current period with the rating rules
+// effective at its start and, unless dryRun, stores the draft invoice while
+// moving the subscription on
+func (e *Engine) closePeriod(ctx context.Context, sub models.BillableSubscription, chainNames map[string]string, nextStart, nextEnd time.Time, dryRun bool) (*models.Invoice, error) {
+	rules, err := e.periodRules(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+	var ruleID *string
+	if rules != nil {
+		ruleID = &rules.ID
+	}
+
+	usage, err := e.usage.GetBillableUsage(ctx, sub.OrganizationID, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, rules != nil && UsesMethods(rules.Definition))
+	if err != nil {
+		return nil, err
+	}
+
+	lineItems := RateUsage(sub.Pricing, rules, sub.BillingPeriod, usage, chainNames)
+	subtotal := Subtotal(lineItems)
+	dueDate := sub.CurrentPeriodEnd.AddDate(0, 0, e.cfg.PaymentTermsDays)
+	invoice := &models.Invoice{
+		OrganizationID: sub.OrganizationID,
+		SubscriptionID: sub.ID,
+		Subtotal:       subtotal,
+		Total:          subtotal,
+		Currency:       sub.Pricing.Currency,
+		Status:         "draft",
+		PeriodStart:    sub.CurrentPeriodStart,
+		PeriodEnd:      sub.CurrentPeriodEnd,
+		DueDate:        &dueDate,
+		LineItems:      lineItems,
+		RatingRuleID:   ruleID,
+	}
+	if dryRun {
+		return invoice, nil
+	}
+
+	prefix := fmt.Sprintf("%s-%d", e.cfg.InvoicePrefix, sub.CurrentPeriodEnd.UTC().Year())
+	if err := e.store.CloseSubscriptionPeriod(ctx, sub, invoice, prefix, nextStart, nextEnd); err != nil {
+		return nil, err
+	}
+
+	e.logger.Info("Draft invoice created",
+		zap.String("invoice_number", invoice.InvoiceNumber),
+		zap.String("organization_id", sub.OrganizationID),
+		zap.Float64("total", invoice.Total),
+		zap.String("currency", invoice.Currency),
+	)
+
+	return invoice, nil
+}
+
+// periodRules returns the validated rating rules effective at the start of
+// the subscription's current period, or nil when its plan has none
+func (e *Engine) periodRules(ctx context.Context, sub models.BillableSubscription) (*models.RatingRules, error) {
+	rules, err := e.store.GetRatingRules(ctx, sub.Pricing.PlanID, sub.CurrentPeriodStart)
+	if err != nil || rules == nil {
+		return nil, err
+	}
+	if err := ValidateRules(rules.Definition); err != nil {
+		return nil, fmt.Errorf("invalid rating rules %s version %d: %w", sub.Pricing.PlanSlug, rules.Version, err)
+	}
+	return rules, nil
+}
+
+// chainNames maps chain slugs to display names for line item descriptions
+func (e *Engine) chainNames(ctx context.Context) (map[string]string, error) {
+	chains, err := e.store.ListChains(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(chains))
+	for _, chain := range chains {
+		names[chain.Slug] = chain.DisplayName
+	}
+	return names, nil
+}
+
+// nextPeriod returns the billing period following the current one
+func nextPeriod(sub models.BillableSubscription) (time.Time, time.Time) {
+	months := 1
+	if sub.BillingPeriod == "yearly" {
+		months = 12
+	}
+	return sub.CurrentPeriodEnd, addMonths(sub.CurrentPeriodEnd, months)
+}
+
+// addMonths adds months to t, clamping the day to the end of the target
+// month instead of overflowing into the next (Jan 31 + 1 month is Feb 28)
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
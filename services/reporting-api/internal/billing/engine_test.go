@@ -0,0 +1,169 @@
+package billing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"go.uber.org/zap"
+)
+
+type usageQuery struct {
+	start, end time.Time
+	byMethod   bool
+}
+
+// fakeUsage returns rows by method or by chain like GetBillableUsage, and
+// records what it was asked for
+type fakeUsage struct {
+	byMethod []models.BillableUsage
+	queries  []usageQuery
+}
+
+func (u *fakeUsage) GetBillableUsage(_ context.Context, _ string, startDate, endDate time.Time, byMethod bool) ([]models.BillableUsage, error) {
+	u.queries = append(u.queries, usageQuery{startDate, endDate, byMethod})
+	if byMethod {
+		return u.byMethod, nil
+	}
+
+	var byChain []models.BillableUsage
+	for _, row := range u.byMethod {
+		last := len(byChain) - 1
+		if last < 0 || byChain[last].ChainSlug != row.ChainSlug {
+			byChain = append(byChain, models.BillableUsage{ChainSlug: row.ChainSlug})
+			last++
+		}
+		byChain[last].Requests += row.Requests
+		byChain[last].ComputeUnits += row.ComputeUnits
+		byChain[last].EgressBytes += row.EgressBytes
+	}
+	return byChain, nil
+}
+
+type fakeStore struct {
+	subs     []models.BillableSubscription
+	rules    *models.RatingRules
+	invoices []*models.Invoice
+}
+
+func (s *fakeStore) ListDueSubscriptions(_ context.Context, _ string, now time.Time) ([]models.BillableSubscription, error) {
+	var due []models.BillableSubscription
+	for _, sub := range s.subs {
+		if !sub.CurrentPeriodEnd.After(now) {
+			due = append(due, sub)
+		}
+	}
+	return due, nil
+}
+
+func (s *fakeStore) GetBillableSubscription(context.Context, string) (*models.BillableSubscription, error) {
+	if len(s.subs) == 0 {
+		return nil, nil
+	}
+	return &s.subs[0], nil
+}
+
+func (s *fakeStore) GetRatingRules(context.Context, string, time.Time) (*models.RatingRules, error) {
+	return s.rules, nil
+}
+
+func (s *fakeStore) ListChains(context.Context, bool) ([]models.Chain, error) {
+	return []models.Chain{{Slug: "eth", DisplayName: "Ethereum"}}, nil
+}
+
+func (s *fakeStore) CloseSubscriptionPeriod(_ context.Context, sub models.BillableSubscription, invoice *models.Invoice, _ string, nextStart, nextEnd time.Time) error {
+	s.invoices = append(s.invoices, invoice)
+	for i := range s.subs {
+		if s.subs[i].ID == sub.ID {
+			s.subs[i].CurrentPeriodStart, s.subs[i].CurrentPeriodEnd = nextStart, nextEnd
+		}
+	}
+	return nil
+}
+
+// yearlyMethodFixture is a yearly subscription whose rating rules weigh a
+// trace method 5 times, with 12M compute units included over the year
+func yearlyMethodFixture(periodStart time.Time) (*fakeUsage, *fakeStore) {
+	usage := &fakeUsage{byMethod: []models.BillableUsage{
+		{ChainSlug: "eth", Method: "debug_traceTransaction", ComputeUnits: 2e6},
+		{ChainSlug: "eth", Method: "eth_call", ComputeUnits: 10e6},
+	}}
+	store := &fakeStore{
+		subs: []models.BillableSubscription{{
+			ID:                 "sub-1",
+			OrganizationID:     "org-1",
+			BillingPeriod:      "yearly",
+			CurrentPeriodStart: periodStart,
+			CurrentPeriodEnd:   addMonths(periodStart, 12),
+			Pricing:            models.PlanPricing{PlanID: "plan-1", PlanSlug: "pro", PlanName: "Pro", PriceYearly: 490, Currency: "USD"},
+		}},
+		rules: &models.RatingRules{ID: "rules-1", Version: 1, Definition: models.RatingDefinition{Meters: []models.MeterRule{{
+			Meter:              MeterComputeUnits,
+			UnitSize:           1e6,
+			Included:           1e6,
+			Mode:               ModeGraduated,
+			Tiers:              []models.RatingTier{{UnitPrice: 1}},
+			MethodCoefficients: map[string]float64{"debug_traceTransaction": 5},
+		}}}},
+	}
+	return usage, store
+}
+
+func TestEngineRunYearlyMethodCoefficients(t *testing.T) {
+	periodStart := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	usage, store := yearlyMethodFixture(periodStart)
+	engine := NewEngine(usage, store, &config.BillingConfig{InvoicePrefix: "INV", PaymentTermsDays: 14}, zap.NewNop())
+
+	results, err := engine.Run(context.Background(), "", now, false)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("results = %+v", results)
+	}
+
+	// The whole year is read by method, though it started over 90 days ago
+	want := usageQuery{periodStart, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), true}
+	if len(usage.queries) != 1 || usage.queries[0] != want {
+		t.Errorf("usage queries = %+v, want %+v", usage.queries, want)
+	}
+
+	// 10M + 5 × 2M weighted units, 12M of them included over the year
+	invoice := results[0].Invoice
+	if invoice.Subtotal != 498 {
+		t.Errorf("subtotal = %v, want 490 base fee + 8 usage; items %+v", invoice.Subtotal, invoice.LineItems)
+	}
+	if invoice.RatingRuleID == nil || *invoice.RatingRuleID != "rules-1" {
+		t.Errorf("rating rule id = %v", invoice.RatingRuleID)
+	}
+	if len(store.invoices) != 1 || !store.subs[0].CurrentPeriodStart.Equal(want.end) {
+		t.Errorf("period not closed: %d invoices, subscription %+v", len(store.invoices), store.subs[0])
+	}
+}
+
+func TestEngineEstimateYearlyMethodCoefficients(t *testing.T) {
+	periodStart := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	usage, store := yearlyMethodFixture(periodStart)
+	engine := NewEngine(usage, store, &config.BillingConfig{InvoicePrefix: "INV"}, zap.NewNop())
+
+	estimate, err := engine.Estimate(context.Background(), "org-1", now)
+	if err != nil {
+		t.Fatalf("Estimate: %v", err)
+	}
+
+	for _, q := range usage.queries {
+		if !q.byMethod {
+			t.Errorf("usage read without methods: %+v", q)
+		}
+	}
+	if got := usage.queries[0].start; !got.Equal(periodStart) {
+		t.Errorf("to-date usage starts %v, want the period start", got)
+	}
+	if estimate.ToDate.Total != 498 || estimate.RatingRuleVersion == nil {
+		t.Errorf("to date = %+v", estimate.ToDate)
+	}
+}
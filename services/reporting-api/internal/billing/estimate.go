@@ -0,0 +1,164 @@
+package billing
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+const (
+	// runRateDays is the trailing window of the run-rate projection
+	runRateDays = 7
+
+	// minEstimateElapsed keeps the linear projection from scaling the first
+	// minutes of a period into absurd totals
+	minEstimateElapsed = time.Hour
+)
+
+// usageKey identifies a usage row by chain and method
+type usageKey struct {
+	chain  string
+	method string
+}
+
+// Estimate rates an organization's current billing period as of now, the
+// way the period's invoice will be rated. Like invoices it counts whole UTC
+// days from the day the period starts; the current day is included so far.
+// It returns nil when the organization has no active subscription.
+func (e *Engine) Estimate(ctx context.Context, orgID string, now time.Time) (*models.BillingEstimate, error) {
+	sub, err := e.store.GetBillableSubscription(ctx, orgID)
+	if err != nil || sub == nil {
+		return nil, err
+	}
+
+	rules, err := e.periodRules(ctx, *sub)
+	if err != nil {
+		return nil, err
+	}
+	byMethod := rules != nil && UsesMethods(rules.Definition)
+
+	chainNames, err := e.chainNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now = now.UTC()
+	today := truncateDay(now)
+	tomorrow := today.AddDate(0, 0, 1)
+	periodStart := truncateDay(sub.CurrentPeriodStart.UTC())
+	total := truncateDay(sub.CurrentPeriodEnd.UTC()).Sub(periodStart)
+	if total < 24*time.Hour {
+		total = 24 * time.Hour
+	}
+	elapsed := now.Sub(periodStart)
+	if elapsed < minEstimateElapsed {
+		elapsed = minEstimateElapsed
+	}
+	if elapsed > total {
+		elapsed = total
+	}
+
+	toDate, err := e.usage.GetBillableUsage(ctx, orgID, sub.CurrentPeriodStart, tomorrow, byMethod)
+	if err != nil {
+		return nil, err
+	}
+	recent, err := e.usage.GetBillableUsage(ctx, orgID, today.AddDate(0, 0, -runRateDays), today, byMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	linear := scaleUsage(toDate, float64(total)/float64(elapsed))
+	remainingDays := float64(total-elapsed) / float64(24*time.Hour)
+	trailing := addUsage(toDate, scaleUsage(recent, remainingDays/runRateDays))
+
+	estimate := &models.BillingEstimate{
+		OrganizationID: orgID,
+		SubscriptionID: sub.ID,
+		PlanSlug:       sub.Pricing.PlanSlug,
+		BillingPeriod:  sub.BillingPeriod,
+		Currency:       sub.Pricing.Currency,
+		PeriodStart:    sub.CurrentPeriodStart,
+		PeriodEnd:      sub.CurrentPeriodEnd,
+		AsOf:           now,
+		ElapsedPct:     math.Round(float64(elapsed)/float64(total)*1e4) / 100,
+		ToDate:         costProjection(*sub, rules, toDate, chainNames),
+		Linear:         costProjection(*sub, rules, linear, chainNames),
+		Trailing7Day:   costProjection(*sub, rules, trailing, chainNames),
+	}
+	if rules != nil {
+		estimate.RatingRuleVersion = &rules.Version
+	}
+
+	return estimate, nil
+}
+
+// costProjection rates usage as a whole billing period of sub
+func costProjection(sub models.BillableSubscription, rules *models.RatingRules, usage []models.BillableUsage, chainNames map[string]string) models.CostProjection {
+	items := RateUsage(sub.Pricing, rules, sub.BillingPeriod, usage, chainNames)
+	projection := models.CostProjection{
+		BaseFee:   items[0].Amount,
+		ByMeter:   make(map[string]float64),
+		LineItems: items,
+		Total:     Subtotal(items),
+	}
+	projection.UsageCharges = roundCents(projection.Total - projection.BaseFee)
+
+	for _, item := range items[1:] {
+		if item.Meter != "" {
+			projection.ByMeter[item.Meter] = roundCents(projection.ByMeter[item.Meter] + item.Amount)
+		}
+	}
+
+	var egressBytes uint64
+	for _, u := range usage {
+		projection.Requests += u.Requests
+		projection.ComputeUnits += u.ComputeUnits
+		egressBytes += u.EgressBytes
+	}
+	projection.EgressGB = math.Round(float64(egressBytes)/bytesPerGB*1e4) / 1e4
+
+	return projection
+}
+
+// scaleUsage multiplies every usage row by factor
+func scaleUsage(usage []models.BillableUsage, factor float64) []models.BillableUsage {
+	scaled := make([]models.BillableUsage, len(usage))
+	for i, u := range usage {
+		scaled[i] = models.BillableUsage{
+			ChainSlug:    u.ChainSlug,
+			Method:       u.Method,
+			Requests:     uint64(math.Round(float64(u.Requests) * factor)),
+			ComputeUnits: uint64(math.Round(float64(u.ComputeUnits) * factor)),
+			EgressBytes:  uint64(math.Round(float64(u.EgressBytes) * factor)),
+		}
+	}
+	return scaled
+}
+
+// addUsage sums two sets of usage rows by chain and method, keeping the
+// order of a followed by the rows only b has
+func addUsage(a, b []models.BillableUsage) []models.BillableUsage {
+	sum := append([]models.BillableUsage{}, a...)
+	index := make(map[usageKey]int, len(sum))
+	for i, u := range sum {
+		index[usageKey{u.ChainSlug, u.Method}] = i
+	}
+	for _, u := range b {
+		i, ok := index[usageKey{u.ChainSlug, u.Method}]
+		if !ok {
+			index[usageKey{u.ChainSlug, u.Method}] = len(sum)
+			sum = append(sum, u)
+			continue
+		}
+		sum[i].Requests += u.Requests
+		sum[i].ComputeUnits += u.ComputeUnits
+		sum[i].EgressBytes += u.EgressBytes
+	}
+	return sum
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,92 @@
+package billing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+	"go.uber.org/zap"
+)
+
+// PriceSync periodically copies the Postgres chain_prices into ClickHouse,
+// where they price chain_org_usage at ingest. Several replicas may run it:
+// each sync writes the full price list.
+type PriceSync struct {
+	clickhouseRepo *repository.ClickHouseRepository
+	postgresRepo   *repository.PostgresRepository
+	interval       time.Duration
+	logger         *zap.Logger
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func NewPriceSync(ch *repository.ClickHouseRepository, pg *repository.PostgresRepository, cfg *config.BillingConfig, logger *zap.Logger) *PriceSync {
+	return &PriceSync{
+		clickhouseRepo: ch,
+		postgresRepo:   pg,
+		interval:       time.Duration(cfg.PriceSyncInterval) * time.Second,
+		logger:         logger,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+}
+
+// Start syncs every PriceSyncInterval seconds until Close
+func (s *PriceSync) Start() {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.runSync()
+
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the sync and waits for a running sync to finish
+func (s *PriceSync) Close() {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+func (s *PriceSync) runSync() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := s.Sync(ctx); err != nil {
+		// Ingest keeps pricing with the last synced prices
+		s.logger.Warn("Chain price sync failed", zap.Error(err))
+	}
+}
+
+// Sync copies the current chain prices once. An empty price list is not
+// synced, so a Postgres that lost its prices does not zero ingest pricing.
+func (s *PriceSync) Sync(ctx context.Context) error {
+	prices, err := s.postgresRepo.GetChainPrices(ctx)
+	if err != nil {
+		return err
+	}
+	if len(prices) == 0 {
+		s.logger.Warn("No chain prices to sync; ClickHouse keeps the last synced prices")
+		return nil
+	}
+
+	if err := s.clickhouseRepo.SyncChainPrices(ctx, prices, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	s.logger.Debug("Chain prices synced", zap.Int("prices", len(prices)))
+	return nil
+}
@@ -0,0 +1,136 @@
+package billing
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// Meters of invoice line items
+const (
+	MeterBaseFee      = "base_fee"
+	MeterComputeUnits = "compute_units"
+	MeterRequests     = "requests"
+	MeterEgressGB     = "egress_gb"
+)
+
+const bytesPerGB = 1024 * 1024 * 1024
+
+// meterKind describes a usage meter. usage returns a usage row in the
+// meter's own units; noun names those units on invoices.
+type meterKind struct {
+	name  string
+	label string
+	noun  string
+	usage func(models.BillableUsage) float64
+}
+
+var meterKinds = map[string]meterKind{
+	MeterComputeUnits: {
+		name:  "Compute units",
+		label: "compute unit overage",
+		noun:  "compute units",
+		usage: func(u models.BillableUsage) float64 { return float64(u.ComputeUnits) },
+	},
+	MeterRequests: {
+		name:  "Requests",
+		label: "request overage",
+		noun:  "requests",
+		usage: func(u models.BillableUsage) float64 { return float64(u.Requests) },
+	},
+	MeterEgressGB: {
+		name:  "Egress",
+		label: "egress overage",
+		noun:  "GB",
+		usage: func(u models.BillableUsage) float64 { return float64(u.EgressBytes) / bytesPerGB },
+	},
+}
+
+// PlanRules returns the rating rules equivalent to a plan's allowance and
+// overage columns, for plans without rating_rules: one flat tier per meter
+// with a rate, and no coefficients
+func PlanRules(pricing models.PlanPricing) models.RatingDefinition {
+	var definition models.RatingDefinition
+	add := func(meter string, unitSize, included, rate float64) {
+		if rate <= 0 {
+			return
+		}
+		definition.Meters = append(definition.Meters, models.MeterRule{
+			Meter:    meter,
+			UnitSize: unitSize,
+			Included: included,
+			Mode:     ModeGraduated,
+			Tiers:    []models.RatingTier{{UnitPrice: rate}},
+		})
+	}
+	add(MeterComputeUnits, 1e6, float64(pricing.IncludedComputeUnits), pricing.OveragePerMillionComputeUnits)
+	add(MeterRequests, 1e6, float64(pricing.IncludedRequests), pricing.OveragePerMillionRequests)
+	add(MeterEgressGB, 1, pricing.IncludedEgressGB, pricing.OveragePerGB)
+	return definition
+}
+
+// RateUsage prices a billing period: the plan's base fee, then its usage
+// under rules, or under the plan's overage columns when rules is nil.
+// chainNames maps slugs to display names for descriptions.
+func RateUsage(pricing models.PlanPricing, rules *models.RatingRules, billingPeriod string, usage []models.BillableUsage, chainNames map[string]string) []models.InvoiceLineItem {
+	months, baseFee, period := 1.0, pricing.PriceMonthly, "monthly"
+	if billingPeriod == "yearly" {
+		months, baseFee, period = 12, pricing.PriceYearly, "yearly"
+	}
+
+	items := []models.InvoiceLineItem{{
+		Description: fmt.Sprintf("%s plan (%s)", pricing.PlanName, period),
+		Meter:       MeterBaseFee,
+		Quantity:    1,
+		UnitPrice:   baseFee,
+		Amount:      roundCents(baseFee),
+	}}
+
+	definition := PlanRules(pricing)
+	if rules != nil {
+		definition = rules.Definition
+	}
+
+	return append(items, Rate(usage, ScaleRules(definition, months), chainNames)...)
+}
+
+// Subtotal adds up line item amounts in cents to avoid float drift
+func Subtotal(items []models.InvoiceLineItem) float64 {
+	var cents int64
+	for _, item := range items {
+		cents += int64(math.Round(item.Amount * 100))
+	}
+	return float64(cents) / 100
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// splitCents distributes total cents by shares using the largest remainder
+// method, so the parts always add up to total
+func splitCents(total int64, shares []float64) []int64 {
+	parts := make([]int64, len(shares))
+	remainders := make([]int, len(shares))
+	allocated := int64(0)
+	for i, share := range shares {
+		exact := float64(total) * share
+		parts[i] = int64(math.Floor(exact))
+		allocated += parts[i]
+		remainders[i] = i
+	}
+
+	sort.SliceStable(remainders, func(a, b int) bool {
+		ea := float64(total)*shares[remainders[a]] - float64(parts[remainders[a]])
+		eb := float64(total)*shares[remainders[b]] - float64(parts[remainders[b]])
+		return ea > eb
+	})
+	for i := 0; allocated < total && len(remainders) > 0; i = (i + 1) % len(remainders) {
+		parts[remainders[i]]++
+		allocated++
+	}
+
+	return parts
+}
@@ -0,0 +1,102 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+func TestSplitCents(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  int64
+		shares []float64
+		want   []int64
+	}{
+		{"single share", 1999, []float64{1}, []int64{1999}},
+		{"even split", 100, []float64{0.5, 0.5}, []int64{50, 50}},
+		{"thirds give the extra cent to the first", 100, []float64{1.0 / 3, 1.0 / 3, 1.0 / 3}, []int64{34, 33, 33}},
+		{"largest remainder wins", 7, []float64{0.1, 0.6, 0.3}, []int64{1, 4, 2}},
+		{"two leftover cents", 11, []float64{0.25, 0.25, 0.25, 0.25}, []int64{3, 3, 3, 2}},
+		{"tied halves", 3, []float64{0.5, 0.5, 0}, []int64{2, 1, 0}},
+		{"zero share gets nothing", 10, []float64{0.7, 0, 0.3}, []int64{7, 0, 3}},
+		{"zero total", 0, []float64{0.4, 0.6}, []int64{0, 0}},
+		{"tiny shares", 1, []float64{0.001, 0.998, 0.001}, []int64{0, 1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitCents(tt.total, tt.shares)
+			var sum int64
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("splitCents(%d, %v) = %v, want %v", tt.total, tt.shares, got, tt.want)
+					break
+				}
+			}
+			for _, part := range got {
+				sum += part
+			}
+			if sum != tt.total {
+				t.Errorf("parts add up to %d, want %d", sum, tt.total)
+			}
+		})
+	}
+}
+
+func TestRateUsageScalesPlanAllowances(t *testing.T) {
+	pricing := models.PlanPricing{
+		PlanName:                      "Pro",
+		PriceMonthly:                  49,
+		PriceYearly:                   490,
+		IncludedComputeUnits:          1e6,
+		OveragePerMillionComputeUnits: 2,
+	}
+	usage := []models.BillableUsage{{ChainSlug: "eth", ComputeUnits: 13e6}}
+
+	tests := []struct {
+		name          string
+		billingPeriod string
+		wantBase      float64
+		wantOverage   float64
+	}{
+		// 12M over the monthly allowance at 2 per 1M
+		{"monthly", "monthly", 49, 24},
+		// The yearly allowance is 12M
+		{"yearly", "yearly", 490, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := RateUsage(pricing, nil, tt.billingPeriod, usage, nil)
+			if len(items) != 2 {
+				t.Fatalf("items = %+v", items)
+			}
+			if items[0].Meter != MeterBaseFee || items[0].Amount != tt.wantBase {
+				t.Errorf("base fee = %+v, want %v", items[0], tt.wantBase)
+			}
+			if items[1].Meter != MeterComputeUnits || items[1].Amount != tt.wantOverage {
+				t.Errorf("overage = %+v, want %v", items[1], tt.wantOverage)
+			}
+		})
+	}
+}
+
+func TestRateUsagePrefersRatingRules(t *testing.T) {
+	pricing := models.PlanPricing{PlanName: "Pro", PriceMonthly: 49, OveragePerMillionRequests: 100}
+	rules := &models.RatingRules{Definition: models.RatingDefinition{Meters: []models.MeterRule{{
+		Meter:    MeterRequests,
+		UnitSize: 1e6,
+		Mode:     ModeGraduated,
+		Tiers:    []models.RatingTier{{UnitPrice: 1}},
+	}}}}
+	usage := []models.BillableUsage{{ChainSlug: "eth", Requests: 3e6}}
+
+	items := RateUsage(pricing, rules, "monthly", usage, nil)
+	if len(items) != 2 || items[1].Amount != 3 {
+		t.Fatalf("items = %+v", items)
+	}
+	if got := Subtotal(items); got != 52 {
+		t.Errorf("Subtotal = %v, want 52", got)
+	}
+}
@@ -0,0 +1,299 @@
+package billing
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// Tier modes of a meter rule
+const (
+	// ModeGraduated prices each tier's units at that tier's price
+	ModeGraduated = "graduated"
+	// ModeVolume prices all units at the price of the tier the total reaches
+	ModeVolume = "volume"
+)
+
+// ValidateRules checks a rating definition before it is used to rate usage
+func ValidateRules(definition models.RatingDefinition) error {
+	if definition.MinimumCommit < 0 || definition.MaximumCharge < 0 {
+		return fmt.Errorf("minimum_commit and maximum_charge cannot be negative")
+	}
+	if definition.MaximumCharge > 0 && definition.MaximumCharge < definition.MinimumCommit {
+		return fmt.Errorf("maximum_charge is below minimum_commit")
+	}
+
+	seen := make(map[string]bool, len(definition.Meters))
+	for _, rule := range definition.Meters {
+		if _, ok := meterKinds[rule.Meter]; !ok {
+			return fmt.Errorf("invalid meter: %q", rule.Meter)
+		}
+		if seen[rule.Meter] {
+			return fmt.Errorf("meter %s has more than one rule", rule.Meter)
+		}
+		seen[rule.Meter] = true
+
+		if rule.UnitSize <= 0 {
+			return fmt.Errorf("meter %s: unit_size must be positive", rule.Meter)
+		}
+		if rule.Included < 0 {
+			return fmt.Errorf("meter %s: included cannot be negative", rule.Meter)
+		}
+		if rule.Mode != ModeGraduated && rule.Mode != ModeVolume {
+			return fmt.Errorf("meter %s: mode must be %s or %s", rule.Meter, ModeGraduated, ModeVolume)
+		}
+		if rule.MinimumCharge < 0 || rule.MaximumCharge < 0 {
+			return fmt.Errorf("meter %s: minimum_charge and maximum_charge cannot be negative", rule.Meter)
+		}
+		if rule.MaximumCharge > 0 && rule.MaximumCharge < rule.MinimumCharge {
+			return fmt.Errorf("meter %s: maximum_charge is below minimum_charge", rule.Meter)
+		}
+
+		if len(rule.Tiers) == 0 {
+			return fmt.Errorf("meter %s needs at least one tier", rule.Meter)
+		}
+		lower := 0.0
+		for i, tier := range rule.Tiers {
+			if tier.UnitPrice < 0 || tier.FlatFee < 0 {
+				return fmt.Errorf("meter %s: tier %d has a negative price", rule.Meter, i+1)
+			}
+			last := i == len(rule.Tiers)-1
+			if tier.UpTo == nil {
+				if !last {
+					return fmt.Errorf("meter %s: only the last tier can be unbounded", rule.Meter)
+				}
+				continue
+			}
+			if last {
+				return fmt.Errorf("meter %s: the last tier cannot have up_to", rule.Meter)
+			}
+			if *tier.UpTo <= lower {
+				return fmt.Errorf("meter %s: tier up_to values must be positive and ascending", rule.Meter)
+			}
+			lower = *tier.UpTo
+		}
+
+		for chain, coefficient := range rule.ChainCoefficients {
+			if coefficient < 0 {
+				return fmt.Errorf("meter %s: chain coefficient of %s cannot be negative", rule.Meter, chain)
+			}
+		}
+		for method, coefficient := range rule.MethodCoefficients {
+			if coefficient < 0 {
+				return fmt.Errorf("meter %s: method coefficient of %s cannot be negative", rule.Meter, method)
+			}
+		}
+	}
+
+	return nil
+}
+
+// UsesMethods reports whether a definition weighs usage by RPC method, so
+// it must be rated from usage broken down by method
+func UsesMethods(definition models.RatingDefinition) bool {
+	for _, rule := range definition.Meters {
+		if len(rule.MethodCoefficients) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ScaleRules returns definition for a billing period of months: included
+// units, tier bounds, minimums and caps are multiplied, prices are not
+func ScaleRules(definition models.RatingDefinition, months float64) models.RatingDefinition {
+	scaled := models.RatingDefinition{
+		MinimumCommit: definition.MinimumCommit * months,
+		MaximumCharge: definition.MaximumCharge * months,
+		Meters:        make([]models.MeterRule, len(definition.Meters)),
+	}
+	for i, rule := range definition.Meters {
+		rule.Included *= months
+		rule.MinimumCharge *= months
+		rule.MaximumCharge *= months
+
+		tiers := make([]models.RatingTier, len(rule.Tiers))
+		for j, tier := range rule.Tiers {
+			if tier.UpTo != nil {
+				upTo := *tier.UpTo * months
+				tier.UpTo = &upTo
+			}
+			tiers[j] = tier
+		}
+		rule.Tiers = tiers
+		scaled.Meters[i] = rule
+	}
+	return scaled
+}
+
+// Rate prices usage under a rating definition, which must have passed
+// ValidateRules. For each meter, usage is weighted by its chain and method
+// coefficients, the included units are taken off the total and the rest is
+// priced by the tiers. The meter's charge is split across chains by their
+// share of the weighted usage, one line per chain, with the cents
+// distributed so the lines add up to the charge; a graduated meter's unit
+// price is the average over its tiers. Meter minimums and caps, then the
+// minimum commit and cap of all usage charges, are separate adjustment
+// lines. chainNames maps slugs to display names for descriptions.
+func Rate(usage []models.BillableUsage, definition models.RatingDefinition, chainNames map[string]string) []models.InvoiceLineItem {
+	var items []models.InvoiceLineItem
+	var usageCents int64
+
+	// Chains in order of first appearance, so lines follow the usage order
+	var chains []string
+	chainIndex := make(map[string]int)
+	for _, u := range usage {
+		if _, ok := chainIndex[u.ChainSlug]; !ok {
+			chainIndex[u.ChainSlug] = len(chains)
+			chains = append(chains, u.ChainSlug)
+		}
+	}
+
+	for _, rule := range definition.Meters {
+		kind := meterKinds[rule.Meter]
+
+		weighted := make([]float64, len(chains))
+		var total float64
+		for _, u := range usage {
+			quantity := kind.usage(u) * coefficient(rule.ChainCoefficients, u.ChainSlug) * coefficient(rule.MethodCoefficients, u.Method)
+			weighted[chainIndex[u.ChainSlug]] += quantity
+			total += quantity
+		}
+
+		billable := total - rule.Included
+		var meterCents int64
+		if billable > 0 {
+			meterCents = int64(math.Round(tierCharge(billable, rule) * 100))
+		}
+
+		if meterCents > 0 {
+			shares := make([]float64, len(chains))
+			for i := range chains {
+				shares[i] = weighted[i] / total
+			}
+			units := billable / rule.UnitSize
+			unitPrice := math.Round(float64(meterCents)/100/units*1e6) / 1e6
+
+			for i, cents := range splitCents(meterCents, shares) {
+				if cents == 0 {
+					continue
+				}
+				items = append(items, models.InvoiceLineItem{
+					Description: fmt.Sprintf("%s - %s", chainName(chainNames, chains[i]), kind.label),
+					Meter:       rule.Meter,
+					ChainSlug:   chains[i],
+					Quantity:    math.Round(units*shares[i]*1e4) / 1e4,
+					Unit:        unitLabel(rule.UnitSize, kind.noun),
+					UnitPrice:   unitPrice,
+					Amount:      float64(cents) / 100,
+				})
+			}
+		}
+
+		adjustment := chargeAdjustment(meterCents, rule.MinimumCharge, rule.MaximumCharge)
+		switch {
+		case adjustment > 0:
+			items = append(items, models.InvoiceLineItem{
+				Description: kind.name + " minimum charge",
+				Meter:       rule.Meter,
+				Amount:      float64(adjustment) / 100,
+			})
+		case adjustment < 0:
+			items = append(items, models.InvoiceLineItem{
+				Description: kind.name + " charge cap",
+				Meter:       rule.Meter,
+				Amount:      float64(adjustment) / 100,
+			})
+		}
+		usageCents += meterCents + adjustment
+	}
+
+	adjustment := chargeAdjustment(usageCents, definition.MinimumCommit, definition.MaximumCharge)
+	switch {
+	case adjustment > 0:
+		items = append(items, models.InvoiceLineItem{
+			Description: "Minimum usage commitment",
+			Amount:      float64(adjustment) / 100,
+		})
+	case adjustment < 0:
+		items = append(items, models.InvoiceLineItem{
+			Description: "Usage charge cap",
+			Amount:      float64(adjustment) / 100,
+		})
+	}
+
+	return items
+}
+
+// tierCharge prices billable units, counted after the included units, by
+// the rule's tiers
+func tierCharge(billable float64, rule models.MeterRule) float64 {
+	if rule.Mode == ModeVolume {
+		for _, tier := range rule.Tiers {
+			if tier.UpTo == nil || billable <= *tier.UpTo {
+				return billable/rule.UnitSize*tier.UnitPrice + tier.FlatFee
+			}
+		}
+		return 0
+	}
+
+	var charge, lower float64
+	for _, tier := range rule.Tiers {
+		if billable <= lower {
+			break
+		}
+		upper := billable
+		if tier.UpTo != nil && *tier.UpTo < billable {
+			upper = *tier.UpTo
+		}
+		charge += (upper-lower)/rule.UnitSize*tier.UnitPrice + tier.FlatFee
+		if tier.UpTo == nil {
+			break
+		}
+		lower = *tier.UpTo
+	}
+	return charge
+}
+
+// chargeAdjustment returns the cents that bring charge up to minimum or down
+// to maximum; a zero maximum is no cap
+func chargeAdjustment(charge int64, minimum, maximum float64) int64 {
+	minCents := int64(math.Round(minimum * 100))
+	maxCents := int64(math.Round(maximum * 100))
+	switch {
+	case charge < minCents:
+		return minCents - charge
+	case maxCents > 0 && charge > maxCents:
+		return maxCents - charge
+	}
+	return 0
+}
+
+// coefficient returns key's coefficient, 1 when it has none
+func coefficient(coefficients map[string]float64, key string) float64 {
+	if c, ok := coefficients[key]; ok {
+		return c
+	}
+	return 1
+}
+
+func chainName(chainNames map[string]string, slug string) string {
+	if name := chainNames[slug]; name != "" {
+		return name
+	}
+	return slug
+}
+
+// unitLabel names what one unit price buys, e.g. 1M compute units
+func unitLabel(unitSize float64, noun string) string {
+	switch {
+	case unitSize == 1:
+		return noun
+	case unitSize >= 1e6 && math.Mod(unitSize, 1e6) == 0:
+		return fmt.Sprintf("%gM %s", unitSize/1e6, noun)
+	case unitSize >= 1e3 && math.Mod(unitSize, 1e3) == 0:
+		return fmt.Sprintf("%gK %s", unitSize/1e3, noun)
+	}
+	return fmt.Sprintf("%g %s", unitSize, noun)
+}
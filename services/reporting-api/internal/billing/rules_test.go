@@ -0,0 +1,337 @@
+package billing
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+func upTo(v float64) *float64 {
+	return &v
+}
+
+// testTiers prices the first 100 units at 1, the next 100 at 0.5 and the
+// rest at 0.25
+func testTiers() []models.RatingTier {
+	return []models.RatingTier{
+		{UpTo: upTo(100), UnitPrice: 1},
+		{UpTo: upTo(200), UnitPrice: 0.5},
+		{UnitPrice: 0.25},
+	}
+}
+
+func validRule() models.MeterRule {
+	return models.MeterRule{
+		Meter:    MeterComputeUnits,
+		UnitSize: 1e6,
+		Mode:     ModeGraduated,
+		Tiers:    testTiers(),
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	withRule := func(mutate func(*models.MeterRule)) models.RatingDefinition {
+		rule := validRule()
+		mutate(&rule)
+		return models.RatingDefinition{Meters: []models.MeterRule{rule}}
+	}
+
+	tests := []struct {
+		name       string
+		definition models.RatingDefinition
+		wantErr    string
+	}{
+		{"valid", withRule(func(*models.MeterRule) {}), ""},
+		{"volume", withRule(func(r *models.MeterRule) { r.Mode = ModeVolume }), ""},
+		{"single unbounded tier", withRule(func(r *models.MeterRule) { r.Tiers = []models.RatingTier{{UnitPrice: 2}} }), ""},
+		{"no meters", models.RatingDefinition{}, ""},
+		{"negative minimum commit", models.RatingDefinition{MinimumCommit: -1}, "cannot be negative"},
+		{"cap below commit", models.RatingDefinition{MinimumCommit: 100, MaximumCharge: 50}, "below minimum_commit"},
+		{"unknown meter", withRule(func(r *models.MeterRule) { r.Meter = "storage" }), "invalid meter"},
+		{"duplicate meter", models.RatingDefinition{Meters: []models.MeterRule{validRule(), validRule()}}, "more than one rule"},
+		{"zero unit size", withRule(func(r *models.MeterRule) { r.UnitSize = 0 }), "unit_size"},
+		{"negative included", withRule(func(r *models.MeterRule) { r.Included = -1 }), "included"},
+		{"unknown mode", withRule(func(r *models.MeterRule) { r.Mode = "stairstep" }), "mode"},
+		{"meter cap below minimum", withRule(func(r *models.MeterRule) { r.MinimumCharge, r.MaximumCharge = 10, 5 }), "below minimum_charge"},
+		{"no tiers", withRule(func(r *models.MeterRule) { r.Tiers = nil }), "at least one tier"},
+		{"negative price", withRule(func(r *models.MeterRule) { r.Tiers[1].UnitPrice = -0.5 }), "negative price"},
+		{"negative flat fee", withRule(func(r *models.MeterRule) { r.Tiers[0].FlatFee = -1 }), "negative price"},
+		{"unbounded middle tier", withRule(func(r *models.MeterRule) { r.Tiers[1].UpTo = nil }), "only the last tier"},
+		{"bounded last tier", withRule(func(r *models.MeterRule) { r.Tiers[2].UpTo = upTo(300) }), "last tier cannot"},
+		{"descending bounds", withRule(func(r *models.MeterRule) { r.Tiers[1].UpTo = upTo(50) }), "ascending"},
+		{"equal bounds", withRule(func(r *models.MeterRule) { r.Tiers[1].UpTo = upTo(100) }), "ascending"},
+		{"zero first bound", withRule(func(r *models.MeterRule) { r.Tiers[0].UpTo = upTo(0) }), "ascending"},
+		{"negative chain coefficient", withRule(func(r *models.MeterRule) { r.ChainCoefficients = map[string]float64{"eth": -1} }), "chain coefficient"},
+		{"negative method coefficient", withRule(func(r *models.MeterRule) { r.MethodCoefficients = map[string]float64{"trace_block": -2} }), "method coefficient"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRules(tt.definition)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateRules: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScaleRules(t *testing.T) {
+	rule := validRule()
+	rule.Included = 1000
+	rule.MinimumCharge = 5
+	rule.MaximumCharge = 50
+	definition := models.RatingDefinition{
+		MinimumCommit: 10,
+		MaximumCharge: 100,
+		Meters:        []models.MeterRule{rule},
+	}
+
+	tests := []struct {
+		name   string
+		months float64
+	}{
+		{"monthly", 1},
+		{"yearly", 12},
+		{"half month proration", 0.5},
+		{"ten days of a thirty day month", 10.0 / 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scaled := ScaleRules(definition, tt.months)
+
+			if !approx(scaled.MinimumCommit, 10*tt.months) || !approx(scaled.MaximumCharge, 100*tt.months) {
+				t.Errorf("commit and cap = %v, %v", scaled.MinimumCommit, scaled.MaximumCharge)
+			}
+			got := scaled.Meters[0]
+			if !approx(got.Included, 1000*tt.months) || !approx(got.MinimumCharge, 5*tt.months) || !approx(got.MaximumCharge, 50*tt.months) {
+				t.Errorf("meter = %+v", got)
+			}
+			if !approx(*got.Tiers[0].UpTo, 100*tt.months) || !approx(*got.Tiers[1].UpTo, 200*tt.months) || got.Tiers[2].UpTo != nil {
+				t.Errorf("tier bounds = %v, %v, %v", *got.Tiers[0].UpTo, *got.Tiers[1].UpTo, got.Tiers[2].UpTo)
+			}
+			// Prices and unit sizes are per unit, not per month
+			if got.UnitSize != 1e6 || got.Tiers[0].UnitPrice != 1 || got.Tiers[1].UnitPrice != 0.5 || got.Tiers[2].UnitPrice != 0.25 {
+				t.Errorf("prices scaled: %+v", got)
+			}
+		})
+	}
+
+	// The source definition is untouched, including the shared tier bounds
+	if *rule.Tiers[0].UpTo != 100 || definition.Meters[0].Included != 1000 || definition.MinimumCommit != 10 {
+		t.Errorf("ScaleRules modified its input: %+v", definition)
+	}
+}
+
+func TestTierCharge(t *testing.T) {
+	graduated := models.MeterRule{UnitSize: 1, Mode: ModeGraduated, Tiers: testTiers()}
+	volume := models.MeterRule{UnitSize: 1, Mode: ModeVolume, Tiers: testTiers()}
+
+	flatFees := testTiers()
+	flatFees[0].FlatFee = 5
+	flatFees[1].FlatFee = 10
+	graduatedFees := models.MeterRule{UnitSize: 1, Mode: ModeGraduated, Tiers: flatFees}
+	volumeFees := models.MeterRule{UnitSize: 1, Mode: ModeVolume, Tiers: flatFees}
+
+	perMillion := models.MeterRule{UnitSize: 1e6, Mode: ModeGraduated, Tiers: []models.RatingTier{{UnitPrice: 2}}}
+
+	tests := []struct {
+		name     string
+		rule     models.MeterRule
+		billable float64
+		want     float64
+	}{
+		{"graduated first tier", graduated, 50, 50},
+		{"graduated first tier boundary", graduated, 100, 100},
+		{"graduated just past the boundary", graduated, 101, 100.5},
+		{"graduated second tier", graduated, 150, 125},
+		{"graduated second tier boundary", graduated, 200, 150},
+		{"graduated last tier", graduated, 300, 175},
+		{"volume first tier", volume, 50, 50},
+		{"volume first tier boundary", volume, 100, 100},
+		{"volume just past the boundary", volume, 101, 50.5},
+		{"volume second tier boundary", volume, 200, 100},
+		{"volume last tier", volume, 300, 75},
+		{"graduated flat fees of reached tiers", graduatedFees, 150, 5 + 100 + 10 + 25},
+		{"graduated flat fee of an unreached tier", graduatedFees, 100, 5 + 100},
+		{"volume flat fee of the reached tier", volumeFees, 150, 10 + 75},
+		{"unit size", perMillion, 2.5e6, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tierCharge(tt.billable, tt.rule); !approx(got, tt.want) {
+				t.Errorf("tierCharge(%v) = %v, want %v", tt.billable, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRate(t *testing.T) {
+	usage := []models.BillableUsage{
+		{ChainSlug: "eth", ComputeUnits: 2e6},
+		{ChainSlug: "base", ComputeUnits: 1e6},
+	}
+	chainNames := map[string]string{"eth": "Ethereum"}
+
+	// 1M included, then 2M at 10 and the rest at 5 per 1M. Base weighs
+	// double, so 4M weighted units leave 3M billable: 20 + 5.
+	meterRule := func(mutate func(*models.MeterRule)) models.MeterRule {
+		rule := models.MeterRule{
+			Meter:             MeterComputeUnits,
+			UnitSize:          1e6,
+			Included:          1e6,
+			Mode:              ModeGraduated,
+			Tiers:             []models.RatingTier{{UpTo: upTo(2e6), UnitPrice: 10}, {UnitPrice: 5}},
+			ChainCoefficients: map[string]float64{"base": 2},
+		}
+		mutate(&rule)
+		return rule
+	}
+
+	tests := []struct {
+		name       string
+		definition models.RatingDefinition
+		want       []models.InvoiceLineItem
+	}{
+		{
+			name:       "graduated split by weighted share",
+			definition: models.RatingDefinition{Meters: []models.MeterRule{meterRule(func(*models.MeterRule) {})}},
+			want: []models.InvoiceLineItem{
+				{Description: "Ethereum - compute unit overage", Meter: MeterComputeUnits, ChainSlug: "eth", Quantity: 1.5, Unit: "1M compute units", UnitPrice: 8.333333, Amount: 12.5},
+				{Description: "base - compute unit overage", Meter: MeterComputeUnits, ChainSlug: "base", Quantity: 1.5, Unit: "1M compute units", UnitPrice: 8.333333, Amount: 12.5},
+			},
+		},
+		{
+			name: "volume prices every unit at the reached tier",
+			definition: models.RatingDefinition{Meters: []models.MeterRule{meterRule(func(r *models.MeterRule) {
+				r.Mode = ModeVolume
+			})}},
+			want: []models.InvoiceLineItem{
+				{Description: "Ethereum - compute unit overage", Meter: MeterComputeUnits, ChainSlug: "eth", Quantity: 1.5, Unit: "1M compute units", UnitPrice: 5, Amount: 7.5},
+				{Description: "base - compute unit overage", Meter: MeterComputeUnits, ChainSlug: "base", Quantity: 1.5, Unit: "1M compute units", UnitPrice: 5, Amount: 7.5},
+			},
+		},
+		{
+			name: "within the included units",
+			definition: models.RatingDefinition{Meters: []models.MeterRule{meterRule(func(r *models.MeterRule) {
+				r.Included = 4e6
+			})}},
+			want: nil,
+		},
+		{
+			name: "meter minimum charge",
+			definition: models.RatingDefinition{Meters: []models.MeterRule{meterRule(func(r *models.MeterRule) {
+				r.Included = 4e6
+				r.MinimumCharge = 30
+			})}},
+			want: []models.InvoiceLineItem{
+				{Description: "Compute units minimum charge", Meter: MeterComputeUnits, Amount: 30},
+			},
+		},
+		{
+			name: "meter cap and usage minimum commit",
+			definition: models.RatingDefinition{
+				MinimumCommit: 40,
+				Meters: []models.MeterRule{meterRule(func(r *models.MeterRule) {
+					r.MaximumCharge = 20
+				})},
+			},
+			want: []models.InvoiceLineItem{
+				{Description: "Ethereum - compute unit overage", Meter: MeterComputeUnits, ChainSlug: "eth", Quantity: 1.5, Unit: "1M compute units", UnitPrice: 8.333333, Amount: 12.5},
+				{Description: "base - compute unit overage", Meter: MeterComputeUnits, ChainSlug: "base", Quantity: 1.5, Unit: "1M compute units", UnitPrice: 8.333333, Amount: 12.5},
+				{Description: "Compute units charge cap", Meter: MeterComputeUnits, Amount: -5},
+				{Description: "Minimum usage commitment", Amount: 20},
+			},
+		},
+		{
+			name: "usage charge cap",
+			definition: models.RatingDefinition{
+				MaximumCharge: 10,
+				Meters:        []models.MeterRule{meterRule(func(*models.MeterRule) {})},
+			},
+			want: []models.InvoiceLineItem{
+				{Description: "Ethereum - compute unit overage", Meter: MeterComputeUnits, ChainSlug: "eth", Quantity: 1.5, Unit: "1M compute units", UnitPrice: 8.333333, Amount: 12.5},
+				{Description: "base - compute unit overage", Meter: MeterComputeUnits, ChainSlug: "base", Quantity: 1.5, Unit: "1M compute units", UnitPrice: 8.333333, Amount: 12.5},
+				{Description: "Usage charge cap", Amount: -15},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Rate(usage, tt.definition, chainNames)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Rate returned %d lines, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("line %d = %+v\nwant %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateSplitsCentsByLargestRemainder(t *testing.T) {
+	usage := []models.BillableUsage{
+		{ChainSlug: "eth", Requests: 1},
+		{ChainSlug: "base", Requests: 1},
+		{ChainSlug: "arb", Requests: 1},
+	}
+	definition := models.RatingDefinition{Meters: []models.MeterRule{{
+		Meter:    MeterRequests,
+		UnitSize: 1,
+		Mode:     ModeGraduated,
+		Tiers:    []models.RatingTier{{UnitPrice: 1.0 / 3}},
+	}}}
+
+	items := Rate(usage, definition, nil)
+	want := []float64{0.34, 0.33, 0.33}
+	if len(items) != len(want) {
+		t.Fatalf("Rate returned %d lines: %+v", len(items), items)
+	}
+	for i, amount := range want {
+		if items[i].Amount != amount {
+			t.Errorf("line %d amount = %v, want %v", i, items[i].Amount, amount)
+		}
+	}
+	if got := Subtotal(items); got != 1 {
+		t.Errorf("lines add up to %v, want the meter charge of 1", got)
+	}
+}
+
+func TestRateMethodCoefficients(t *testing.T) {
+	usage := []models.BillableUsage{
+		{ChainSlug: "eth", Method: "eth_call", Requests: 100},
+		{ChainSlug: "eth", Method: "trace_block", Requests: 10},
+	}
+	definition := models.RatingDefinition{Meters: []models.MeterRule{{
+		Meter:              MeterRequests,
+		UnitSize:           1,
+		Mode:               ModeGraduated,
+		Tiers:              []models.RatingTier{{UnitPrice: 0.01}},
+		MethodCoefficients: map[string]float64{"trace_block": 10},
+	}}}
+
+	if !UsesMethods(definition) {
+		t.Fatalf("UsesMethods = false for a rule with method coefficients")
+	}
+	items := Rate(usage, definition, nil)
+	// 100 + 10 * 10 weighted requests at 0.01
+	if len(items) != 1 || items[0].Amount != 2 || items[0].Quantity != 200 {
+		t.Fatalf("items = %+v", items)
+	}
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"log"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -14,6 +16,11 @@ type Config struct {
 	PostgreSQL PostgreSQLConfig
 	Redis      RedisConfig
 	Auth       AuthConfig
+	Unkey      UnkeyConfig
+	RateLimit  RateLimitConfig
+	Status     StatusConfig
+	Billing    BillingConfig
+	Stripe     StripeConfig
 	Logging    LoggingConfig
 }
 
@@ -55,8 +62,127 @@ type RedisConfig struct {
 
 type AuthConfig struct {
 	Enabled bool
-	// Simple API key auth for Phase 6
+	// Super-user key that can read every tenant
 	AdminAPIKey string
+	// Role (owner, admin, member) granted to customer API keys
+	APIKeyRole string
+	JWT        JWTConfig
+}
+
+// JWTConfig configures verification of customer portal session tokens
+type JWTConfig struct {
+	Enabled  bool
+	Issuer   string
+	Audience string
+	// File path or http(s) URL of the JWKS document
+	JWKSURL string
+	// JWKS refresh interval in seconds (0 disables refresh)
+	RefreshInterval int
+	// Claim carrying the organization id
+	OrgClaim string
+	// Allowed clock skew in seconds
+	LeewaySeconds int
+}
+
+// UnkeyConfig configures API key verification against Unkey
+type UnkeyConfig struct {
+	Enabled   bool
+	VerifyURL string
+	RootKey   string
+	APIID     string
+	// Verify cache TTL in seconds (shared with Kong when Redis is enabled)
+	CacheTTL int
+	// HTTP timeout in seconds
+	Timeout int
+}
+
+// RateLimitConfig configures per-tenant rate limiting of the API
+type RateLimitConfig struct {
+	Enabled bool
+	// memory (per replica) or redis (shared across replicas)
+	Backend string
+	// Requests per minute for callers without a plan (or with auth disabled)
+	DefaultPerMinute int
+	// How long plan limits are cached, in seconds
+	PlanCacheTTL int
+	// Record 429s into ClickHouse rate_limit_events
+	RecordEvents bool
+	// Events buffered in memory before new ones are dropped
+	EventBufferSize int
+	// Events written per ClickHouse insert
+	EventBatchSize int
+	// Maximum time between flushes, in seconds
+	EventFlushInterval int
+	// Compute units charged by routes heavier than 1, as
+	// "<route path>=<cost>" entries (comma-separated in the environment)
+	RouteCosts []string
+}
+
+// ParseRouteCosts returns RouteCosts keyed by route path
+func (c *RateLimitConfig) ParseRouteCosts() (map[string]int, error) {
+	costs := make(map[string]int, len(c.RouteCosts))
+	for _, entry := range c.RouteCosts {
+		route, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		cost, err := strconv.Atoi(value)
+		if !ok || route == "" || err != nil || cost <= 0 {
+			return nil, fmt.Errorf("invalid ratelimit routecosts entry %q, expected <route path>=<positive cost>", entry)
+		}
+		costs[route] = cost
+	}
+	return costs, nil
+}
+
+// StatusConfig configures the public status page and its incident monitor
+type StatusConfig struct {
+	Enabled bool
+	// Run the incident monitor in this process
+	MonitorEnabled bool
+	// Seconds between health evaluations
+	CheckInterval int
+	// Minutes of chain_health_minutely traffic behind each evaluation
+	WindowMinutes int
+	// Below this many requests in the window error rates are not evaluated
+	MinRequests          int
+	DegradedErrorRatePct float64
+	OutageErrorRatePct   float64
+	DegradedP95LatencyMS float64
+	OutageP95LatencyMS   float64
+	// Consecutive operational checks before an incident is resolved
+	ResolveAfterChecks int
+	// Public base URL used for feed links (e.g. https://status.example.com);
+	// the Atom and RSS feeds are only served when it is set
+	PublicURL string
+}
+
+// BillingConfig configures the invoice engine (cmd/billing) and the chain
+// price sync of the server
+type BillingConfig struct {
+	// Invoice numbers are <prefix>-<year>-<sequence>, e.g. INV-2025-000042
+	InvoicePrefix string
+	// Days after the period end an invoice is due
+	PaymentTermsDays int
+	// Seconds between copies of chain_prices into ClickHouse (0 disables)
+	PriceSyncInterval int
+}
+
+// StripeConfig configures the Stripe usage exporter (cmd/stripe-export) and
+// the Stripe webhook endpoint
+type StripeConfig struct {
+	Enabled bool
+	// Base URL of the Stripe API; point it at cmd/stripe-stub for development
+	APIURL    string
+	SecretKey string
+	// Signing secret of the webhook endpoint (whsec_...)
+	WebhookSecret string
+	// Seconds a webhook signature may be older than now
+	WebhookTolerance int
+	// Usage record buckets: hour or day
+	Granularity string
+	// Minutes a bucket is left to settle after it ends before it is exported,
+	// since usage_hourly receives late requests
+	SettleMinutes int
+	// Seconds per Stripe API call
+	Timeout int
 }
 
 type LoggingConfig struct {
@@ -132,6 +258,72 @@ func setDefaults() {
 	// Auth defaults
 	viper.SetDefault("auth.enabled", false)
 	viper.SetDefault("auth.adminapikey", "")
+	viper.SetDefault("auth.apikeyrole", "admin")
+	viper.SetDefault("auth.jwt.enabled", false)
+	viper.SetDefault("auth.jwt.issuer", "")
+	viper.SetDefault("auth.jwt.audience", "")
+	viper.SetDefault("auth.jwt.jwksurl", "")
+	viper.SetDefault("auth.jwt.refreshinterval", 300)
+	viper.SetDefault("auth.jwt.orgclaim", "org_id")
+	viper.SetDefault("auth.jwt.leewayseconds", 30)
+
+	// Unkey defaults
+	viper.SetDefault("unkey.enabled", false)
+	viper.SetDefault("unkey.verifyurl", "http://unkey:8080/api/v1/keys.verifyKey")
+	viper.SetDefault("unkey.rootkey", "")
+	viper.SetDefault("unkey.apiid", "")
+	viper.SetDefault("unkey.cachettl", 60)
+	viper.SetDefault("unkey.timeout", 5)
+
+	// Rate limit defaults
+	viper.SetDefault("ratelimit.enabled", false)
+	viper.SetDefault("ratelimit.backend", "memory")
+	viper.SetDefault("ratelimit.defaultperminute", 60)
+	viper.SetDefault("ratelimit.plancachettl", 60)
+	viper.SetDefault("ratelimit.recordevents", true)
+	viper.SetDefault("ratelimit.eventbuffersize", 10000)
+	viper.SetDefault("ratelimit.eventbatchsize", 1000)
+	viper.SetDefault("ratelimit.eventflushinterval", 5)
+	// Routes scanning more data cost more compute units; others cost 1
+	viper.SetDefault("ratelimit.routecosts", []string{
+		"/api/v1/usage/organization/:orgId/hourly=2",
+		"/api/v1/usage/organization/:orgId/by-chain=2",
+		"/api/v1/usage/organization/:orgId/requests=3",
+		"/api/v1/usage/organization/:orgId/methods/:method/timeseries=2",
+		"/api/v1/usage/organization/:orgId/expensive-methods=2",
+		"/api/v1/usage/organization/:orgId/timeseries=2",
+		"/api/v1/usage/organization/:orgId/breakdown=2",
+		"/api/v1/usage/organization/:orgId/keys=2",
+		"/api/v1/billing/organization/:orgId/estimate=2",
+	})
+
+	// Status page defaults
+	viper.SetDefault("status.enabled", true)
+	viper.SetDefault("status.monitorenabled", true)
+	viper.SetDefault("status.checkinterval", 60)
+	viper.SetDefault("status.windowminutes", 5)
+	viper.SetDefault("status.minrequests", 20)
+	viper.SetDefault("status.degradederrorratepct", 5.0)
+	viper.SetDefault("status.outageerrorratepct", 50.0)
+	viper.SetDefault("status.degradedp95latencyms", 2000.0)
+	viper.SetDefault("status.outagep95latencyms", 10000.0)
+	viper.SetDefault("status.resolveafterchecks", 3)
+	viper.SetDefault("status.publicurl", "")
+
+	// Billing defaults
+	viper.SetDefault("billing.invoiceprefix", "INV")
+	viper.SetDefault("billing.paymenttermsdays", 14)
+	viper.SetDefault("billing.pricesyncinterval", 300)
+
+	// Stripe defaults
+	viper.SetDefault("stripe.enabled", false)
+	viper.SetDefault("stripe.apiurl", "https://api.stripe.com")
+	viper.SetDefault("stripe.secretkey", "")
+	viper.SetDefault("stripe.webhooksecret", "")
+	viper.SetDefault("stripe.webhooktolerance", 300)
+	viper.SetDefault("stripe.granularity", "hour")
+	viper.SetDefault("stripe.settleminutes", 120)
+	viper.SetDefault("stripe.timeout", 10)
 
 	// Logging defaults
 	viper.SetDefault("logging.level", "info")
@@ -152,5 +344,76 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("postgresql host is required")
 	}
 
+	switch c.Auth.APIKeyRole {
+	case "owner", "admin", "member":
+	default:
+		return fmt.Errorf("auth apikeyrole must be one of owner, admin, member")
+	}
+
+	if c.RateLimit.Enabled {
+		if c.RateLimit.DefaultPerMinute <= 0 {
+			return fmt.Errorf("ratelimit defaultperminute must be positive")
+		}
+		switch c.RateLimit.Backend {
+		case "memory":
+		case "redis":
+			if !c.Redis.Enabled {
+				return fmt.Errorf("ratelimit backend redis requires redis to be enabled")
+			}
+		default:
+			return fmt.Errorf("ratelimit backend must be memory or redis")
+		}
+		if _, err := c.RateLimit.ParseRouteCosts(); err != nil {
+			return err
+		}
+		if c.RateLimit.RecordEvents && (c.RateLimit.EventBufferSize <= 0 || c.RateLimit.EventBatchSize <= 0 || c.RateLimit.EventFlushInterval <= 0) {
+			return fmt.Errorf("ratelimit event buffer size, batch size and flush interval must be positive")
+		}
+	}
+
+	if c.Unkey.Enabled && c.Unkey.VerifyURL == "" {
+		return fmt.Errorf("unkey verifyurl is required when unkey is enabled")
+	}
+
+	if c.Auth.JWT.Enabled {
+		if c.Auth.JWT.Issuer == "" || c.Auth.JWT.Audience == "" {
+			return fmt.Errorf("auth jwt issuer and audience are required when jwt is enabled")
+		}
+		if c.Auth.JWT.JWKSURL == "" {
+			return fmt.Errorf("auth jwt jwksurl is required when jwt is enabled")
+		}
+	}
+
+	if c.Status.Enabled {
+		if c.Status.CheckInterval <= 0 || c.Status.WindowMinutes <= 0 || c.Status.ResolveAfterChecks <= 0 {
+			return fmt.Errorf("status checkinterval, windowminutes and resolveafterchecks must be positive")
+		}
+		if c.Status.OutageErrorRatePct < c.Status.DegradedErrorRatePct || c.Status.OutageP95LatencyMS < c.Status.DegradedP95LatencyMS {
+			return fmt.Errorf("status outage thresholds must not be below degraded thresholds")
+		}
+		if c.Status.PublicURL != "" {
+			u, err := url.Parse(c.Status.PublicURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+				return fmt.Errorf("status publicurl must be an absolute http(s) URL without query or fragment")
+			}
+		}
+	}
+
+	if c.Billing.InvoicePrefix == "" || c.Billing.PaymentTermsDays < 0 || c.Billing.PriceSyncInterval < 0 {
+		return fmt.Errorf("billing invoiceprefix is required and paymenttermsdays and pricesyncinterval must not be negative")
+	}
+
+	if c.Stripe.Enabled {
+		if c.Stripe.APIURL == "" || c.Stripe.SecretKey == "" || c.Stripe.WebhookSecret == "" {
+			return fmt.Errorf("stripe apiurl, secretkey and webhooksecret are required when stripe is enabled")
+		}
+		if c.Stripe.Granularity != "hour" && c.Stripe.Granularity != "day" {
+			return fmt.Errorf("stripe granularity must be hour or day")
+		}
+		if c.Stripe.WebhookTolerance <= 0 || c.Stripe.SettleMinutes < 0 || c.Stripe.Timeout <= 0 {
+			return fmt.Errorf("stripe webhooktolerance and timeout must be positive and settleminutes must not be negative")
+		}
+	}
+
 	return nil
 }
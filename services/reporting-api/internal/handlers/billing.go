@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/billing"
+)
+
+type BillingHandler struct {
+	engine *billing.Engine
+}
+
+func NewBillingHandler(engine *billing.Engine) *BillingHandler {
+	return &BillingHandler{
+		engine: engine,
+	}
+}
+
+// GetOrganizationEstimate returns the charges of the current billing period
+// so far and projected to its end
+// GET /api/v1/billing/organization/:orgId/estimate
+func (h *BillingHandler) GetOrganizationEstimate(c *gin.Context) {
+	orgID := c.Param("orgId")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization id is required"})
+		return
+	}
+
+	estimate, err := h.engine.Estimate(c.Request.Context(), orgID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to estimate billing period"})
+		return
+	}
+	if estimate == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "organization has no active subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, estimate)
+}
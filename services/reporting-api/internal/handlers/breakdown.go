@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+)
+
+var defaultUsageMetrics = []string{"requests", "compute_units", "errors"}
+
+// GetOrganizationBreakdown aggregates usage over the range by any combination
+// of dimensions, replacing a dedicated handler per breakdown
+// GET /api/v1/usage/organization/:orgId/breakdown
+func (h *UsageHandler) GetOrganizationBreakdown(c *gin.Context) {
+	orgID := c.Param("orgId")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization id is required"})
+		return
+	}
+
+	startDate, endDate, err := h.parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	groupBy, filters, ok := parseUsageDimensions(c)
+	if !ok {
+		return
+	}
+	if _, err := repository.UsageSource(groupBy, filters, startDate, time.Now().UTC()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	metrics, ok := parseUsageMetrics(c)
+	if !ok {
+		return
+	}
+
+	params := models.UsageQueryParams{
+		StartDate: startDate,
+		EndDate:   endDate,
+		Metrics:   metrics,
+		GroupBy:   groupBy,
+		Filters:   filters,
+		Limit:     parseLimit(c, 100, repository.MaxBreakdownGroups),
+	}
+
+	breakdown, err := h.clickhouseRepo.GetUsageBreakdown(c.Request.Context(), orgID, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get usage breakdown"})
+		return
+	}
+
+	c.JSON(http.StatusOK, breakdown)
+}
+
+// parseUsageDimensions reads group_by, a comma-separated list of dimensions,
+// and filters given as filter[dimension]=value1,value2. It writes the error
+// response on failure.
+func parseUsageDimensions(c *gin.Context) ([]string, map[string][]string, bool) {
+	groupBy := splitList(c.Query("group_by"))
+
+	var filters map[string][]string
+	for dimension, v := range c.QueryMap("filter") {
+		if filters == nil {
+			filters = make(map[string][]string)
+		}
+		filters[dimension] = splitList(v)
+	}
+
+	if err := repository.ValidateUsageDimensions(groupBy, filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+
+	return groupBy, filters, true
+}
+
+// parseUsageMetrics reads metrics, a comma-separated list defaulting to
+// requests, compute_units and errors. It writes the error response on failure.
+func parseUsageMetrics(c *gin.Context) ([]string, bool) {
+	v := c.Query("metrics")
+	if v == "" {
+		return defaultUsageMetrics, true
+	}
+
+	var metrics []string
+	seen := make(map[string]bool)
+	for _, metric := range splitList(v) {
+		if !repository.ValidTimeseriesMetric(metric) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "metrics must be a comma-separated list of requests, compute_units, errors, error_rate, egress_bytes, latency_avg, latency_p50, latency_p95, latency_p99"})
+			return nil, false
+		}
+		if !seen[metric] {
+			seen[metric] = true
+			metrics = append(metrics, metric)
+		}
+	}
+	if len(metrics) == 0 {
+		return defaultUsageMetrics, true
+	}
+
+	return metrics, true
+}
+
+// splitList splits a comma-separated query value, dropping blank entries
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+)
+
+// recentHealthWindow is the traffic window behind "current" health figures
+const recentHealthWindow = 5 * time.Minute
+
+// healthWindows maps the window parameter to its bucket size in minutes
+var healthWindows = map[string]struct {
+	duration      time.Duration
+	bucketMinutes int
+}{
+	"1h":  {time.Hour, 1},
+	"6h":  {6 * time.Hour, 5},
+	"24h": {24 * time.Hour, 15},
+	"7d":  {7 * 24 * time.Hour, 60},
+	"30d": {30 * 24 * time.Hour, 360},
+}
+
+type ChainsHandler struct {
+	clickhouseRepo *repository.ClickHouseRepository
+	postgresRepo   *repository.PostgresRepository
+}
+
+func NewChainsHandler(ch *repository.ClickHouseRepository, pg *repository.PostgresRepository) *ChainsHandler {
+	return &ChainsHandler{
+		clickhouseRepo: ch,
+		postgresRepo:   pg,
+	}
+}
+
+// ListChains returns the chain catalog with current health
+// GET /api/v1/chains
+func (h *ChainsHandler) ListChains(c *gin.Context) {
+	includeTestnets := c.Query("include_testnets") == "true"
+
+	chains, err := h.postgresRepo.ListChains(c.Request.Context(), includeTestnets)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list chains"})
+		return
+	}
+
+	// The catalog is still useful without live metrics
+	health, err := h.clickhouseRepo.GetChainHealthSummary(c.Request.Context(), time.Now().UTC().Add(-recentHealthWindow))
+	if err == nil {
+		for i := range chains {
+			if chainHealth, ok := health[chains[i].Slug]; ok {
+				chains[i].Health = &chainHealth
+			}
+		}
+	}
+
+	if chains == nil {
+		chains = []models.Chain{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chains": chains,
+		"total":  len(chains),
+	})
+}
+
+// GetChainHealth returns a chain's health time series
+// GET /api/v1/chains/:slug/health
+func (h *ChainsHandler) GetChainHealth(c *gin.Context) {
+	window := c.DefaultQuery("window", "24h")
+	params, ok := healthWindows[window]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window must be one of 1h, 6h, 24h, 7d, 30d"})
+		return
+	}
+
+	chain, ok := h.getChain(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	series, err := h.clickhouseRepo.GetChainHealthSeries(c.Request.Context(), chain.Slug, now.Add(-params.duration), now, params.bucketMinutes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get chain health"})
+		return
+	}
+
+	current, err := h.clickhouseRepo.GetChainHealthSummary(c.Request.Context(), now.Add(-recentHealthWindow))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get chain health"})
+		return
+	}
+	if chainHealth, ok := current[chain.Slug]; ok {
+		chain.Health = &chainHealth
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chain":          chain,
+		"window":         window,
+		"bucket_minutes": params.bucketMinutes,
+		"series":         series,
+	})
+}
+
+// GetChainUpstreams returns a chain's upstream nodes with their live metrics
+// GET /api/v1/chains/:slug/upstreams
+func (h *ChainsHandler) GetChainUpstreams(c *gin.Context) {
+	chain, ok := h.getChain(c)
+	if !ok {
+		return
+	}
+
+	endpoints, err := h.postgresRepo.ListRPCEndpoints(c.Request.Context(), chain.Slug)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list upstreams"})
+		return
+	}
+
+	health, err := h.clickhouseRepo.GetUpstreamHealth(c.Request.Context(), chain.Slug, time.Now().UTC().Add(-recentHealthWindow))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get upstream health"})
+		return
+	}
+
+	upstreams := make([]models.UpstreamStatus, 0, len(endpoints))
+	for i := range endpoints {
+		status := models.UpstreamStatus{
+			Host:     endpoints[i].Host,
+			Endpoint: &endpoints[i],
+		}
+		if upstreamHealth, ok := health[endpoints[i].Host]; ok {
+			status.Health = &upstreamHealth
+			delete(health, endpoints[i].Host)
+		}
+		upstreams = append(upstreams, status)
+	}
+
+	// Hosts serving traffic without a catalog entry usually mean drift
+	// between Kong's upstreams and rpc_endpoints
+	unknown := make([]string, 0, len(health))
+	for host := range health {
+		unknown = append(unknown, host)
+	}
+	sort.Strings(unknown)
+	for _, host := range unknown {
+		upstreamHealth := health[host]
+		upstreams = append(upstreams, models.UpstreamStatus{
+			Host:   host,
+			Health: &upstreamHealth,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chain":     chain,
+		"upstreams": upstreams,
+	})
+}
+
+// getChain loads the :slug chain, writing the error response when it fails
+func (h *ChainsHandler) getChain(c *gin.Context) (*models.Chain, bool) {
+	slug := c.Param("slug")
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "chain slug is required"})
+		return nil, false
+	}
+
+	chain, err := h.postgresRepo.GetChainBySlug(c.Request.Context(), slug)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get chain"})
+		return nil, false
+	}
+	if chain == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "chain not found"})
+		return nil, false
+	}
+
+	return chain, true
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// newUsageComparison compares usage with the previous window. Chains are kept
+// in the current window's order, followed by chains only used before.
+func newUsageComparison(compare string, current models.SummaryMetrics, currentChains []models.ChainUsage, previous *models.UsageSummary, previousChains []models.ChainUsage) *models.UsageComparison {
+	comparison := &models.UsageComparison{
+		Compare: compare,
+		Period:  previous.Period,
+		Summary: previous.Summary,
+		Deltas:  usageDeltas(current, previous.Summary),
+		ByChain: []models.ChainComparison{},
+	}
+
+	previousBySlug := make(map[string]models.ChainUsage, len(previousChains))
+	for _, chain := range previousChains {
+		previousBySlug[chain.ChainSlug] = chain
+	}
+
+	seen := make(map[string]bool, len(currentChains))
+	for _, chain := range currentChains {
+		seen[chain.ChainSlug] = true
+		comparison.ByChain = append(comparison.ByChain, models.ChainComparison{
+			ChainSlug: chain.ChainSlug,
+			ChainType: chain.ChainType,
+			Deltas:    usageDeltas(chainMetrics(chain), chainMetrics(previousBySlug[chain.ChainSlug])),
+		})
+	}
+	for _, chain := range previousChains {
+		if seen[chain.ChainSlug] {
+			continue
+		}
+		comparison.ByChain = append(comparison.ByChain, models.ChainComparison{
+			ChainSlug: chain.ChainSlug,
+			ChainType: chain.ChainType,
+			Deltas:    usageDeltas(models.SummaryMetrics{}, chainMetrics(chain)),
+		})
+	}
+
+	return comparison
+}
+
+// usageDeltas compares the metrics reported in comparisons
+func usageDeltas(current, previous models.SummaryMetrics) models.UsageDeltas {
+	return models.UsageDeltas{
+		Requests:     newMetricDelta(float64(current.TotalRequests), float64(previous.TotalRequests)),
+		ComputeUnits: newMetricDelta(float64(current.TotalComputeUnits), float64(previous.TotalComputeUnits)),
+		EgressGB:     newMetricDelta(current.TotalEgressGB, previous.TotalEgressGB),
+		ErrorRatePct: newMetricDelta(current.ErrorRatePct, previous.ErrorRatePct),
+		LatencyP95MS: newMetricDelta(current.AvgLatencyP95MS, previous.AvgLatencyP95MS),
+	}
+}
+
+func newMetricDelta(current, previous float64) models.MetricDelta {
+	delta := models.MetricDelta{
+		Current:  current,
+		Previous: previous,
+		Change:   current - previous,
+	}
+	if previous != 0 {
+		pct := delta.Change / previous * 100
+		delta.ChangePct = &pct
+	}
+	return delta
+}
+
+// chainMetrics maps a chain's usage onto the summary metrics it shares
+func chainMetrics(chain models.ChainUsage) models.SummaryMetrics {
+	return models.SummaryMetrics{
+		TotalRequests:     chain.Requests,
+		TotalComputeUnits: chain.ComputeUnits,
+		TotalEgressGB:     chain.EgressGB,
+		ErrorCount:        chain.ErrorCount,
+		ErrorRatePct:      chain.ErrorRatePct,
+		AvgLatencyP95MS:   chain.AvgLatencyP95,
+	}
+}
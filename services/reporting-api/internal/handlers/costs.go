@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+)
+
+// GetChainCosts returns estimated usage cost per chain per day, priced at
+// ingest from the chain_prices list prices
+// GET /api/v1/admin/costs
+func (h *UsageHandler) GetChainCosts(c *gin.Context) {
+	startDate, endDate, err := h.parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := repository.CostFilter{
+		OrganizationID: c.Query("organization_id"),
+		ChainSlug:      c.Query("chain"),
+		PlanSlug:       c.Query("plan"),
+	}
+
+	costs, err := h.clickhouseRepo.GetChainCosts(c.Request.Context(), startDate, endDate, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get chain costs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, costs)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+)
+
+// errorLookupWindow is how far back a request's errors are looked up when
+// no range is given
+const errorLookupWindow = 24 * time.Hour
+
+// GetOrganizationErrors returns the top error groups and a page of raw errors
+// GET /api/v1/usage/organization/:orgId/errors
+func (h *UsageHandler) GetOrganizationErrors(c *gin.Context) {
+	orgID := c.Param("orgId")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization id is required"})
+		return
+	}
+
+	startDate, endDate, err := h.parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := models.ErrorFilter{
+		ChainSlug: c.Query("chain"),
+		RPCMethod: c.Query("rpc_method"),
+		ErrorType: c.Query("error_type"),
+	}
+	if statusStr := c.Query("status_code"); statusStr != "" {
+		status, err := strconv.Atoi(statusStr)
+		if err != nil || status < 100 || status > 599 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status_code must be an HTTP status code"})
+			return
+		}
+		filter.StatusCode = status
+	}
+
+	topN := 10
+	if topStr := c.Query("top"); topStr != "" {
+		top, err := strconv.Atoi(topStr)
+		if err != nil || top < 0 || top > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "top must be between 0 and 100"})
+			return
+		}
+		topN = top
+	}
+	limit := parseLimit(c, 50, 500)
+
+	page, err := h.clickhouseRepo.GetErrors(c.Request.Context(), orgID, startDate, endDate, filter, topN, limit, c.Query("cursor"))
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get errors"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetOrganizationErrorByRequestID returns the errors recorded for one request
+// within start/end, by default the last 24 hours
+// GET /api/v1/usage/organization/:orgId/errors/:requestId
+func (h *UsageHandler) GetOrganizationErrorByRequestID(c *gin.Context) {
+	orgID := c.Param("orgId")
+	requestID := c.Param("requestId")
+	if orgID == "" || requestID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization id and request id are required"})
+		return
+	}
+
+	// Without a range, look at the last day rather than the whole table
+	endDate := time.Now()
+	startDate := endDate.Add(-errorLookupWindow)
+	if c.Query("start") != "" || c.Query("start_date") != "" || c.Query("end") != "" || c.Query("end_date") != "" {
+		var err error
+		if startDate, endDate, err = h.parseDateRange(c); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	events, err := h.clickhouseRepo.GetErrorsByRequestID(c.Request.Context(), orgID, requestID, startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get errors"})
+		return
+	}
+	if len(events) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no errors recorded for request in this period"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization_id": orgID,
+		"request_id":      requestID,
+		"errors":          events,
+	})
+}
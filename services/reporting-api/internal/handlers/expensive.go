@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+	"github.com/hoodrun/rpc-gateway/reporting-api/pkg/utils"
+)
+
+// GetOrganizationExpensiveMethods audits an organization's expensive calls
+// GET /api/v1/usage/organization/:orgId/expensive-methods
+func (h *UsageHandler) GetOrganizationExpensiveMethods(c *gin.Context) {
+	orgID := c.Param("orgId")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization id is required"})
+		return
+	}
+
+	startDate, endDate, ok := h.parseExpensiveRange(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.clickhouseRepo.GetExpensiveMethodReport(c.Request.Context(), orgID, startDate, endDate, parseLimit(c, 50, 500))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get expensive method usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetExpensiveMethodShares ranks organizations by expensive-call share
+// GET /api/v1/admin/expensive-methods
+func (h *UsageHandler) GetExpensiveMethodShares(c *gin.Context) {
+	startDate, endDate, ok := h.parseExpensiveRange(c)
+	if !ok {
+		return
+	}
+
+	minCalls := uint64(100)
+	if v := c.Query("min_calls"); v != "" {
+		parsed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_calls must be a non-negative integer"})
+			return
+		}
+		minCalls = parsed
+	}
+
+	ctx := c.Request.Context()
+	shares, err := h.clickhouseRepo.GetExpensiveMethodShares(ctx, startDate, endDate, minCalls, parseLimit(c, 50, 200))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get expensive method shares"})
+		return
+	}
+
+	// Names are best effort; deleted organizations keep their usage
+	for i := range shares {
+		if org, err := h.postgresRepo.GetOrganization(ctx, shares[i].OrganizationID); err == nil {
+			shares[i].OrganizationName = org.Name
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"period":        models.NewPeriod(startDate, endDate),
+		"min_calls":     minCalls,
+		"organizations": shares,
+	})
+}
+
+// parseExpensiveRange parses the date range, defaulting to and clamping at the
+// retention of expensive_method_usage. It writes the error response on failure.
+func (h *UsageHandler) parseExpensiveRange(c *gin.Context) (time.Time, time.Time, bool) {
+	if c.Query("start") == "" && c.Query("start_date") == "" && c.Query("end") == "" && c.Query("end_date") == "" {
+		loc, err := utils.LoadTimezone(c.Query("tz"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return time.Time{}, time.Time{}, false
+		}
+		now := time.Now().In(loc)
+		return now.Add(-repository.ExpensiveMethodRetention), now, true
+	}
+
+	startDate, endDate, err := h.parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return time.Time{}, time.Time{}, false
+	}
+	retentionStart := time.Now().In(startDate.Location()).Add(-repository.ExpensiveMethodRetention)
+	if endDate.Before(retentionStart) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expensive method data is kept for 7 days"})
+		return time.Time{}, time.Time{}, false
+	}
+	if startDate.Before(retentionStart) {
+		startDate = retentionStart
+	}
+
+	return startDate, endDate, true
+}
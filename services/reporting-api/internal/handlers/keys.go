@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// keySorts maps the sort options of GetOrganizationKeys to the value they
+// order by; keys never used sort as zero
+var keySorts = map[string]func(models.KeyUsage) float64{
+	"requests":      func(k models.KeyUsage) float64 { return float64(k.Summary.TotalRequests) },
+	"compute_units": func(k models.KeyUsage) float64 { return float64(k.Summary.TotalComputeUnits) },
+	"last_used": func(k models.KeyUsage) float64 {
+		if k.LastUsed == nil {
+			return 0
+		}
+		return float64(k.LastUsed.Unix())
+	},
+}
+
+// GetOrganizationKeys lists an organization's API keys with their usage,
+// including keys without traffic, to find unused or runaway keys
+// GET /api/v1/usage/organization/:orgId/keys
+func (h *UsageHandler) GetOrganizationKeys(c *gin.Context) {
+	orgID := c.Param("orgId")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization id is required"})
+		return
+	}
+
+	startDate, endDate, err := h.parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sortBy := c.DefaultQuery("sort", "requests")
+	sortValue, ok := keySorts[sortBy]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be requests, compute_units or last_used"})
+		return
+	}
+	order := c.DefaultQuery("order", "desc")
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	keys, err := h.postgresRepo.ListAPIKeys(ctx, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list api keys"})
+		return
+	}
+
+	usage, err := h.clickhouseRepo.GetOrganizationKeyUsage(ctx, orgID, startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get key usage"})
+		return
+	}
+
+	result := make([]models.KeyUsage, 0, len(keys))
+	for _, key := range keys {
+		keyUsage := models.KeyUsage{
+			ID:            key.ID,
+			KeyPrefix:     key.KeyPrefix,
+			Name:          key.Name,
+			ConsumerID:    key.ConsumerID,
+			Status:        key.Status,
+			ExpiresAt:     key.ExpiresAt,
+			AllowedChains: key.AllowedChains,
+			CreatedAt:     key.CreatedAt,
+			ByChain:       []models.ChainUsage{},
+		}
+		if u, ok := usage[key.KeyPrefix]; ok {
+			keyUsage.Summary = u.Summary
+			keyUsage.ByChain = u.ByChain
+			keyUsage.LastUsed = u.LastUsed
+		}
+		// api_keys.last_used_at is more precise when it is kept up to date
+		if key.LastUsedAt != nil && (keyUsage.LastUsed == nil || key.LastUsedAt.After(*keyUsage.LastUsed)) {
+			keyUsage.LastUsed = key.LastUsedAt
+		}
+		result = append(result, keyUsage)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if order == "asc" {
+			return sortValue(result[i]) < sortValue(result[j])
+		}
+		return sortValue(result[i]) > sortValue(result[j])
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization_id": orgID,
+		"period":          models.NewPeriod(startDate, endDate),
+		"sort":            sortBy,
+		"order":           order,
+		"keys":            result,
+	})
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+)
+
+// methodFlags maps the flag query parameter to the property it selects
+var methodFlags = map[string]func(models.MethodComputeUnits) bool{
+	"expensive": func(m models.MethodComputeUnits) bool { return m.IsExpensive },
+	"archive":   func(m models.MethodComputeUnits) bool { return m.RequiresArchive },
+	"trace":     func(m models.MethodComputeUnits) bool { return m.RequiresTrace },
+}
+
+// GetMethodAnalytics ranks RPC methods across all organizations
+// GET /api/v1/admin/methods
+func (h *UsageHandler) GetMethodAnalytics(c *gin.Context) {
+	startDate, endDate, err := h.parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sortBy := c.DefaultQuery("sort", "requests")
+	if !repository.ValidMethodSort(sortBy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of requests, compute_units, error_rate, latency, consumers"})
+		return
+	}
+
+	flag := c.Query("flag")
+	hasFlag, ok := methodFlags[flag]
+	if flag != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "flag must be one of expensive, archive, trace"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	units, err := h.postgresRepo.GetMethodComputeUnits(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get method compute units"})
+		return
+	}
+
+	chains, err := h.postgresRepo.ListChains(ctx, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list chains"})
+		return
+	}
+	chainTypes := make(map[string]string, len(chains))
+	for _, chain := range chains {
+		chainTypes[chain.Slug] = chain.ChainType
+	}
+
+	// Narrow the query to flagged method names; the chain type is checked
+	// per row below since a name may be flagged on one chain type only
+	var methods []string
+	if hasFlag != nil {
+		seen := make(map[string]bool)
+		for _, byMethod := range units {
+			for name, m := range byMethod {
+				if hasFlag(m) && !seen[name] {
+					seen[name] = true
+					methods = append(methods, name)
+				}
+			}
+		}
+		// No flagged methods: match nothing rather than everything
+		if len(methods) == 0 {
+			methods = []string{""}
+		}
+	}
+
+	analytics, err := h.clickhouseRepo.GetMethodAnalytics(ctx, startDate, endDate, c.Query("chain"), methods, sortBy, parseLimit(c, 50, 500))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get method analytics"})
+		return
+	}
+
+	annotated := analytics.Methods[:0]
+	for _, stats := range analytics.Methods {
+		if m, ok := units[chainTypes[stats.ChainSlug]][stats.Method]; ok {
+			stats.Properties = &m
+		}
+		if hasFlag != nil && (stats.Properties == nil || !hasFlag(*stats.Properties)) {
+			continue
+		}
+		annotated = append(annotated, stats)
+	}
+	analytics.Methods = annotated
+
+	c.JSON(http.StatusOK, analytics)
+}
+
+// GetOrganizationMethodTimeseries returns an organization's usage of one RPC
+// method over time
+// GET /api/v1/usage/organization/:orgId/methods/:method/timeseries
+func (h *UsageHandler) GetOrganizationMethodTimeseries(c *gin.Context) {
+	orgID := c.Param("orgId")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization id is required"})
+		return
+	}
+
+	method := c.Param("method")
+	if method == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "method is required"})
+		return
+	}
+
+	startDate, endDate, err := h.parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Per-method data comes from usage_hourly, which is kept for 90 days
+	if endDate.Sub(startDate) > 90*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "method data limited to 90 days maximum"})
+		return
+	}
+
+	// Hourly buckets for up to a week, daily beyond
+	interval := c.Query("interval")
+	switch interval {
+	case "":
+		interval = "hour"
+		if endDate.Sub(startDate) > 7*24*time.Hour {
+			interval = "day"
+		}
+	case "hour":
+		if endDate.Sub(startDate) > 7*24*time.Hour {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hourly data limited to 7 days maximum"})
+			return
+		}
+	case "day":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be hour or day"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	chainSlug := c.Query("chain")
+	chainType := ""
+	if chainSlug != "" {
+		chain, err := h.postgresRepo.GetChainBySlug(ctx, chainSlug)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get chain"})
+			return
+		}
+		if chain == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "chain not found"})
+			return
+		}
+		chainType = chain.ChainType
+	}
+
+	buckets, err := h.clickhouseRepo.GetMethodTimeseries(ctx, orgID, method, chainSlug, startDate, endDate, interval)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get method timeseries"})
+		return
+	}
+
+	units, err := h.postgresRepo.GetMethodComputeUnits(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get method compute units"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MethodTimeseries{
+		OrganizationID: orgID,
+		Method:         method,
+		ChainSlug:      chainSlug,
+		Period:         models.NewPeriod(startDate, endDate),
+		Interval:       interval,
+		Properties:     lookupMethod(units, chainType, method),
+		Timeseries:     buckets,
+	})
+}
+
+// lookupMethod finds a method's compute units entry. Without a chain type the
+// method name must be listed under exactly one chain type.
+func lookupMethod(units map[string]map[string]models.MethodComputeUnits, chainType, method string) *models.MethodComputeUnits {
+	if chainType != "" {
+		if m, ok := units[chainType][method]; ok {
+			return &m
+		}
+		return nil
+	}
+
+	var matches []models.MethodComputeUnits
+	for _, byMethod := range units {
+		if m, ok := byMethod[method]; ok {
+			matches = append(matches, m)
+		}
+	}
+	if len(matches) != 1 {
+		return nil
+	}
+	return &matches[0]
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOrganizationRateLimits returns 429 counts by window and consumer
+// GET /api/v1/usage/organization/:orgId/rate-limits
+func (h *UsageHandler) GetOrganizationRateLimits(c *gin.Context) {
+	orgID := c.Param("orgId")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization id is required"})
+		return
+	}
+
+	startDate, endDate, err := h.parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Hourly buckets for up to a week, daily beyond
+	interval := c.Query("interval")
+	switch interval {
+	case "":
+		interval = "hour"
+		if endDate.Sub(startDate) > 7*24*time.Hour {
+			interval = "day"
+		}
+	case "hour":
+		if endDate.Sub(startDate) > 7*24*time.Hour {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hourly data limited to 7 days maximum"})
+			return
+		}
+	case "day":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be hour or day"})
+		return
+	}
+
+	report, err := h.clickhouseRepo.GetRateLimitReport(c.Request.Context(), orgID, startDate, endDate, interval)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get rate limit events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
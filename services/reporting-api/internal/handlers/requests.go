@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/middleware"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+)
+
+// GetRequest returns a single request trace
+// GET /api/v1/requests/:requestId
+func (h *UsageHandler) GetRequest(c *gin.Context) {
+	requestID := c.Param("requestId")
+	if requestID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request id is required"})
+		return
+	}
+
+	// The route carries no :orgId, so tenant scoping is applied to the query.
+	// Requests of other tenants are reported as not found.
+	orgID := ""
+	if principal, ok := middleware.GetPrincipal(c); ok && !principal.IsAdmin {
+		orgID = principal.OrganizationID
+	}
+
+	trace, err := h.clickhouseRepo.GetRequest(c.Request.Context(), requestID, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get request"})
+		return
+	}
+	if trace == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found (requests are kept for 14 days)"})
+		return
+	}
+
+	c.JSON(http.StatusOK, trace)
+}
+
+// SearchOrganizationRequests returns a page of an organization's requests
+// GET /api/v1/usage/organization/:orgId/requests
+func (h *UsageHandler) SearchOrganizationRequests(c *gin.Context) {
+	orgID := c.Param("orgId")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization id is required"})
+		return
+	}
+
+	startDate, endDate, err := h.parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := models.RequestFilter{
+		ChainSlug: c.Query("chain"),
+		RPCMethod: c.Query("method"),
+	}
+
+	if status := strings.ToLower(c.Query("status")); status != "" {
+		if len(status) == 3 && strings.HasSuffix(status, "xx") && status[0] >= '1' && status[0] <= '5' {
+			filter.StatusClass = int(status[0] - '0')
+		} else if code, err := strconv.Atoi(status); err == nil && code >= 100 && code <= 599 {
+			filter.StatusCode = code
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be a status code or class (e.g. 502, 5xx)"})
+			return
+		}
+	}
+
+	if minLatency := c.Query("min_latency"); minLatency != "" {
+		ms, err := strconv.Atoi(minLatency)
+		if err != nil || ms < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_latency must be a non-negative number of milliseconds"})
+			return
+		}
+		filter.MinLatencyMS = ms
+	}
+
+	limit := parseLimit(c, 50, 500)
+
+	page, err := h.clickhouseRepo.SearchRequests(c.Request.Context(), orgID, startDate, endDate, filter, limit, c.Query("cursor"))
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/status"
+)
+
+// feedSize is the number of incidents in the Atom and RSS feeds
+const feedSize = 50
+
+type StatusHandler struct {
+	service   *status.Service
+	publicURL string
+}
+
+// NewStatusHandler creates the public status handler; publicURL is the base
+// of feed links. Feeds are cached publicly, so their links are never built
+// from request headers.
+func NewStatusHandler(service *status.Service, publicURL string) *StatusHandler {
+	return &StatusHandler{
+		service:   service,
+		publicURL: strings.TrimRight(publicURL, "/"),
+	}
+}
+
+// GetStatus returns the current status of every chain
+// GET /status
+func (h *StatusHandler) GetStatus(c *gin.Context) {
+	page, err := h.service.Page(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "status temporarily unavailable"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=30")
+	c.JSON(http.StatusOK, page)
+}
+
+// ListIncidents returns the incidents of the last 90 days
+// GET /status/incidents
+func (h *StatusHandler) ListIncidents(c *gin.Context) {
+	incidents, err := h.service.Incidents(c.Request.Context(), parseLimit(c, 50, status.MaxIncidents))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "incidents temporarily unavailable"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=30")
+	c.JSON(http.StatusOK, gin.H{
+		"incidents": incidents,
+	})
+}
+
+// AtomFeed returns the incidents as an Atom feed
+// GET /status/feed.atom
+func (h *StatusHandler) AtomFeed(c *gin.Context) {
+	incidents, err := h.service.Incidents(c.Request.Context(), feedSize)
+	if err != nil {
+		c.String(http.StatusServiceUnavailable, "incidents temporarily unavailable")
+		return
+	}
+
+	base := h.publicURL
+	feed := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		ID:      base + "/status",
+		Title:   "RPC Gateway Status - Incidents",
+		Updated: feedUpdated(incidents).Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: base + "/status/feed.atom", Rel: "self"},
+			{Href: base + "/status", Rel: "alternate"},
+		},
+	}
+	for _, incident := range incidents {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        "urn:uuid:" + incident.ID,
+			Title:     incidentFeedTitle(incident),
+			Updated:   incident.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: incident.StartedAt.UTC().Format(time.RFC3339),
+			Link:      atomLink{Href: base + "/status/incidents#" + incident.ID, Rel: "alternate"},
+			Summary:   incidentSummary(incident),
+		})
+	}
+
+	writeXML(c, "application/atom+xml; charset=utf-8", feed)
+}
+
+// RSSFeed returns the incidents as an RSS 2.0 feed
+// GET /status/feed.rss
+func (h *StatusHandler) RSSFeed(c *gin.Context) {
+	incidents, err := h.service.Incidents(c.Request.Context(), feedSize)
+	if err != nil {
+		c.String(http.StatusServiceUnavailable, "incidents temporarily unavailable")
+		return
+	}
+
+	base := h.publicURL
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         "RPC Gateway Status - Incidents",
+			Link:          base + "/status",
+			Description:   "Degraded performance and outages of RPC Gateway chains",
+			LastBuildDate: feedUpdated(incidents).Format(time.RFC1123Z),
+		},
+	}
+	for _, incident := range incidents {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       incidentFeedTitle(incident),
+			Link:        base + "/status/incidents#" + incident.ID,
+			Description: incidentSummary(incident),
+			GUID:        rssGUID{Value: incident.ID, IsPermaLink: false},
+			PubDate:     incident.StartedAt.UTC().Format(time.RFC1123Z),
+		})
+	}
+
+	writeXML(c, "application/rss+xml; charset=utf-8", feed)
+}
+
+func writeXML(c *gin.Context, contentType string, v interface{}) {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to render feed")
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=60")
+	c.Data(http.StatusOK, contentType, append([]byte(xml.Header), data...))
+}
+
+// feedUpdated is the time of the latest change in the feed
+func feedUpdated(incidents []models.Incident) time.Time {
+	updated := time.Unix(0, 0).UTC()
+	for _, incident := range incidents {
+		if incident.UpdatedAt.After(updated) {
+			updated = incident.UpdatedAt.UTC()
+		}
+	}
+	return updated
+}
+
+func incidentFeedTitle(incident models.Incident) string {
+	if incident.ResolvedAt != nil {
+		return "[Resolved] " + incident.Title
+	}
+	return incident.Title
+}
+
+func incidentSummary(incident models.Incident) string {
+	state := incident.Status
+	if state != "" {
+		state = strings.ToUpper(state[:1]) + state[1:]
+	}
+	summary := fmt.Sprintf("%s on %s since %s. Peak error rate %.1f%%, peak p95 latency %.0f ms.",
+		state, incident.ChainName,
+		incident.StartedAt.UTC().Format(time.RFC1123),
+		incident.PeakErrorRatePct, incident.PeakP95LatencyMS)
+	if incident.ResolvedAt != nil {
+		summary += fmt.Sprintf(" Resolved at %s after %s.",
+			incident.ResolvedAt.UTC().Format(time.RFC1123),
+			incident.ResolvedAt.Sub(incident.StartedAt).Round(time.Minute))
+	}
+	return summary
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Updated   string   `xml:"updated"`
+	Published string   `xml:"published"`
+	Link      atomLink `xml:"link"`
+	Summary   string   `xml:"summary"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/stripe"
+)
+
+// maxWebhookBody bounds Stripe event payloads, which are far smaller
+const maxWebhookBody = 1 << 20
+
+type StripeHandler struct {
+	webhooks *stripe.Webhooks
+}
+
+func NewStripeHandler(webhooks *stripe.Webhooks) *StripeHandler {
+	return &StripeHandler{
+		webhooks: webhooks,
+	}
+}
+
+// Webhook receives Stripe events, authenticated by their Stripe-Signature.
+// Stripe retries deliveries that do not get a 2xx response.
+// POST /webhooks/stripe
+func (h *StripeHandler) Webhook(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+		return
+	}
+
+	err = h.webhooks.Handle(c.Request.Context(), payload, c.GetHeader("Stripe-Signature"), time.Now())
+	switch {
+	case errors.Is(err, stripe.ErrInvalidSignature):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid signature"})
+		return
+	case errors.Is(err, stripe.ErrInvalidEvent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
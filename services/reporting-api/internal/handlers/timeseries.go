@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+)
+
+// maxTimeseriesBuckets caps the points of one series
+const maxTimeseriesBuckets = 1500
+
+// GetOrganizationTimeseries returns usage bucketed by minute, hour, day, week
+// or month, choosing the source table from the granularity and range
+// GET /api/v1/usage/organization/:orgId/timeseries
+func (h *UsageHandler) GetOrganizationTimeseries(c *gin.Context) {
+	orgID := c.Param("orgId")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization id is required"})
+		return
+	}
+
+	startDate, endDate, err := h.parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Hourly buckets for up to a week, daily beyond
+	granularity := c.Query("granularity")
+	if granularity == "" {
+		granularity = "hour"
+		if endDate.Sub(startDate) > 7*24*time.Hour {
+			granularity = "day"
+		}
+	}
+
+	groupBy, filters, ok := parseUsageDimensions(c)
+	if !ok {
+		return
+	}
+	if _, err := repository.UsageTimeseriesSource(granularity, groupBy, filters, startDate, time.Now().UTC()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if endDate.Sub(startDate)/repository.Granularities[granularity] > maxTimeseriesBuckets {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many buckets; use a coarser granularity or a shorter range"})
+		return
+	}
+
+	metrics, ok := parseUsageMetrics(c)
+	if !ok {
+		return
+	}
+
+	params := models.UsageQueryParams{
+		StartDate:   startDate,
+		EndDate:     endDate,
+		ChainSlug:   c.Query("chain"),
+		Aggregation: granularity,
+		Metrics:     metrics,
+		GroupBy:     groupBy,
+		Filters:     filters,
+	}
+
+	timeseries, err := h.clickhouseRepo.GetUsageTimeseries(c.Request.Context(), orgID, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get usage timeseries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, timeseries)
+}
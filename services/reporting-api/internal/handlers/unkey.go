@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/unkey"
+)
+
+type UnkeyHandler struct {
+	client *unkey.Client
+}
+
+func NewUnkeyHandler(client *unkey.Client) *UnkeyHandler {
+	return &UnkeyHandler{client: client}
+}
+
+// PurgeKey drops a revoked key from the verify cache shared with Kong.
+// purged is false when no verification result of the key was cached.
+// POST /api/v1/admin/unkey/keys/:keyId/purge
+func (h *UnkeyHandler) PurgeKey(c *gin.Context) {
+	keyID := c.Param("keyId")
+	if keyID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key id is required"})
+		return
+	}
+
+	purged, err := h.client.PurgeKeyID(c.Request.Context(), keyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to purge key cache"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"key_id": keyID,
+		"purged": purged,
+	})
+}
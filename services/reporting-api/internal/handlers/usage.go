@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/middleware"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
 	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+	"github.com/hoodrun/rpc-gateway/reporting-api/pkg/utils"
 )
 
 type UsageHandler struct {
@@ -37,6 +41,21 @@ func (h *UsageHandler) GetOrganizationUsageSummary(c *gin.Context) {
 		return
 	}
 
+	// Resolve the comparison window up front so bad input fails fast
+	compare := c.Query("compare")
+	var compareStart, compareEnd time.Time
+	if compare != "" {
+		compareStart, compareEnd, err = utils.ComparisonPeriod(startDate, endDate, compare)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if startDate.Location() != time.UTC && time.Since(compareStart) > repository.ZonedUsageRetention {
+			c.JSON(http.StatusBadRequest, gin.H{"error": (&TimezoneRangeError{}).Error()})
+			return
+		}
+	}
+
 	// Verify organization exists
 	org, err := h.postgresRepo.GetOrganization(c.Request.Context(), orgID)
 	if err != nil {
@@ -65,6 +84,28 @@ func (h *UsageHandler) GetOrganizationUsageSummary(c *gin.Context) {
 		}
 	}
 
+	// Same aggregation over the comparison window, overall and per chain
+	if compare != "" {
+		ctx := c.Request.Context()
+		currentChains := summary.ByChain
+		if !includeBreakdown {
+			currentChains, err = h.clickhouseRepo.GetUsageByChain(ctx, orgID, startDate, endDate)
+		}
+		var previous *models.UsageSummary
+		var previousChains []models.ChainUsage
+		if err == nil {
+			previous, err = h.clickhouseRepo.GetUsageSummary(ctx, orgID, compareStart, compareEnd)
+		}
+		if err == nil {
+			previousChains, err = h.clickhouseRepo.GetUsageByChain(ctx, orgID, compareStart, compareEnd)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get usage comparison"})
+			return
+		}
+		summary.Comparison = newUsageComparison(compare, summary.Summary, currentChains, previous, previousChains)
+	}
+
 	// Add organization info to response
 	response := gin.H{
 		"organization": gin.H{
@@ -101,11 +142,8 @@ func (h *UsageHandler) GetOrganizationDailyUsage(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"organization_id": orgID,
-		"period": gin.H{
-			"start": startDate,
-			"end":   endDate,
-		},
-		"daily_usage": dailyUsage,
+		"period":          models.NewPeriod(startDate, endDate),
+		"daily_usage":     dailyUsage,
 	})
 }
 
@@ -141,11 +179,8 @@ func (h *UsageHandler) GetOrganizationHourlyUsage(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"organization_id": orgID,
 		"chain_slug":      chainSlug,
-		"period": gin.H{
-			"start": startDate,
-			"end":   endDate,
-		},
-		"hourly_usage": hourlyUsage,
+		"period":          models.NewPeriod(startDate, endDate),
+		"hourly_usage":    hourlyUsage,
 	})
 }
 
@@ -172,11 +207,8 @@ func (h *UsageHandler) GetOrganizationUsageByChain(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"organization_id": orgID,
-		"period": gin.H{
-			"start": startDate,
-			"end":   endDate,
-		},
-		"by_chain": chainUsage,
+		"period":          models.NewPeriod(startDate, endDate),
+		"by_chain":        chainUsage,
 	})
 }
 
@@ -195,7 +227,28 @@ func (h *UsageHandler) GetAPIKeyUsage(c *gin.Context) {
 		return
 	}
 
-	keyUsage, err := h.clickhouseRepo.GetAPIKeyUsage(c.Request.Context(), keyPrefix, startDate, endDate)
+	// Prefixes are not unique across organizations: tenants read their own
+	// key, and the admin names the organization when a prefix is shared
+	orgID := c.Query("organization_id")
+	if principal, ok := middleware.GetPrincipal(c); ok && !principal.IsAdmin {
+		orgID = principal.OrganizationID
+	}
+	if orgID == "" {
+		orgID, err = h.postgresRepo.GetAPIKeyOrganization(c.Request.Context(), keyPrefix)
+		if errors.Is(err, repository.ErrAmbiguousKeyPrefix) {
+			c.JSON(http.StatusConflict, gin.H{"error": "key prefix is used by several organizations; set organization_id"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return
+		}
+	} else if owned, err := h.postgresRepo.OrganizationHasAPIKey(c.Request.Context(), orgID, keyPrefix); err != nil || !owned {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+
+	keyUsage, err := h.clickhouseRepo.GetAPIKeyUsage(c.Request.Context(), orgID, keyPrefix, startDate, endDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get key usage"})
 		return
@@ -204,37 +257,28 @@ func (h *UsageHandler) GetAPIKeyUsage(c *gin.Context) {
 	c.JSON(http.StatusOK, keyUsage)
 }
 
-// Helper function to parse date range from query parameters
+// Helper function to parse date range from query parameters. start/end take
+// YYYY-MM-DD dates or RFC3339 timestamps (start_date/end_date are accepted as
+// aliases); tz sets the zone of day boundaries and buckets, UTC by default.
 func (h *UsageHandler) parseDateRange(c *gin.Context) (time.Time, time.Time, error) {
-	// Default to current month if not specified
-	now := time.Now().UTC()
-	defaultStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
-	defaultEnd := now
-
-	startDateStr := c.Query("start_date")
-	endDateStr := c.Query("end_date")
-
-	var startDate, endDate time.Time
-	var err error
+	loc, err := utils.LoadTimezone(c.Query("tz"))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
 
-	if startDateStr != "" {
-		startDate, err = time.Parse("2006-01-02", startDateStr)
-		if err != nil {
-			return time.Time{}, time.Time{}, err
-		}
-	} else {
-		startDate = defaultStart
+	startStr := c.Query("start")
+	if startStr == "" {
+		startStr = c.Query("start_date")
+	}
+	endStr := c.Query("end")
+	if endStr == "" {
+		endStr = c.Query("end_date")
 	}
 
-	if endDateStr != "" {
-		endDate, err = time.Parse("2006-01-02", endDateStr)
-		if err != nil {
-			return time.Time{}, time.Time{}, err
-		}
-		// Set to end of day
-		endDate = endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-	} else {
-		endDate = defaultEnd
+	// Default to current month if not specified
+	startDate, endDate, err := utils.ParseDateRange(startStr, endStr, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
 	}
 
 	// Validate date range
@@ -247,6 +291,12 @@ func (h *UsageHandler) parseDateRange(c *gin.Context) (time.Time, time.Time, err
 		return time.Time{}, time.Time{}, &DateRangeTooLargeError{}
 	}
 
+	// usage_daily holds UTC days, so other zones are served from
+	// usage_hourly and limited to its retention
+	if loc != time.UTC && time.Since(startDate) > repository.ZonedUsageRetention {
+		return time.Time{}, time.Time{}, &TimezoneRangeError{}
+	}
+
 	return startDate, endDate, nil
 }
 
@@ -262,6 +312,12 @@ func (e *DateRangeTooLargeError) Error() string {
 	return "date range cannot exceed 1 year"
 }
 
+type TimezoneRangeError struct{}
+
+func (e *TimezoneRangeError) Error() string {
+	return "time zones other than UTC are limited to the last 90 days"
+}
+
 // parseLimit helper
 func parseLimit(c *gin.Context, defaultLimit, maxLimit int) int {
 	limitStr := c.Query("limit")
@@ -1,16 +1,61 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
 )
 
-// AuthMiddleware provides simple API key authentication for Phase 6
-// In Phase 7-8, this will be replaced with Unkey integration and JWT tokens
-func AuthMiddleware(cfg *config.AuthConfig) gin.HandlerFunc {
+// Context keys set by AuthMiddleware
+const (
+	ContextKeyPrincipal      = "principal"
+	ContextKeyOrganizationID = "organization_id"
+)
+
+// Principal identifies the caller of an authenticated request
+type Principal struct {
+	OrganizationID string
+	UserID         string
+	ConsumerID     string
+	KeyPrefix      string
+	Plan           string // plan slug of an Unkey key's metadata, if any
+	Role           string
+	Scopes         []string
+	IsAdmin        bool
+}
+
+// CanAccessOrganization reports whether the principal may read data of orgID
+func (p *Principal) CanAccessOrganization(orgID string) bool {
+	return p.IsAdmin || p.OrganizationID == orgID
+}
+
+// APIKeyResolver resolves a customer API key to its metadata
+type APIKeyResolver interface {
+	ResolveAPIKey(ctx context.Context, apiKey string) (*models.APIKey, error)
+}
+
+// APIKeyOwnerLookup reports whether an organization owns a key prefix.
+// Prefixes are not unique across organizations.
+type APIKeyOwnerLookup interface {
+	OrganizationHasAPIKey(ctx context.Context, orgID, keyPrefix string) (bool, error)
+}
+
+// TokenVerifier verifies a bearer token (JWT) and resolves its principal
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (*Principal, error)
+}
+
+// AuthMiddleware authenticates the admin key, portal JWTs and tenant-scoped
+// customer API keys. The admin key is a super-user that can read every tenant;
+// JWTs and customer keys are resolved to their organization, which is stored
+// in the gin context. tokens may be nil when JWT auth is disabled.
+func AuthMiddleware(cfg *config.AuthConfig, keys APIKeyResolver, tokens TokenVerifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Skip auth if disabled (development mode)
 		if !cfg.Enabled {
@@ -30,7 +75,7 @@ func AuthMiddleware(cfg *config.AuthConfig) gin.HandlerFunc {
 
 		// Expected format: "Bearer <token>"
 		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "invalid authorization header format, expected: Bearer <token>",
 			})
@@ -40,9 +85,57 @@ func AuthMiddleware(cfg *config.AuthConfig) gin.HandlerFunc {
 
 		token := parts[1]
 
-		// Simple token validation for Phase 6
-		// TODO Phase 7: Replace with Unkey API key verification
-		if token != cfg.AdminAPIKey {
+		// Admin key can see every tenant
+		if cfg.AdminAPIKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminAPIKey)) == 1 {
+			setPrincipal(c, &Principal{
+				Scopes:  []string{ScopeAdmin},
+				IsAdmin: true,
+			})
+			c.Next()
+			return
+		}
+
+		// Portal session tokens
+		if tokens != nil && LooksLikeJWT(token) {
+			principal, err := tokens.VerifyToken(c.Request.Context(), token)
+			if errors.Is(err, ErrInvalidToken) {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "invalid token",
+				})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "failed to verify token",
+				})
+				c.Abort()
+				return
+			}
+
+			setPrincipal(c, principal)
+			c.Next()
+			return
+		}
+
+		if keys == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid api key",
+			})
+			c.Abort()
+			return
+		}
+
+		// With Unkey disabled, keys are verified against their hash in Postgres
+		key, err := keys.ResolveAPIKey(c.Request.Context(), token)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to verify api key",
+			})
+			c.Abort()
+			return
+		}
+		if key == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "invalid api key",
 			})
@@ -50,11 +143,88 @@ func AuthMiddleware(cfg *config.AuthConfig) gin.HandlerFunc {
 			return
 		}
 
-		// Token is valid, continue
+		// API keys are not tied to a user and act with the configured role
+		setPrincipal(c, &Principal{
+			OrganizationID: key.OrganizationID,
+			ConsumerID:     key.ConsumerID,
+			KeyPrefix:      key.KeyPrefix,
+			Plan:           key.Plan,
+			Role:           cfg.APIKeyRole,
+			Scopes:         keyScopes(cfg.APIKeyRole, key.Scopes),
+		})
+		c.Next()
+	}
+}
+
+// keyScopes returns the scopes of an API key acting with role. Scopes the key
+// carries (Unkey keys) narrow the role's scopes but never widen them.
+func keyScopes(role string, granted []string) []string {
+	scopes := ScopesForRole(role)
+	if len(granted) == 0 {
+		return scopes
+	}
+
+	narrowed := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		for _, g := range granted {
+			if g == scope {
+				narrowed = append(narrowed, scope)
+				break
+			}
+		}
+	}
+	return narrowed
+}
+
+// TenantScopeMiddleware rejects requests for an :orgId, or a :keyPrefix no key
+// of the authenticated caller's organization has
+func TenantScopeMiddleware(keys APIKeyOwnerLookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok || principal.IsAdmin {
+			// Auth disabled or super-user
+			c.Next()
+			return
+		}
+
+		if orgID := c.Param("orgId"); orgID != "" && !principal.CanAccessOrganization(orgID) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "access to this organization is not allowed",
+			})
+			c.Abort()
+			return
+		}
+
+		if keyPrefix := c.Param("keyPrefix"); keyPrefix != "" {
+			owned, err := keys.OrganizationHasAPIKey(c.Request.Context(), principal.OrganizationID, keyPrefix)
+			if err != nil || !owned {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "access to this api key is not allowed",
+				})
+				c.Abort()
+				return
+			}
+		}
+
 		c.Next()
 	}
 }
 
+// GetPrincipal returns the authenticated caller, if any
+func GetPrincipal(c *gin.Context) (*Principal, bool) {
+	value, exists := c.Get(ContextKeyPrincipal)
+	if !exists {
+		return nil, false
+	}
+	principal, ok := value.(*Principal)
+	return principal, ok
+}
+
+func setPrincipal(c *gin.Context, principal *Principal) {
+	c.Set(ContextKeyPrincipal, principal)
+	c.Set(ContextKeyOrganizationID, principal.OrganizationID)
+}
+
 // CORSMiddleware handles CORS for browser-based clients
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -62,6 +232,7 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
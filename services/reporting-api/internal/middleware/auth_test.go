@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// fakeKeyOwners maps key prefixes to the organizations that have a key with it
+type fakeKeyOwners map[string][]string
+
+func (o fakeKeyOwners) OrganizationHasAPIKey(_ context.Context, orgID, keyPrefix string) (bool, error) {
+	for _, owner := range o[keyPrefix] {
+		if owner == orgID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestTenantScopeMiddlewareKeyPrefix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// rk_shared is the prefix of a key of each organization
+	owners := fakeKeyOwners{
+		"rk_shared": {"org-2", "org-1"},
+		"rk_other":  {"org-2"},
+	}
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		path      string
+		want      int
+	}{
+		{"own key", &Principal{OrganizationID: "org-1"}, "/usage/key/rk_shared", http.StatusOK},
+		{"prefix shared with another organization", &Principal{OrganizationID: "org-2"}, "/usage/key/rk_shared", http.StatusOK},
+		{"key of another organization", &Principal{OrganizationID: "org-1"}, "/usage/key/rk_other", http.StatusForbidden},
+		{"unknown key", &Principal{OrganizationID: "org-1"}, "/usage/key/rk_missing", http.StatusForbidden},
+		{"admin", &Principal{IsAdmin: true}, "/usage/key/rk_other", http.StatusOK},
+		{"own organization", &Principal{OrganizationID: "org-1"}, "/usage/organization/org-1/summary", http.StatusOK},
+		{"other organization", &Principal{OrganizationID: "org-1"}, "/usage/organization/org-2/summary", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				setPrincipal(c, tt.principal)
+				c.Next()
+			})
+			router.Use(TenantScopeMiddleware(owners))
+			ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+			router.GET("/usage/key/:keyPrefix", ok)
+			router.GET("/usage/organization/:orgId/summary", ok)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+// fakeKeys resolves API keys from a map
+type fakeKeys map[string]*models.APIKey
+
+func (k fakeKeys) ResolveAPIKey(_ context.Context, apiKey string) (*models.APIKey, error) {
+	return k[apiKey], nil
+}
+
+func TestAuthMiddlewareAPIKeyPlanAndScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	keys := fakeKeys{
+		"sk_role":   {OrganizationID: "org-1"},
+		"sk_scoped": {OrganizationID: "org-1", Plan: "pro", Scopes: []string{ScopeUsageRead, ScopeAdmin}},
+	}
+	cfg := &config.AuthConfig{Enabled: true, APIKeyRole: RoleAdmin}
+
+	tests := []struct {
+		key        string
+		plan       string
+		wantScopes []string
+	}{
+		{"sk_role", "", ScopesForRole(RoleAdmin)},
+		// Key scopes narrow the role's scopes; admin:* is not granted
+		{"sk_scoped", "pro", []string{ScopeUsageRead}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			var principal *Principal
+			router := gin.New()
+			router.Use(AuthMiddleware(cfg, keys, nil))
+			router.GET("/", func(c *gin.Context) {
+				principal, _ = GetPrincipal(c)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK || principal == nil {
+				t.Fatalf("GET = %d", rec.Code)
+			}
+			if principal.Plan != tt.plan {
+				t.Errorf("plan = %q, want %q", principal.Plan, tt.plan)
+			}
+			if fmt.Sprint(principal.Scopes) != fmt.Sprint(tt.wantScopes) {
+				t.Errorf("scopes = %v, want %v", principal.Scopes, tt.wantScopes)
+			}
+		})
+	}
+}
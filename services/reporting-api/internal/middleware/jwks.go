@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// JWKS holds token verification keys loaded from a file path or URL
+// and refreshed periodically
+type JWKS struct {
+	source     string
+	httpClient *http.Client
+	logger     *zap.Logger
+
+	mu   sync.RWMutex
+	keys map[string]crypto.PublicKey
+
+	stop chan struct{}
+	once sync.Once
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewJWKS loads the key set from source (file path or http(s) URL).
+// A refresh interval of zero disables periodic reloading.
+func NewJWKS(source string, refresh time.Duration, logger *zap.Logger) (*JWKS, error) {
+	j := &JWKS{
+		source:     source,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+		stop:       make(chan struct{}),
+	}
+
+	if err := j.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	if refresh > 0 {
+		go j.refreshLoop(refresh)
+	}
+
+	return j, nil
+}
+
+// Key returns the public key for a key id
+func (j *JWKS) Key(kid string) (crypto.PublicKey, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	// Tokens without a kid are accepted only for single-key sets
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+// Refresh reloads the key set; the previous keys are kept on failure
+func (j *JWKS) Refresh(ctx context.Context) error {
+	data, err := j.read(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read jwks: %w", err)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	// Identity providers publish encryption keys and key types we do not
+	// verify with (e.g. OKP) next to the signing keys; those are skipped
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			j.skipKey(jwk, fmt.Errorf("not a signing key (use %q)", jwk.Use))
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			j.skipKey(jwk, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("jwks contains no usable signing keys")
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.mu.Unlock()
+
+	return nil
+}
+
+func (j *JWKS) skipKey(jwk jsonWebKey, reason error) {
+	if j.logger != nil {
+		j.logger.Debug("Skipping JWK",
+			zap.String("kid", jwk.Kid),
+			zap.String("kty", jwk.Kty),
+			zap.Error(reason),
+		)
+	}
+}
+
+// Close stops the refresh goroutine
+func (j *JWKS) Close() {
+	j.once.Do(func() { close(j.stop) })
+}
+
+func (j *JWKS) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := j.Refresh(ctx); err != nil && j.logger != nil {
+				j.logger.Warn("Failed to refresh JWKS, keeping previous keys", zap.Error(err))
+			}
+			cancel()
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+func (j *JWKS) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(j.source, "http://") && !strings.HasPrefix(j.source, "https://") {
+		return os.ReadFile(j.source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := j.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
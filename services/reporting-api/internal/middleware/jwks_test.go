@@ -0,0 +1,285 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// testKey is a signing key with the JWK of its public half
+type testKey struct {
+	kid     string
+	private crypto.Signer
+	jwk     map[string]string
+}
+
+func newRSAKey(t *testing.T, kid string) *testKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	return &testKey{
+		kid:     kid,
+		private: key,
+		jwk: map[string]string{
+			"kid": kid,
+			"kty": "RSA",
+			"use": "sig",
+			"n":   encodeBigInt(key.N),
+			"e":   encodeBigInt(big.NewInt(int64(key.E))),
+		},
+	}
+}
+
+func newECKey(t *testing.T, kid string) *testKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key: %v", err)
+	}
+	return &testKey{
+		kid:     kid,
+		private: key,
+		jwk: map[string]string{
+			"kid": kid,
+			"kty": "EC",
+			"use": "sig",
+			"crv": "P-256",
+			"x":   encodeBigInt(key.X),
+			"y":   encodeBigInt(key.Y),
+		},
+	}
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func jwksDocument(t *testing.T, keys ...*testKey) []byte {
+	t.Helper()
+	set := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+	for _, key := range keys {
+		set.Keys = append(set.Keys, key.jwk)
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+	return data
+}
+
+// jwksServer serves a key set that tests can replace to simulate rotation
+type jwksServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	document []byte
+	status   int
+	fetches  atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, keys ...*testKey) *jwksServer {
+	t.Helper()
+	s := &jwksServer{document: jwksDocument(t, keys...), status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.WriteHeader(s.status)
+		w.Write(s.document)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) serve(status int, document []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+	if document != nil {
+		s.document = document
+	}
+}
+
+func TestJWKSLoadsFromURL(t *testing.T) {
+	rsaKey, ecKey := newRSAKey(t, "rsa-1"), newECKey(t, "ec-1")
+	server := newJWKSServer(t, rsaKey, ecKey)
+
+	jwks, err := NewJWKS(server.URL, 0, nil)
+	if err != nil {
+		t.Fatalf("NewJWKS: %v", err)
+	}
+	defer jwks.Close()
+
+	if key, ok := jwks.Key("rsa-1"); !ok || !key.(*rsa.PublicKey).Equal(rsaKey.private.Public()) {
+		t.Errorf("rsa-1 not loaded")
+	}
+	if key, ok := jwks.Key("ec-1"); !ok || !key.(*ecdsa.PublicKey).Equal(ecKey.private.Public()) {
+		t.Errorf("ec-1 not loaded")
+	}
+	if _, ok := jwks.Key("missing"); ok {
+		t.Errorf("unknown kid resolved")
+	}
+	// An empty kid is ambiguous with two keys
+	if _, ok := jwks.Key(""); ok {
+		t.Errorf("empty kid resolved in a two-key set")
+	}
+}
+
+func TestJWKSLoadsFromFile(t *testing.T) {
+	key := newRSAKey(t, "file-1")
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksDocument(t, key), 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+
+	jwks, err := NewJWKS(path, 0, nil)
+	if err != nil {
+		t.Fatalf("NewJWKS: %v", err)
+	}
+	defer jwks.Close()
+
+	// A single-key set also serves tokens without a kid
+	if _, ok := jwks.Key(""); !ok {
+		t.Errorf("empty kid not resolved in a single-key set")
+	}
+}
+
+func TestJWKSRejectsInvalidSets(t *testing.T) {
+	encryptionOnly := newRSAKey(t, "enc")
+	encryptionOnly.jwk["use"] = "enc"
+
+	badCurve := newECKey(t, "bad")
+	badCurve.jwk["y"] = badCurve.jwk["x"]
+
+	tests := []struct {
+		name     string
+		status   int
+		document []byte
+	}{
+		{"server error", http.StatusInternalServerError, []byte(`{}`)},
+		{"not json", http.StatusOK, []byte(`not json`)},
+		{"no keys", http.StatusOK, []byte(`{"keys":[]}`)},
+		{"no signing keys", http.StatusOK, jwksDocument(t, encryptionOnly)},
+		{"point not on curve", http.StatusOK, jwksDocument(t, badCurve)},
+		{"unsupported key type", http.StatusOK, []byte(`{"keys":[{"kid":"a","kty":"oct","k":"c2VjcmV0"}]}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newJWKSServer(t)
+			server.serve(tt.status, tt.document)
+			if _, err := NewJWKS(server.URL, 0, nil); err == nil {
+				t.Fatalf("NewJWKS succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestJWKSSkipsUnusableKeys(t *testing.T) {
+	signing := newRSAKey(t, "rsa-1")
+	encryption := newRSAKey(t, "enc")
+	encryption.jwk["use"] = "enc"
+	badCurve := newECKey(t, "bad")
+	badCurve.jwk["y"] = badCurve.jwk["x"]
+	okp := &testKey{jwk: map[string]string{"kid": "ed-1", "kty": "OKP", "crv": "Ed25519", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}}
+	symmetric := &testKey{jwk: map[string]string{"kid": "oct-1", "kty": "oct", "k": "c2VjcmV0"}}
+	malformed := &testKey{jwk: map[string]string{"kid": "rsa-bad", "kty": "RSA", "n": "!", "e": "AQAB"}}
+	server := newJWKSServer(t, okp, symmetric, encryption, signing, badCurve, malformed)
+
+	jwks, err := NewJWKS(server.URL, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewJWKS: %v", err)
+	}
+	defer jwks.Close()
+
+	if _, ok := jwks.Key("rsa-1"); !ok {
+		t.Errorf("signing key not loaded")
+	}
+	for _, kid := range []string{"oct-1", "ed-1", "enc", "bad", "rsa-bad"} {
+		if _, ok := jwks.Key(kid); ok {
+			t.Errorf("unusable key %s loaded", kid)
+		}
+	}
+	// The signing key is the only key, so it also serves tokens without a kid
+	if _, ok := jwks.Key(""); !ok {
+		t.Errorf("empty kid not resolved")
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+	oldKey, newKey := newRSAKey(t, "2024"), newRSAKey(t, "2025")
+	server := newJWKSServer(t, oldKey)
+
+	jwks, err := NewJWKS(server.URL, 0, nil)
+	if err != nil {
+		t.Fatalf("NewJWKS: %v", err)
+	}
+	defer jwks.Close()
+
+	server.serve(http.StatusOK, jwksDocument(t, newKey))
+	if err := jwks.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if _, ok := jwks.Key("2025"); !ok {
+		t.Errorf("rotated key not loaded")
+	}
+	if _, ok := jwks.Key("2024"); ok {
+		t.Errorf("retired key still loaded")
+	}
+
+	// A failed refresh keeps the current keys
+	server.serve(http.StatusBadGateway, nil)
+	if err := jwks.Refresh(context.Background()); err == nil {
+		t.Fatalf("Refresh succeeded against a failing server")
+	}
+	if _, ok := jwks.Key("2025"); !ok {
+		t.Errorf("keys dropped after a failed refresh")
+	}
+}
+
+func TestJWKSPeriodicRefresh(t *testing.T) {
+	oldKey, newKey := newECKey(t, "a"), newECKey(t, "b")
+	server := newJWKSServer(t, oldKey)
+
+	jwks, err := NewJWKS(server.URL, 10*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("NewJWKS: %v", err)
+	}
+	defer jwks.Close()
+
+	server.serve(http.StatusOK, jwksDocument(t, newKey))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok := jwks.Key("b"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("rotated key not picked up by the refresh loop after %d fetches", server.fetches.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	jwks.Close()
+	// Close is safe to call twice
+	jwks.Close()
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// ErrInvalidToken is returned for tokens that fail verification
+var ErrInvalidToken = errors.New("invalid token")
+
+// UserLookup resolves a user by id
+type UserLookup interface {
+	GetUser(ctx context.Context, userID string) (*models.User, error)
+}
+
+// TokenClaims are the verified claims used for authorization
+type TokenClaims struct {
+	Subject        string
+	OrganizationID string
+	ExpiresAt      time.Time
+}
+
+// JWTVerifier validates RS256/ES256 bearer tokens issued by the customer portal
+type JWTVerifier struct {
+	cfg    *config.JWTConfig
+	keys   *JWKS
+	users  UserLookup
+	parser *jwt.Parser
+}
+
+// NewJWTVerifier creates a verifier checking issuer, audience, expiry and the
+// organization claim; users resolves the token subject to its role
+func NewJWTVerifier(cfg *config.JWTConfig, keys *JWKS, users UserLookup) *JWTVerifier {
+	return &JWTVerifier{
+		cfg:   cfg,
+		keys:  keys,
+		users: users,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{"RS256", "ES256"}),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(time.Duration(cfg.LeewaySeconds)*time.Second),
+		),
+	}
+}
+
+// LooksLikeJWT reports whether a bearer token has the compact JWS shape
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// ParseClaims verifies the token signature and standard claims
+func (v *JWTVerifier) ParseClaims(token string) (*TokenClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := v.keys.Key(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+
+	orgID, _ := claims[v.cfg.OrgClaim].(string)
+	if orgID == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.cfg.OrgClaim)
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil, fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+
+	return &TokenClaims{
+		Subject:        subject,
+		OrganizationID: orgID,
+		ExpiresAt:      exp.Time,
+	}, nil
+}
+
+// VerifyToken verifies the token and resolves its subject to a principal.
+// The role always comes from the users table, never from the token.
+func (v *JWTVerifier) VerifyToken(ctx context.Context, token string) (*Principal, error) {
+	claims, err := v.ParseClaims(token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := v.users.GetUser(ctx, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Status != "active" {
+		return nil, fmt.Errorf("%w: unknown or inactive user", ErrInvalidToken)
+	}
+	if user.OrganizationID != claims.OrganizationID {
+		return nil, fmt.Errorf("%w: user does not belong to organization", ErrInvalidToken)
+	}
+
+	return &Principal{
+		OrganizationID: user.OrganizationID,
+		UserID:         user.ID,
+		Role:           user.Role,
+		Scopes:         ScopesForRole(user.Role),
+	}, nil
+}
@@ -0,0 +1,228 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+const (
+	testIssuer   = "https://portal.example.com"
+	testAudience = "reporting-api"
+	testOrgID    = "org-1"
+	testUserID   = "user-1"
+)
+
+type fakeUsers map[string]*models.User
+
+func (u fakeUsers) GetUser(_ context.Context, userID string) (*models.User, error) {
+	return u[userID], nil
+}
+
+func testJWTConfig(jwksURL string) *config.JWTConfig {
+	return &config.JWTConfig{
+		Enabled:       true,
+		Issuer:        testIssuer,
+		Audience:      testAudience,
+		JWKSURL:       jwksURL,
+		OrgClaim:      "org_id",
+		LeewaySeconds: 30,
+	}
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":    testIssuer,
+		"aud":    testAudience,
+		"sub":    testUserID,
+		"org_id": testOrgID,
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func signToken(t *testing.T, key *testKey, method jwt.SigningMethod, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if key.kid != "" {
+		token.Header["kid"] = key.kid
+	}
+	signed, err := token.SignedString(key.private)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func newTestVerifier(t *testing.T, users fakeUsers, keys ...*testKey) *JWTVerifier {
+	t.Helper()
+	server := newJWKSServer(t, keys...)
+	jwks, err := NewJWKS(server.URL, 0, nil)
+	if err != nil {
+		t.Fatalf("NewJWKS: %v", err)
+	}
+	t.Cleanup(jwks.Close)
+	return NewJWTVerifier(testJWTConfig(server.URL), jwks, users)
+}
+
+func TestParseClaims(t *testing.T) {
+	rsaKey, ecKey := newRSAKey(t, "rsa"), newECKey(t, "ec")
+	unknownKey := newRSAKey(t, "unknown")
+	verifier := newTestVerifier(t, nil, rsaKey, ecKey)
+
+	with := func(mutate func(jwt.MapClaims)) jwt.MapClaims {
+		claims := validClaims()
+		mutate(claims)
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		key     *testKey
+		method  jwt.SigningMethod
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{"rs256", rsaKey, jwt.SigningMethodRS256, validClaims(), false},
+		{"es256", ecKey, jwt.SigningMethodES256, validClaims(), false},
+		{"audience list", rsaKey, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["aud"] = []string{"other", testAudience} }), false},
+		{"expired within leeway", rsaKey, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-10 * time.Second).Unix() }), false},
+		{"expired", rsaKey, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }), true},
+		{"missing exp", rsaKey, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { delete(c, "exp") }), true},
+		{"wrong issuer", rsaKey, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }), true},
+		{"wrong audience", rsaKey, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["aud"] = "billing" }), true},
+		{"missing sub", rsaKey, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { delete(c, "sub") }), true},
+		{"missing org claim", rsaKey, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { delete(c, "org_id") }), true},
+		{"not yet valid", rsaKey, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() }), true},
+		{"unknown kid", unknownKey, jwt.SigningMethodRS256, validClaims(), true},
+		{"kid of another key", &testKey{kid: "ec", private: rsaKey.private}, jwt.SigningMethodRS256, validClaims(), true},
+		{"rs512 not allowed", rsaKey, jwt.SigningMethodRS512, validClaims(), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.ParseClaims(signToken(t, tt.key, tt.method, tt.claims))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("err = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseClaims: %v", err)
+			}
+			if claims.Subject != testUserID || claims.OrganizationID != testOrgID {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestParseClaimsRejectsUnsignedToken(t *testing.T) {
+	verifier := newTestVerifier(t, nil, newRSAKey(t, "rsa"))
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	if _, err := verifier.ParseClaims(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	key := newRSAKey(t, "rsa")
+	users := fakeUsers{
+		testUserID:      {ID: testUserID, OrganizationID: testOrgID, Role: "member", Status: "active"},
+		"user-inactive": {ID: "user-inactive", OrganizationID: testOrgID, Role: "owner", Status: "suspended"},
+		"user-other":    {ID: "user-other", OrganizationID: "org-2", Role: "owner", Status: "active"},
+	}
+	verifier := newTestVerifier(t, users, key)
+
+	tests := []struct {
+		name     string
+		subject  string
+		wantRole string
+		wantErr  bool
+	}{
+		{"active user", testUserID, "member", false},
+		{"unknown user", "user-missing", "", true},
+		{"inactive user", "user-inactive", "", true},
+		{"user of another organization", "user-other", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			claims["sub"] = tt.subject
+			// A role claim in the token is never trusted
+			claims["role"] = "owner"
+
+			principal, err := verifier.VerifyToken(context.Background(), signToken(t, key, jwt.SigningMethodRS256, claims))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("err = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyToken: %v", err)
+			}
+			if principal.Role != tt.wantRole || principal.OrganizationID != testOrgID || principal.UserID != tt.subject {
+				t.Errorf("principal = %+v", principal)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareJWT(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newECKey(t, "ec")
+	users := fakeUsers{
+		testUserID: {ID: testUserID, OrganizationID: testOrgID, Role: "owner", Status: "active"},
+	}
+	verifier := newTestVerifier(t, users, key)
+	cfg := &config.AuthConfig{Enabled: true, AdminAPIKey: "admin-secret", APIKeyRole: "admin"}
+
+	router := gin.New()
+	router.GET("/whoami", AuthMiddleware(cfg, nil, verifier), func(c *gin.Context) {
+		principal, _ := GetPrincipal(c)
+		c.JSON(http.StatusOK, gin.H{"org": principal.OrganizationID, "role": principal.Role})
+	})
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"valid token", signToken(t, key, jwt.SigningMethodES256, validClaims()), http.StatusOK},
+		{"expired token", signToken(t, key, jwt.SigningMethodES256, expired), http.StatusUnauthorized},
+		{"tampered token", signToken(t, key, jwt.SigningMethodES256, validClaims()) + "x", http.StatusUnauthorized},
+		{"admin key", "admin-secret", http.StatusOK},
+		{"customer key without resolver", "sk_live_abc", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+}
@@ -1,37 +1,126 @@
 package middleware
 
 import (
+	"context"
+	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
 )
 
+// Window names (match rate_limit_events.limit_type)
+const (
+	WindowSecond = "second"
+	WindowMinute = "minute"
+	WindowHour   = "hour"
+	WindowDay    = "day"
+)
+
+// Limit describes one window as a token bucket: Rate tokens per Window,
+// holding up to Burst tokens
+type Limit struct {
+	Name   string
+	Rate   int
+	Window time.Duration
+	Burst  int
+	// Scope separates buckets of the same window, e.g. "chain:eth-mainnet";
+	// empty for plan-wide windows
+	Scope string
+	// PerRequest windows are charged one token per request instead of the
+	// request's compute units
+	PerRequest bool
+}
+
+// bucket identifies the limit's bucket among a key's buckets
+func (l Limit) bucket() string {
+	id := l.Name
+	if l.PerRequest {
+		id += ":requests"
+	}
+	if l.Scope != "" {
+		id = l.Scope + ":" + id
+	}
+	return id
+}
+
+// charge is the number of tokens a request of cost compute units takes
+func (l Limit) charge(cost int) int {
+	if l.PerRequest {
+		return 1
+	}
+	return cost
+}
+
+// capacity is the bucket size (never below the window rate)
+func (l Limit) capacity() int {
+	if l.Burst < l.Rate {
+		return l.Rate
+	}
+	return l.Burst
+}
+
+// Result is the outcome of a rate limit check across all windows.
+// It describes the tripped window when denied, otherwise the most
+// constraining window.
+type Result struct {
+	Allowed    bool
+	Window     string // window name (second, minute, hour, day)
+	Scope      string // scope of that window, empty for plan-wide windows
+	Rate       int    // configured rate of that window
+	Limit      int    // bucket capacity of that window
+	Remaining  int
+	Used       int           // tokens consumed in that window
+	Reset      time.Duration // time until the bucket is full again
+	RetryAfter time.Duration // time until the request would be allowed (when denied)
+}
+
+// Limiter decides whether a request under key fits within every window,
+// charging cost tokens (compute units) to each of them, or one token to
+// PerRequest windows.
+// RateLimiter (in-process) and RedisLimiter (shared across replicas) implement it.
+type Limiter interface {
+	Take(ctx context.Context, key string, limits []Limit, cost int) (Result, error)
+}
+
 // RateLimiter implements a simple in-memory token bucket rate limiter
 type RateLimiter struct {
-	tokens    map[string]*tokenBucket
-	mu        sync.RWMutex
-	rate      int           // tokens per window
-	window    time.Duration // time window
-	maxTokens int           // bucket size
+	tokens       map[string]*keyBuckets
+	mu           sync.RWMutex
+	defaultLimit Limit
+}
+
+// keyBuckets holds one bucket per window; a single lock keeps the
+// check-then-charge across windows atomic
+type keyBuckets struct {
+	buckets    map[string]*tokenBucket
+	lastSeen   time.Time
+	longestWin time.Duration
+	mu         sync.Mutex
 }
 
 type tokenBucket struct {
-	tokens     int
+	tokens     float64
 	lastRefill time.Time
-	mu         sync.Mutex
 }
 
 // NewRateLimiter creates a new rate limiter
-// rate: number of requests allowed per window
+// rate: number of requests allowed per window for keys without a specific limit
 // window: time window (e.g., 1 minute)
 func NewRateLimiter(rate int, window time.Duration) *RateLimiter {
 	rl := &RateLimiter{
-		tokens:    make(map[string]*tokenBucket),
-		rate:      rate,
-		window:    window,
-		maxTokens: rate,
+		tokens: make(map[string]*keyBuckets),
+		defaultLimit: Limit{
+			Name:   WindowMinute,
+			Rate:   rate,
+			Window: window,
+			Burst:  rate,
+		},
 	}
 
 	// Start cleanup goroutine
@@ -42,54 +131,178 @@ func NewRateLimiter(rate int, window time.Duration) *RateLimiter {
 
 // Allow checks if a request is allowed for the given key (IP, API key, etc.)
 func (rl *RateLimiter) Allow(key string) bool {
+	result, _ := rl.Take(context.Background(), key, []Limit{rl.defaultLimit}, 1)
+	return result.Allowed
+}
+
+// Take charges every window of key's buckets, or none of them if any window
+// lacks capacity
+func (rl *RateLimiter) Take(_ context.Context, key string, limits []Limit, cost int) (Result, error) {
 	rl.mu.RLock()
-	bucket, exists := rl.tokens[key]
+	state, exists := rl.tokens[key]
 	rl.mu.RUnlock()
 
 	if !exists {
-		// Create new bucket
-		bucket = &tokenBucket{
-			tokens:     rl.maxTokens,
-			lastRefill: time.Now(),
-		}
 		rl.mu.Lock()
-		rl.tokens[key] = bucket
+		// Re-check under the write lock so concurrent first requests share buckets
+		if state, exists = rl.tokens[key]; !exists {
+			state = &keyBuckets{buckets: make(map[string]*tokenBucket)}
+			rl.tokens[key] = state
+		}
 		rl.mu.Unlock()
 	}
 
-	bucket.mu.Lock()
-	defer bucket.mu.Unlock()
+	state.mu.Lock()
+	defer state.mu.Unlock()
 
-	// Refill tokens based on time passed
 	now := time.Now()
-	elapsed := now.Sub(bucket.lastRefill)
-	refillTokens := int(elapsed / rl.window * time.Duration(rl.rate))
+	state.lastSeen = now
 
-	if refillTokens > 0 {
-		bucket.tokens = min(bucket.tokens+refillTokens, rl.maxTokens)
+	// Refill every window, then check all of them before charging any
+	var result Result
+	var denied bool
+	tightest := -1
+	var tightestLeft float64
+	for i, limit := range limits {
+		capacity := float64(limit.capacity())
+		perToken := limit.Window / time.Duration(limit.Rate)
+		// A request heavier than a bucket drains it rather than never fitting
+		charge := math.Min(float64(limit.charge(cost)), capacity)
+
+		bucket, ok := state.buckets[limit.bucket()]
+		if !ok {
+			bucket = &tokenBucket{tokens: capacity, lastRefill: now}
+			state.buckets[limit.bucket()] = bucket
+		}
+		elapsed := now.Sub(bucket.lastRefill)
+		bucket.tokens = math.Min(bucket.tokens+float64(elapsed)/float64(perToken), capacity)
 		bucket.lastRefill = now
+
+		if limit.Window > state.longestWin {
+			state.longestWin = limit.Window
+		}
+
+		if bucket.tokens < charge {
+			window := windowResult(limit, bucket.tokens, perToken)
+			window.RetryAfter = time.Duration((charge - bucket.tokens) * float64(perToken))
+			// Report the window that keeps the caller waiting longest
+			if !denied || window.RetryAfter > result.RetryAfter {
+				result = window
+			}
+			denied = true
+			continue
+		}
+
+		if left := bucket.tokens - charge; tightest < 0 || left < tightestLeft {
+			tightest, tightestLeft = i, left
+		}
 	}
 
-	// Check if tokens available
-	if bucket.tokens > 0 {
-		bucket.tokens--
-		return true
+	if denied {
+		return result, nil
 	}
 
-	return false
+	for i, limit := range limits {
+		bucket := state.buckets[limit.bucket()]
+		bucket.tokens -= math.Min(float64(limit.charge(cost)), float64(limit.capacity()))
+		if i == tightest {
+			result = windowResult(limit, bucket.tokens, limit.Window/time.Duration(limit.Rate))
+		}
+	}
+	result.Allowed = true
+
+	return result, nil
+}
+
+func windowResult(limit Limit, tokens float64, perToken time.Duration) Result {
+	capacity := limit.capacity()
+	return Result{
+		Window:    limit.Name,
+		Scope:     limit.Scope,
+		Rate:      limit.Rate,
+		Limit:     capacity,
+		Remaining: int(tokens),
+		Used:      capacity - int(tokens),
+		Reset:     time.Duration((float64(capacity) - tokens) * float64(perToken)),
+	}
 }
 
-// RateLimitMiddleware creates a rate limiting middleware
-func RateLimitMiddleware(limiter *RateLimiter) gin.HandlerFunc {
+// PlanResolver looks up the active plan of an organization and the plan's
+// per-chain limits
+type PlanResolver interface {
+	GetOrganizationPlan(ctx context.Context, orgID string) (*models.Plan, error)
+	GetPlanChainLimits(ctx context.Context, planID string) ([]models.PlanChainLimit, error)
+}
+
+// RateLimitRecorder receives rejected requests for auditing.
+// Implementations must not block.
+type RateLimitRecorder interface {
+	RecordRateLimitEvent(event models.RateLimitEvent)
+}
+
+// RateLimitMiddleware creates a rate limiting middleware.
+// Authenticated tenants get their own buckets sized by their plan's
+// per-minute (with burst multiplier), per-hour and per-day limits, so one
+// tenant cannot starve the others. Requests to a chain's :slug route are also
+// held to the plan's plan_chain_limits for that chain. Requests without a principal (auth disabled) are limited per
+// client IP with the default limit. The admin key is not rate limited. costs
+// charges heavier routes (keyed by route path) more than one compute unit.
+// Rejections are passed to recorder when it is not nil.
+func RateLimitMiddleware(limiter Limiter, cfg *config.RateLimitConfig, plans PlanResolver, costs map[string]int, recorder RateLimitRecorder) gin.HandlerFunc {
+	cache := newPlanLimitCache(time.Duration(cfg.PlanCacheTTL) * time.Second)
+	defaultLimits := []Limit{{
+		Name:   WindowMinute,
+		Rate:   cfg.DefaultPerMinute,
+		Window: time.Minute,
+		Burst:  cfg.DefaultPerMinute,
+	}}
+
 	return func(c *gin.Context) {
-		// Use IP as key (in production, use API key or user ID)
-		key := c.ClientIP()
+		key := "ip:" + c.ClientIP()
+		tenant := planLimitEntry{limits: defaultLimits}
+
+		principal, ok := GetPrincipal(c)
+		if ok {
+			if principal.IsAdmin {
+				c.Next()
+				return
+			}
+
+			key = "org:" + principal.OrganizationID
+			tenant = cache.get(c.Request.Context(), plans, principal.OrganizationID, defaultLimits)
+		}
+
+		cost := 1
+		if routeCost, ok := costs[c.FullPath()]; ok && routeCost > 0 {
+			cost = routeCost
+		}
+
+		result, err := limiter.Take(c.Request.Context(), key, tenant.limitsFor(requestChain(c)), cost)
+		if err != nil {
+			// Fail open: the limiter backend is unavailable
+			c.Next()
+			return
+		}
+		setRateLimitHeaders(c, result)
 
-		if !limiter.Allow(key) {
-			c.JSON(http.StatusTooManyRequests, gin.H{
+		if !result.Allowed {
+			if recorder != nil {
+				recorder.RecordRateLimitEvent(rateLimitEvent(c, principal, tenant.planSlug, result, cost))
+			}
+
+			retryAfter := ceilSeconds(result.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			body := gin.H{
 				"error":       "rate limit exceeded",
-				"retry_after": limiter.window.Seconds(),
-			})
+				"limit_type":  result.Window,
+				"limit":       result.Rate,
+				"cost":        cost,
+				"retry_after": retryAfter,
+			}
+			if result.Scope != "" {
+				body["scope"] = result.Scope
+			}
+			c.JSON(http.StatusTooManyRequests, body)
 			c.Abort()
 			return
 		}
@@ -98,51 +311,224 @@ func RateLimitMiddleware(limiter *RateLimiter) gin.HandlerFunc {
 	}
 }
 
-// RateLimitByAPIKeyMiddleware creates a rate limiting middleware using API key
-func RateLimitByAPIKeyMiddleware(limiter *RateLimiter) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Extract API key from Authorization header
-		apiKey := c.GetHeader("Authorization")
-		if apiKey == "" {
-			// Fall back to IP-based limiting
-			apiKey = c.ClientIP()
+// rateLimitEvent describes a rejected request; principal is nil when auth is disabled
+func rateLimitEvent(c *gin.Context, principal *Principal, planSlug string, result Result, cost int) models.RateLimitEvent {
+	event := models.RateLimitEvent{
+		Timestamp:    time.Now().UTC(),
+		PlanSlug:     planSlug,
+		LimitType:    result.Window,
+		LimitValue:   uint32(result.Rate),
+		CurrentCount: uint32(result.Used),
+		ClientIP:     c.ClientIP(),
+	}
+
+	metadata := map[string]interface{}{
+		"method": c.Request.Method,
+		"route":  c.FullPath(),
+		"cost":   cost,
+	}
+	if result.Scope != "" {
+		metadata["scope"] = result.Scope
+	}
+	if principal != nil {
+		event.OrganizationID = principal.OrganizationID
+		event.ConsumerID = principal.ConsumerID
+		if principal.KeyPrefix != "" {
+			metadata["key_prefix"] = principal.KeyPrefix
 		}
+		if principal.UserID != "" {
+			metadata["user_id"] = principal.UserID
+		}
+	}
+	if data, err := json.Marshal(metadata); err == nil {
+		event.Metadata = string(data)
+	}
 
-		if !limiter.Allow(apiKey) {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":       "rate limit exceeded",
-				"retry_after": limiter.window.Seconds(),
-			})
-			c.Abort()
-			return
+	return event
+}
+
+// setRateLimitHeaders writes the IETF RateLimit-* headers
+func setRateLimitHeaders(c *gin.Context, result Result) {
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// planLimitCache caches per-organization limits to keep Postgres off the hot path
+type planLimitCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]planLimitEntry
+}
+
+type planLimitEntry struct {
+	limits    []Limit
+	planSlug  string
+	expiresAt time.Time
+	// chainLimits holds the windows of requests for a chain slug
+	chainLimits map[string][]Limit
+}
+
+// limitsFor returns the windows a request for chainSlug must fit: the
+// plan-wide windows and the chain's own. Chain windows never lift the plan
+// limits, is_override rows included.
+func (e planLimitEntry) limitsFor(chainSlug string) []Limit {
+	chainLimits, ok := e.chainLimits[chainSlug]
+	if chainSlug == "" || !ok {
+		return e.limits
+	}
+
+	limits := make([]Limit, 0, len(e.limits)+len(chainLimits))
+	limits = append(limits, e.limits...)
+	return append(limits, chainLimits...)
+}
+
+// requestChain returns the chain a request is served for, or "" for none.
+// Only the :slug route parameter counts: query parameters are chosen by the
+// caller and may not name the chain the handler serves.
+func requestChain(c *gin.Context) string {
+	return c.Param("slug")
+}
+
+func newPlanLimitCache(ttl time.Duration) *planLimitCache {
+	return &planLimitCache{
+		ttl:     ttl,
+		entries: make(map[string]planLimitEntry),
+	}
+}
+
+func (pc *planLimitCache) get(ctx context.Context, plans PlanResolver, orgID string, fallback []Limit) planLimitEntry {
+	pc.mu.Lock()
+	entry, ok := pc.entries[orgID]
+	pc.mu.Unlock()
+
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry
+	}
+
+	entry = planLimitEntry{limits: fallback}
+	plan, err := plans.GetOrganizationPlan(ctx, orgID)
+	if err != nil {
+		// Don't cache lookup failures; retry on the next request
+		return entry
+	}
+	if plan != nil {
+		entry.planSlug = plan.Slug
+		if plan.RateLimitPerMinute > 0 {
+			entry.limits = planLimits(plan)
 		}
 
-		c.Next()
+		chainLimits, err := plans.GetPlanChainLimits(ctx, plan.ID)
+		if err != nil {
+			return entry
+		}
+		entry.chainLimits = make(map[string][]Limit, len(chainLimits))
+		for i := range chainLimits {
+			if limits := chainLimitWindows(&chainLimits[i]); len(limits) > 0 {
+				entry.chainLimits[chainLimits[i].ChainSlug] = limits
+			}
+		}
 	}
+	entry.expiresAt = time.Now().Add(pc.ttl)
+
+	pc.mu.Lock()
+	pc.entries[orgID] = entry
+	pc.mu.Unlock()
+
+	return entry
+}
+
+// planLimits converts a plan's limits into windows. The burst multiplier
+// applies to the minute window; hour and day windows are strict quotas.
+func planLimits(plan *models.Plan) []Limit {
+	multiplier := plan.BurstMultiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	limits := []Limit{{
+		Name:   WindowMinute,
+		Rate:   plan.RateLimitPerMinute,
+		Window: time.Minute,
+		Burst:  int(float64(plan.RateLimitPerMinute) * multiplier),
+	}}
+	if plan.RateLimitPerHour > 0 {
+		limits = append(limits, Limit{
+			Name:   WindowHour,
+			Rate:   plan.RateLimitPerHour,
+			Window: time.Hour,
+			Burst:  plan.RateLimitPerHour,
+		})
+	}
+	if plan.RateLimitPerDay > 0 {
+		limits = append(limits, Limit{
+			Name:   WindowDay,
+			Rate:   plan.RateLimitPerDay,
+			Window: 24 * time.Hour,
+			Burst:  plan.RateLimitPerDay,
+		})
+	}
+
+	return limits
+}
+
+// chainLimitWindows converts a plan_chain_limits row into windows scoped to
+// its chain. Request windows count requests; the compute unit windows are
+// charged the request's cost. A row without limits has no windows.
+func chainLimitWindows(cl *models.PlanChainLimit) []Limit {
+	scope := "chain:" + cl.ChainSlug
+	windows := []struct {
+		name       string
+		rate       int
+		window     time.Duration
+		perRequest bool
+	}{
+		{WindowSecond, cl.RateLimitPerSecond, time.Second, true},
+		{WindowMinute, cl.RateLimitPerMinute, time.Minute, true},
+		{WindowHour, cl.RateLimitPerHour, time.Hour, true},
+		{WindowDay, cl.RateLimitPerDay, 24 * time.Hour, true},
+		{WindowSecond, cl.ComputeUnitsPerSecond, time.Second, false},
+		{WindowDay, cl.ComputeUnitsPerDay, 24 * time.Hour, false},
+	}
+
+	var limits []Limit
+	for _, w := range windows {
+		if w.rate <= 0 {
+			continue
+		}
+		limits = append(limits, Limit{
+			Name:       w.name,
+			Rate:       w.rate,
+			Window:     w.window,
+			Burst:      w.rate,
+			Scope:      scope,
+			PerRequest: w.perRequest,
+		})
+	}
+
+	return limits
 }
 
 // cleanup removes old entries periodically
 func (rl *RateLimiter) cleanup() {
-	ticker := time.NewTicker(rl.window * 10)
+	ticker := time.NewTicker(rl.defaultLimit.Window * 10)
 	defer ticker.Stop()
 
 	for range ticker.C {
 		rl.mu.Lock()
 		now := time.Now()
-		for key, bucket := range rl.tokens {
-			bucket.mu.Lock()
-			if now.Sub(bucket.lastRefill) > rl.window*2 {
+		for key, state := range rl.tokens {
+			state.mu.Lock()
+			// Idle longer than the longest window means every bucket is full again
+			if now.Sub(state.lastSeen) > state.longestWin {
 				delete(rl.tokens, key)
 			}
-			bucket.mu.Unlock()
+			state.mu.Unlock()
 		}
 		rl.mu.Unlock()
 	}
 }
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// gcraScript implements GCRA (generic cell rate algorithm) atomically in Redis.
+// It stores one theoretical arrival time (TAT) per window and uses the Redis
+// clock so every replica shares the same budget. All windows are checked
+// before any is charged, so a rejected request consumes nothing.
+//
+// KEYS[i]      bucket key of window i
+// ARGV[3i-2]   emission interval of window i in microseconds (window / rate)
+// ARGV[3i-1]   burst of window i (tokens allowed back to back)
+// ARGV[3i]     tokens charged to window i
+//
+// Returns {allowed, window index (1-based), remaining, reset_us, retry_after_us}
+// for the tripped window when denied, otherwise the most constraining one.
+var gcraScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local new_tats = {}
+local denied, denied_retry = 0, -1
+local tightest, tightest_left = 0, -1
+local out_remaining, out_reset = 0, 0
+
+for i = 1, #KEYS do
+  local interval = tonumber(ARGV[3 * i - 2])
+  local burst = tonumber(ARGV[3 * i - 1])
+  local tolerance = interval * burst
+  local charge = math.min(tonumber(ARGV[3 * i]), burst)
+
+  local tat = tonumber(redis.call('GET', KEYS[i]))
+  if not tat or tat < now then
+    tat = now
+  end
+
+  local new_tat = tat + interval * charge
+  local allow_at = new_tat - tolerance
+  new_tats[i] = new_tat
+
+  if now < allow_at then
+    if allow_at - now > denied_retry then
+      local remaining = math.floor((tolerance - (tat - now)) / interval)
+      if remaining < 0 then remaining = 0 end
+      denied, denied_retry = i, allow_at - now
+      out_remaining, out_reset = remaining, tat - now
+    end
+  elseif denied == 0 then
+    local left = math.floor((tolerance - (new_tat - now)) / interval)
+    if tightest == 0 or left < tightest_left then
+      tightest, tightest_left = i, left
+      out_remaining, out_reset = left, new_tat - now
+    end
+  end
+end
+
+if denied > 0 then
+  return {0, denied, out_remaining, out_reset, denied_retry}
+end
+
+for i = 1, #KEYS do
+  redis.call('SET', KEYS[i], new_tats[i], 'PX', math.ceil((new_tats[i] - now) / 1000))
+end
+return {1, tightest, out_remaining, out_reset, 0}
+`)
+
+// RedisLimiter is a distributed limiter shared by all replicas.
+// When Redis is unreachable it falls back to a local in-process limiter and
+// retries Redis after a short backoff.
+type RedisLimiter struct {
+	client   *redis.Client
+	fallback Limiter
+	logger   *zap.Logger
+
+	mu          sync.Mutex
+	unavailable time.Time // Redis is skipped until this time
+}
+
+// redisRetryBackoff is how long Redis is bypassed after a failure
+const redisRetryBackoff = 5 * time.Second
+
+// NewRedisLimiter creates a Redis-backed limiter with a local fallback
+func NewRedisLimiter(client *redis.Client, fallback Limiter, logger *zap.Logger) *RedisLimiter {
+	return &RedisLimiter{
+		client:   client,
+		fallback: fallback,
+		logger:   logger,
+	}
+}
+
+// Take charges every window of key's budget, or none of them if any window
+// lacks capacity
+func (rl *RedisLimiter) Take(ctx context.Context, key string, limits []Limit, cost int) (Result, error) {
+	if !rl.available() {
+		return rl.fallback.Take(ctx, key, limits, cost)
+	}
+
+	// The hash tag keeps all windows of a key in one cluster slot
+	keys := make([]string, len(limits))
+	args := make([]interface{}, 0, 3*len(limits))
+	for i, limit := range limits {
+		keys[i] = "ratelimit:{" + key + "}:" + limit.bucket()
+		interval := limit.Window / time.Duration(limit.Rate)
+		args = append(args, interval.Microseconds(), limit.capacity(), limit.charge(cost))
+	}
+
+	values, err := gcraScript.Run(ctx, rl.client, keys, args...).Int64Slice()
+	if err != nil {
+		rl.markUnavailable(err)
+		return rl.fallback.Take(ctx, key, limits, cost)
+	}
+
+	limit := limits[values[1]-1]
+	remaining := int(values[2])
+	return Result{
+		Allowed:    values[0] == 1,
+		Window:     limit.Name,
+		Scope:      limit.Scope,
+		Rate:       limit.Rate,
+		Limit:      limit.capacity(),
+		Remaining:  remaining,
+		Used:       limit.capacity() - remaining,
+		Reset:      time.Duration(values[3]) * time.Microsecond,
+		RetryAfter: time.Duration(values[4]) * time.Microsecond,
+	}, nil
+}
+
+func (rl *RedisLimiter) available() bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return time.Now().After(rl.unavailable)
+}
+
+func (rl *RedisLimiter) markUnavailable(err error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	// Log once per outage window, not once per request
+	if time.Now().After(rl.unavailable) && rl.logger != nil {
+		rl.logger.Warn("Redis rate limiter unavailable, using local limiter", zap.Error(err))
+	}
+	rl.unavailable = time.Now().Add(redisRetryBackoff)
+}
@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+type fakePlans struct {
+	plan        *models.Plan
+	chainLimits []models.PlanChainLimit
+}
+
+func (p *fakePlans) GetOrganizationPlan(context.Context, string) (*models.Plan, error) {
+	return p.plan, nil
+}
+
+func (p *fakePlans) GetPlanChainLimits(context.Context, string) ([]models.PlanChainLimit, error) {
+	return p.chainLimits, nil
+}
+
+func TestTakeChargesPerRequestWindowsOnce(t *testing.T) {
+	limiter := NewRateLimiter(60, time.Minute)
+	limits := []Limit{
+		{Name: WindowMinute, Rate: 100, Window: time.Minute, Burst: 100},
+		{Name: WindowSecond, Rate: 10, Window: time.Second, Burst: 10, PerRequest: true},
+	}
+
+	result, err := limiter.Take(context.Background(), "org:1", limits, 5)
+	if err != nil || !result.Allowed {
+		t.Fatalf("Take = %+v, %v", result, err)
+	}
+
+	state := limiter.tokens["org:1"]
+	if got := state.buckets[limits[0].bucket()].tokens; got < 94.9 || got > 95.1 {
+		t.Errorf("compute unit window has %.1f tokens, want 95", got)
+	}
+	if got := state.buckets[limits[1].bucket()].tokens; got < 8.9 || got > 9.1 {
+		t.Errorf("request window has %.1f tokens, want 9", got)
+	}
+}
+
+func TestLimitBucketsAreDistinct(t *testing.T) {
+	limits := []Limit{
+		{Name: WindowSecond},
+		{Name: WindowSecond, PerRequest: true},
+		{Name: WindowSecond, Scope: "chain:eth"},
+		{Name: WindowSecond, Scope: "chain:eth", PerRequest: true},
+		{Name: WindowSecond, Scope: "chain:base", PerRequest: true},
+	}
+
+	seen := make(map[string]bool)
+	for _, limit := range limits {
+		if seen[limit.bucket()] {
+			t.Errorf("bucket %q shared by two limits", limit.bucket())
+		}
+		seen[limit.bucket()] = true
+	}
+	// Plan-wide windows keep their original bucket (and Redis key) names
+	if got := (Limit{Name: WindowMinute}).bucket(); got != WindowMinute {
+		t.Errorf("plan minute bucket = %q", got)
+	}
+}
+
+func TestChainLimitWindows(t *testing.T) {
+	limits := chainLimitWindows(&models.PlanChainLimit{
+		ChainSlug:             "eth",
+		RateLimitPerSecond:    5,
+		RateLimitPerDay:       1000,
+		ComputeUnitsPerSecond: 50,
+		IsOverride:            true,
+	})
+	if len(limits) != 3 {
+		t.Fatalf("limits = %+v", limits)
+	}
+
+	want := []struct {
+		name       string
+		rate       int
+		perRequest bool
+	}{
+		{WindowSecond, 5, true},
+		{WindowDay, 1000, true},
+		{WindowSecond, 50, false},
+	}
+	for i, w := range want {
+		got := limits[i]
+		if got.Name != w.name || got.Rate != w.rate || got.PerRequest != w.perRequest || got.Scope != "chain:eth" {
+			t.Errorf("limit %d = %+v, want %+v", i, got, w)
+		}
+	}
+
+	if limits := chainLimitWindows(&models.PlanChainLimit{ChainSlug: "eth"}); len(limits) != 0 {
+		t.Errorf("row without limits produced windows")
+	}
+}
+
+func TestPlanLimitEntryLimitsFor(t *testing.T) {
+	planWide := []Limit{{Name: WindowMinute, Rate: 100, Window: time.Minute}}
+	chainWindow := Limit{Name: WindowSecond, Rate: 1, Window: time.Second, Scope: "chain:eth", PerRequest: true}
+	entry := planLimitEntry{
+		limits: planWide,
+		chainLimits: map[string][]Limit{
+			"eth": {chainWindow},
+		},
+	}
+
+	tests := []struct {
+		chain string
+		want  int
+	}{
+		{"", 1},
+		{"arb", 1},
+		{"eth", 2},
+	}
+	for _, tt := range tests {
+		if got := entry.limitsFor(tt.chain); len(got) != tt.want {
+			t.Errorf("limitsFor(%q) has %d windows, want %d", tt.chain, len(got), tt.want)
+		}
+	}
+	if got := entry.limitsFor("eth"); got[0].Scope != "" || got[1].Scope != "chain:eth" {
+		t.Errorf("chain windows did not add to the plan-wide windows: %+v", got)
+	}
+}
+
+func TestRateLimitMiddlewareChainLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	plans := &fakePlans{
+		plan: &models.Plan{ID: "plan-1", Slug: "pro", RateLimitPerMinute: 1000, BurstMultiplier: 1},
+		chainLimits: []models.PlanChainLimit{
+			{ChainSlug: "eth", RateLimitPerSecond: 2},
+		},
+	}
+	cfg := &config.RateLimitConfig{DefaultPerMinute: 60, PlanCacheTTL: 60}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		setPrincipal(c, &Principal{OrganizationID: "org-1"})
+		c.Next()
+	})
+	router.Use(RateLimitMiddleware(NewRateLimiter(60, time.Minute), cfg, plans, nil, nil))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/chains/:slug/health", ok)
+	router.GET("/usage", ok)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := get("/chains/eth/health"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i, rec.Code)
+		}
+	}
+
+	rec := get("/chains/eth/health")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third eth request = %d, want 429", rec.Code)
+	}
+	var body map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if body["limit_type"] != WindowSecond || body["scope"] != "chain:eth" {
+		t.Errorf("429 body = %v", body)
+	}
+
+	// Other chains and chain-less requests only use the plan-wide windows;
+	// a chain query parameter does not select chain windows
+	if rec := get("/chains/base/health"); rec.Code != http.StatusOK {
+		t.Errorf("base request = %d, want 200", rec.Code)
+	}
+	if rec := get("/usage?chain=eth"); rec.Code != http.StatusOK {
+		t.Errorf("request with a chain parameter = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimitMiddlewareOverrideKeepsPlanLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// The override row allows far more than the plan's 3 per minute
+	plans := &fakePlans{
+		plan: &models.Plan{ID: "plan-1", Slug: "free", RateLimitPerMinute: 3, BurstMultiplier: 1},
+		chainLimits: []models.PlanChainLimit{
+			{ChainSlug: "base", RateLimitPerMinute: 10000, IsOverride: true},
+		},
+	}
+	cfg := &config.RateLimitConfig{DefaultPerMinute: 60, PlanCacheTTL: 60}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		setPrincipal(c, &Principal{OrganizationID: "org-1"})
+		c.Next()
+	})
+	router.Use(RateLimitMiddleware(NewRateLimiter(60, time.Minute), cfg, plans, nil, nil))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/chains/:slug/health", ok)
+	router.GET("/usage", ok)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	paths := []string{"/usage?chain=base", "/chains/base/health", "/usage?chain=base"}
+	for i, path := range paths {
+		if rec := get(path); rec.Code != http.StatusOK {
+			t.Fatalf("request %d (%s) = %d, want 200", i, path, rec.Code)
+		}
+	}
+
+	for _, path := range []string{"/usage?chain=base", "/chains/base/health"} {
+		rec := get(path)
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("%s over the plan limit = %d, want 429", path, rec.Code)
+		}
+		var body map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if body["limit_type"] != WindowMinute || body["scope"] != nil {
+			t.Errorf("%s 429 body = %v, want the plan-wide minute window", path, body)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permission scopes required by routes
+const (
+	ScopeUsageRead   = "usage:read"
+	ScopeKeysRead    = "keys:read"
+	ScopeBillingRead = "billing:read"
+	ScopeChainsRead  = "chains:read"
+	// ScopeAdmin grants every scope (platform super-user)
+	ScopeAdmin = "admin:*"
+)
+
+// Organization roles (users.role)
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// roleScopes maps organization roles to the scopes they grant
+var roleScopes = map[string][]string{
+	RoleOwner:  {ScopeUsageRead, ScopeKeysRead, ScopeBillingRead, ScopeChainsRead},
+	RoleAdmin:  {ScopeUsageRead, ScopeKeysRead, ScopeChainsRead},
+	RoleMember: {ScopeUsageRead, ScopeChainsRead},
+}
+
+// ScopesForRole returns the scopes granted to a role (empty for unknown roles)
+func ScopesForRole(role string) []string {
+	return roleScopes[role]
+}
+
+// HasScope reports whether the principal was granted scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope rejects requests whose principal lacks the given scope
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			// Auth disabled (development mode)
+			c.Next()
+			return
+		}
+
+		if !principal.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":          "insufficient scope",
+				"code":           "insufficient_scope",
+				"required_scope": scope,
+				"role":           principal.Role,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
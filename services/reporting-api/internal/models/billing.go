@@ -0,0 +1,195 @@
+package models
+
+import "time"
+
+// Invoice is a billing invoice as stored in Postgres invoices
+type Invoice struct {
+	ID              string            `json:"id"`
+	OrganizationID  string            `json:"organization_id"`
+	SubscriptionID  string            `json:"subscription_id"`
+	InvoiceNumber   string            `json:"invoice_number"`
+	Subtotal        float64           `json:"subtotal"`
+	Tax             float64           `json:"tax"`
+	Total           float64           `json:"total"`
+	Currency        string            `json:"currency"`
+	Status          string            `json:"status"` // draft, open, paid, void, uncollectible
+	PeriodStart     time.Time         `json:"period_start"`
+	PeriodEnd       time.Time         `json:"period_end"`
+	DueDate         *time.Time        `json:"due_date,omitempty"`
+	PaidAt          *time.Time        `json:"paid_at,omitempty"`
+	LineItems       []InvoiceLineItem `json:"line_items"`
+	RatingRuleID    *string           `json:"rating_rule_id,omitempty"`    // nil when rated from the plan's overage columns
+	StripeInvoiceID *string           `json:"stripe_invoice_id,omitempty"` // set on invoices received from Stripe
+	CreatedAt       time.Time         `json:"created_at"`
+}
+
+// InvoiceLineItem is one entry of invoices.line_items
+type InvoiceLineItem struct {
+	Description string  `json:"description"`
+	Meter       string  `json:"meter,omitempty"` // base_fee, compute_units, requests, egress_gb
+	ChainSlug   string  `json:"chain_slug,omitempty"`
+	Quantity    float64 `json:"quantity,omitempty"`
+	Unit        string  `json:"unit,omitempty"` // what one unit_price buys, e.g. 1M compute units
+	UnitPrice   float64 `json:"unit_price,omitempty"`
+	Amount      float64 `json:"amount"`
+}
+
+// PlanPricing holds a plan's fees, monthly allowances and overage rates
+type PlanPricing struct {
+	PlanID                        string  `json:"plan_id"`
+	PlanSlug                      string  `json:"plan_slug"`
+	PlanName                      string  `json:"plan_name"`
+	PriceMonthly                  float64 `json:"price_monthly"`
+	PriceYearly                   float64 `json:"price_yearly"`
+	Currency                      string  `json:"currency"`
+	IncludedComputeUnits          uint64  `json:"included_compute_units"`
+	IncludedRequests              uint64  `json:"included_requests"`
+	IncludedEgressGB              float64 `json:"included_egress_gb"`
+	OveragePerMillionComputeUnits float64 `json:"overage_per_million_compute_units"`
+	OveragePerMillionRequests     float64 `json:"overage_per_million_requests"`
+	OveragePerGB                  float64 `json:"overage_per_gb"`
+}
+
+// BillableSubscription is an active subscription with its plan's pricing
+type BillableSubscription struct {
+	ID                 string      `json:"id"`
+	OrganizationID     string      `json:"organization_id"`
+	BillingPeriod      string      `json:"billing_period"` // monthly, yearly
+	CurrentPeriodStart time.Time   `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time   `json:"current_period_end"`
+	CancelAtPeriodEnd  bool        `json:"cancel_at_period_end"`
+	Pricing            PlanPricing `json:"pricing"`
+}
+
+// BillableUsage is one chain's usage in a billing period, or one method's
+// when usage is broken down by method
+type BillableUsage struct {
+	ChainSlug    string `json:"chain_slug"`
+	Method       string `json:"method,omitempty"`
+	Requests     uint64 `json:"requests"`
+	ComputeUnits uint64 `json:"compute_units"`
+	EgressBytes  uint64 `json:"egress_bytes"`
+}
+
+// RatingRules is one version of a plan's rating rules from rating_rules
+type RatingRules struct {
+	ID            string           `json:"id"`
+	PlanID        string           `json:"plan_id"`
+	Version       int              `json:"version"`
+	Description   string           `json:"description,omitempty"`
+	EffectiveFrom time.Time        `json:"effective_from"`
+	Definition    RatingDefinition `json:"definition"`
+}
+
+// RatingDefinition is how a plan's usage is charged. Allowances, tier
+// bounds, minimums and caps are per billing month.
+type RatingDefinition struct {
+	MinimumCommit float64     `json:"minimum_commit,omitempty"` // usage charges are topped up to this
+	MaximumCharge float64     `json:"maximum_charge,omitempty"` // usage charges are capped at this; 0 is no cap
+	Meters        []MeterRule `json:"meters"`
+}
+
+// MeterRule rates one meter. Coefficients weight usage before the included
+// units and tiers apply; chains and methods without one weigh 1.
+type MeterRule struct {
+	Meter              string             `json:"meter"`     // compute_units, requests, egress_gb
+	UnitSize           float64            `json:"unit_size"` // units one unit_price buys
+	Included           float64            `json:"included,omitempty"`
+	Mode               string             `json:"mode"` // graduated, volume
+	Tiers              []RatingTier       `json:"tiers"`
+	ChainCoefficients  map[string]float64 `json:"chain_coefficients,omitempty"`
+	MethodCoefficients map[string]float64 `json:"method_coefficients,omitempty"`
+	MinimumCharge      float64            `json:"minimum_charge,omitempty"`
+	MaximumCharge      float64            `json:"maximum_charge,omitempty"` // 0 is no cap
+}
+
+// RatingTier prices billable units up to UpTo, counted after the included
+// units. The last tier has no UpTo.
+type RatingTier struct {
+	UpTo      *float64 `json:"up_to,omitempty"`
+	UnitPrice float64  `json:"unit_price"`
+	FlatFee   float64  `json:"flat_fee,omitempty"`
+}
+
+// BillingEstimate is the expected invoice of a subscription's current
+// period: its charges so far and two projections to the period end
+type BillingEstimate struct {
+	OrganizationID    string         `json:"organization_id"`
+	SubscriptionID    string         `json:"subscription_id"`
+	PlanSlug          string         `json:"plan_slug"`
+	BillingPeriod     string         `json:"billing_period"`
+	Currency          string         `json:"currency"`
+	PeriodStart       time.Time      `json:"period_start"`
+	PeriodEnd         time.Time      `json:"period_end"`
+	AsOf              time.Time      `json:"as_of"`
+	ElapsedPct        float64        `json:"elapsed_pct"`
+	RatingRuleVersion *int           `json:"rating_rule_version,omitempty"` // nil when rated from the plan's overage columns
+	ToDate            CostProjection `json:"to_date"`
+	Linear            CostProjection `json:"linear"`      // period-to-date usage scaled to the whole period
+	Trailing7Day      CostProjection `json:"trailing_7d"` // period-to-date usage plus the last 7 days' daily rate for the rest
+}
+
+// CostProjection is usage and what it costs in the period
+type CostProjection struct {
+	Requests     uint64             `json:"requests"`
+	ComputeUnits uint64             `json:"compute_units"`
+	EgressGB     float64            `json:"egress_gb"`
+	BaseFee      float64            `json:"base_fee"`
+	UsageCharges float64            `json:"usage_charges"` // everything but the base fee, with minimums and caps
+	ByMeter      map[string]float64 `json:"by_meter"`
+	LineItems    []InvoiceLineItem  `json:"line_items"`
+	Total        float64            `json:"total"`
+}
+
+// ChainPrice is a chain's USD list price from chain_prices. An empty
+// PlanSlug is the chain's price for plans without their own.
+type ChainPrice struct {
+	ChainSlug                   string  `json:"chain_slug"`
+	PlanSlug                    string  `json:"plan_slug"`
+	PricePerMillionComputeUnits float64 `json:"price_per_million_compute_units"`
+	PricePerMillionRequests     float64 `json:"price_per_million_requests"`
+	PricePerGB                  float64 `json:"price_per_gb"`
+}
+
+// ChainCostDay is one chain's usage and estimated cost on one UTC day
+type ChainCostDay struct {
+	Date          time.Time `json:"date"`
+	ChainSlug     string    `json:"chain_slug"`
+	Requests      uint64    `json:"requests"`
+	ComputeUnits  uint64    `json:"compute_units"`
+	EgressGB      float64   `json:"egress_gb"`
+	EstimatedCost float64   `json:"estimated_cost"`
+}
+
+// ChainCosts is usage cost per chain per day from chain_org_usage, priced
+// at ingest by the chain_prices list prices in USD
+type ChainCosts struct {
+	Period         Period             `json:"period"`
+	OrganizationID string             `json:"organization_id,omitempty"`
+	ChainSlug      string             `json:"chain_slug,omitempty"`
+	PlanSlug       string             `json:"plan_slug,omitempty"`
+	Currency       string             `json:"currency"`
+	TotalCost      float64            `json:"total_cost"`
+	ByChain        map[string]float64 `json:"by_chain"`
+	Days           []ChainCostDay     `json:"days"`
+}
+
+// StripeMeterExport is a metered Stripe price of an active subscription
+// billed through Stripe, and how far its usage has been reported
+type StripeMeterExport struct {
+	SubscriptionID       string     `json:"subscription_id"`
+	OrganizationID       string     `json:"organization_id"`
+	StripeSubscriptionID string     `json:"stripe_subscription_id"`
+	CurrentPeriodStart   time.Time  `json:"current_period_start"`
+	Meter                string     `json:"meter"`
+	StripePriceID        string     `json:"stripe_price_id"`
+	ExportedThrough      *time.Time `json:"exported_through,omitempty"` // nil before the first export
+}
+
+// BillableUsageBucket is an organization's usage in one hour or day
+type BillableUsageBucket struct {
+	Start        time.Time `json:"start"`
+	Requests     uint64    `json:"requests"`
+	ComputeUnits uint64    `json:"compute_units"`
+	EgressBytes  uint64    `json:"egress_bytes"`
+}
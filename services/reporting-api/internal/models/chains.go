@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Chain is a supported blockchain network from the Postgres catalog
+type Chain struct {
+	ID                   string          `json:"id"`
+	Name                 string          `json:"name"`
+	Slug                 string          `json:"slug"`
+	ChainType            string          `json:"chain_type"`
+	ChainID              string          `json:"chain_id,omitempty"`
+	DisplayName          string          `json:"display_name"`
+	BlockTimeSeconds     int             `json:"block_time_seconds"`
+	NativeCurrency       *NativeCurrency `json:"native_currency,omitempty"`
+	SupportsWebsocket    bool            `json:"supports_websocket"`
+	SupportsArchive      bool            `json:"supports_archive"`
+	SupportsTrace        bool            `json:"supports_trace"`
+	IsActive             bool            `json:"is_active"`
+	IsTestnet            bool            `json:"is_testnet"`
+	EndpointCount        int             `json:"endpoint_count"`
+	HealthyEndpointCount int             `json:"healthy_endpoint_count"`
+	Health               *ChainHealth    `json:"health,omitempty"`
+}
+
+// NativeCurrency is the gas token of a chain
+type NativeCurrency struct {
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Decimals int    `json:"decimals"`
+}
+
+// ChainHealth summarizes recent traffic of a chain (or one upstream) from
+// ClickHouse chain_health_minutely
+type ChainHealth struct {
+	Timestamp        time.Time `json:"timestamp"`
+	RequestCount     uint64    `json:"request_count"`
+	ErrorCount       uint64    `json:"error_count"`
+	ErrorRatePct     float64   `json:"error_rate_pct"`
+	AvgLatencyMS     float64   `json:"avg_latency_ms"`
+	P95LatencyMS     float64   `json:"p95_latency_ms"`
+	P99LatencyMS     float64   `json:"p99_latency_ms"`
+	HealthyUpstreams uint64    `json:"healthy_upstreams"`
+	TotalUpstreams   uint64    `json:"total_upstreams"`
+}
+
+// RPCEndpoint is an upstream node from Postgres rpc_endpoints
+type RPCEndpoint struct {
+	ID                  string     `json:"id"`
+	Name                string     `json:"name"`
+	URL                 string     `json:"url"`
+	Host                string     `json:"host"`
+	EndpointType        string     `json:"endpoint_type"`
+	IsArchive           bool       `json:"is_archive"`
+	SupportsTrace       bool       `json:"supports_trace"`
+	Weight              int        `json:"weight"`
+	Priority            int        `json:"priority"`
+	IsHealthy           bool       `json:"is_healthy"`
+	LastHealthCheck     *time.Time `json:"last_health_check,omitempty"`
+	HealthCheckFailures int        `json:"health_check_failures"`
+	AvgLatencyMS        int        `json:"avg_latency_ms"`
+	IsActive            bool       `json:"is_active"`
+	Provider            string     `json:"provider,omitempty"`
+}
+
+// UpstreamStatus merges an upstream's catalog entry with its live metrics.
+// Endpoint is nil for hosts seen in traffic but missing from the catalog.
+type UpstreamStatus struct {
+	Host     string       `json:"host"`
+	Endpoint *RPCEndpoint `json:"endpoint,omitempty"`
+	Health   *ChainHealth `json:"health,omitempty"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// ErrorFilter narrows an errors query; empty fields match everything
+type ErrorFilter struct {
+	ChainSlug  string
+	RPCMethod  string
+	ErrorType  string
+	StatusCode int
+}
+
+// ErrorEvent is a single row of the ClickHouse errors table
+type ErrorEvent struct {
+	Timestamp    time.Time `json:"timestamp"`
+	RequestID    string    `json:"request_id"`
+	ConsumerID   string    `json:"consumer_id"`
+	ChainSlug    string    `json:"chain_slug"`
+	ErrorType    string    `json:"error_type"`
+	ErrorMessage string    `json:"error_message"`
+	ErrorStack   string    `json:"error_stack,omitempty"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	StatusCode   uint16    `json:"status_code"`
+	RPCMethod    string    `json:"rpc_method"`
+	Metadata     string    `json:"metadata,omitempty"` // JSON
+}
+
+// ErrorGroup aggregates errors sharing a normalized message
+type ErrorGroup struct {
+	Pattern         string    `json:"pattern"`
+	ErrorType       string    `json:"error_type"`
+	Count           uint64    `json:"count"`
+	FirstSeen       time.Time `json:"first_seen"`
+	LastSeen        time.Time `json:"last_seen"`
+	SampleMessage   string    `json:"sample_message"`
+	SampleRequestID string    `json:"sample_request_id"`
+	StatusCodes     []uint16  `json:"status_codes"`
+	Chains          []string  `json:"chains"`
+	RPCMethods      []string  `json:"rpc_methods"`
+}
+
+// ErrorsPage is one page of the errors explorer
+type ErrorsPage struct {
+	OrganizationID string       `json:"organization_id"`
+	Period         Period       `json:"period"`
+	TotalErrors    uint64       `json:"total_errors"`
+	TopErrors      []ErrorGroup `json:"top_errors"`
+	Errors         []ErrorEvent `json:"errors"`
+	NextCursor     string       `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,128 @@
+package models
+
+import "time"
+
+// MethodComputeUnits is the weight and properties of an RPC method from the
+// Postgres method_compute_units table
+type MethodComputeUnits struct {
+	ChainType       string `json:"chain_type"`
+	Method          string `json:"method"`
+	ComputeUnits    int    `json:"compute_units"`
+	IsExpensive     bool   `json:"is_expensive"`
+	RequiresArchive bool   `json:"requires_archive"`
+	RequiresTrace   bool   `json:"requires_trace"`
+	Description     string `json:"description,omitempty"`
+}
+
+// MethodStats is platform-wide usage of an RPC method on one chain
+type MethodStats struct {
+	ChainSlug            string              `json:"chain_slug"`
+	Method               string              `json:"method"`
+	Requests             uint64              `json:"requests"`
+	UniqueConsumers      uint64              `json:"unique_consumers"`
+	UniqueOrganizations  uint64              `json:"unique_organizations"`
+	ComputeUnits         uint64              `json:"compute_units"`
+	AvgComputeUnits      float64             `json:"avg_compute_units"`
+	ComputeUnitsSharePct float64             `json:"compute_units_share_pct"`
+	ErrorCount           uint64              `json:"error_count"`
+	ErrorRatePct         float64             `json:"error_rate_pct"`
+	AvgLatencyMS         float64             `json:"avg_latency_ms"`
+	P95LatencyMS         float64             `json:"p95_latency_ms"`
+	Properties           *MethodComputeUnits `json:"properties,omitempty"`
+}
+
+// MethodAnalytics ranks RPC methods across all organizations
+type MethodAnalytics struct {
+	Period            Period        `json:"period"`
+	ChainSlug         string        `json:"chain_slug,omitempty"`
+	SortBy            string        `json:"sort_by"`
+	TotalRequests     uint64        `json:"total_requests"`
+	TotalComputeUnits uint64        `json:"total_compute_units"`
+	Methods           []MethodStats `json:"methods"`
+}
+
+// MethodBucket is one interval of an RPC method's time series
+type MethodBucket struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Requests     uint64    `json:"requests"`
+	ComputeUnits uint64    `json:"compute_units"`
+	ErrorCount   uint64    `json:"error_count"`
+	ErrorRatePct float64   `json:"error_rate_pct"`
+	AvgLatencyMS float64   `json:"avg_latency_ms"`
+	P95LatencyMS float64   `json:"p95_latency_ms"`
+}
+
+// MethodTimeseries is an organization's usage of one RPC method over time
+type MethodTimeseries struct {
+	OrganizationID string              `json:"organization_id"`
+	Method         string              `json:"method"`
+	ChainSlug      string              `json:"chain_slug,omitempty"`
+	Period         Period              `json:"period"`
+	Interval       string              `json:"interval"`
+	Properties     *MethodComputeUnits `json:"properties,omitempty"`
+	Timeseries     []MethodBucket      `json:"timeseries"`
+}
+
+// ExpensiveMethodUsage is a consumer's use of one expensive RPC method
+type ExpensiveMethodUsage struct {
+	ConsumerID     string    `json:"consumer_id"`
+	ChainSlug      string    `json:"chain_slug"`
+	Method         string    `json:"method"`
+	Calls          uint64    `json:"calls"`
+	ComputeUnits   uint64    `json:"compute_units"`
+	ErrorCount     uint64    `json:"error_count"`
+	AvgLatencyMS   float64   `json:"avg_latency_ms"`
+	P95LatencyMS   float64   `json:"p95_latency_ms"`
+	MaxLatencyMS   uint32    `json:"max_latency_ms"`
+	PeakRatePerSec float64   `json:"peak_rate_per_sec"`
+	FirstSeen      time.Time `json:"first_seen"`
+	LastSeen       time.Time `json:"last_seen"`
+}
+
+// ExpensiveCall is a single expensive RPC call
+type ExpensiveCall struct {
+	Timestamp    time.Time `json:"timestamp"`
+	ConsumerID   string    `json:"consumer_id"`
+	ChainSlug    string    `json:"chain_slug"`
+	Method       string    `json:"method"`
+	ComputeUnits uint32    `json:"compute_units"`
+	LatencyMS    uint32    `json:"latency_ms"`
+	StatusCode   uint16    `json:"status_code"`
+}
+
+// ExpensiveMethodAlert is a period in which a consumer met the condition of
+// the ExpensiveMethodAbuse Prometheus alert for one method
+type ExpensiveMethodAlert struct {
+	ConsumerID     string    `json:"consumer_id"`
+	Method         string    `json:"method"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	Calls          uint64    `json:"calls"`
+	PeakRatePerSec float64   `json:"peak_rate_per_sec"`
+}
+
+// ExpensiveMethodReport audits an organization's expensive RPC calls
+type ExpensiveMethodReport struct {
+	OrganizationID    string                 `json:"organization_id"`
+	Period            Period                 `json:"period"`
+	TotalCalls        uint64                 `json:"total_calls"`
+	TotalComputeUnits uint64                 `json:"total_compute_units"`
+	Usage             []ExpensiveMethodUsage `json:"usage"`
+	SlowestCalls      []ExpensiveCall        `json:"slowest_calls"`
+	Alerts            []ExpensiveMethodAlert `json:"alerts"`
+}
+
+// ExpensiveMethodShare is an organization's expensive calls relative to all
+// of its traffic
+type ExpensiveMethodShare struct {
+	OrganizationID        string  `json:"organization_id"`
+	OrganizationName      string  `json:"organization_name,omitempty"`
+	ExpensiveCalls        uint64  `json:"expensive_calls"`
+	ExpensiveComputeUnits uint64  `json:"expensive_compute_units"`
+	TotalRequests         uint64  `json:"total_requests"`
+	TotalComputeUnits     uint64  `json:"total_compute_units"`
+	CallSharePct          float64 `json:"call_share_pct"`
+	ComputeUnitSharePct   float64 `json:"compute_unit_share_pct"`
+	Consumers             uint64  `json:"consumers"`
+	TopMethod             string  `json:"top_method"`
+}
@@ -37,6 +37,7 @@ type Plan struct {
 	RateLimitPerMinute int      `json:"rate_limit_per_minute"`
 	RateLimitPerHour   int      `json:"rate_limit_per_hour"`
 	RateLimitPerDay    int      `json:"rate_limit_per_day"`
+	BurstMultiplier    float64  `json:"burst_multiplier"`
 	PriceMonthly       float64  `json:"price_monthly"`
 	PriceYearly        float64  `json:"price_yearly"`
 	Currency           string   `json:"currency"`
@@ -48,6 +49,19 @@ type Plan struct {
 	IsPublic           bool     `json:"is_public"`
 }
 
+// PlanChainLimit is a plan's rate limits for one chain (plan_chain_limits).
+// Zero means no limit for that window.
+type PlanChainLimit struct {
+	ChainSlug             string `json:"chain_slug"`
+	RateLimitPerSecond    int    `json:"rate_limit_per_second"`
+	RateLimitPerMinute    int    `json:"rate_limit_per_minute"`
+	RateLimitPerHour      int    `json:"rate_limit_per_hour"`
+	RateLimitPerDay       int    `json:"rate_limit_per_day"`
+	ComputeUnitsPerSecond int    `json:"compute_units_per_second"`
+	ComputeUnitsPerDay    int    `json:"compute_units_per_day"`
+	IsOverride            bool   `json:"is_override"`
+}
+
 // Subscription represents an active subscription
 type Subscription struct {
 	ID                 string     `json:"id"`
@@ -80,6 +94,8 @@ type APIKey struct {
 	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
 	AllowedChains     []string   `json:"allowed_chains,omitempty"`
 	RestrictedMethods []string   `json:"restricted_methods,omitempty"`
+	Plan              string     `json:"plan,omitempty"`   // from Unkey key metadata
+	Scopes            []string   `json:"scopes,omitempty"` // from Unkey key permissions and metadata
 	CreatedAt         time.Time  `json:"created_at"`
 	UpdatedAt         time.Time  `json:"updated_at,omitempty"`
 	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// RequestFilter narrows a request search; zero values match everything
+type RequestFilter struct {
+	ChainSlug string
+	RPCMethod string
+	// Exact status code, or a class (2xx..5xx) when StatusClass is set
+	StatusCode   int
+	StatusClass  int
+	MinLatencyMS int
+}
+
+// RequestTrace is a single request from ClickHouse requests_raw
+type RequestTrace struct {
+	Timestamp         time.Time `json:"timestamp"`
+	RequestID         string    `json:"request_id"`
+	OrganizationID    string    `json:"organization_id"`
+	ConsumerID        string    `json:"consumer_id"`
+	APIKeyPrefix      string    `json:"api_key_prefix"`
+	PlanSlug          string    `json:"plan_slug"`
+	ChainSlug         string    `json:"chain_slug"`
+	ChainType         string    `json:"chain_type"`
+	ChainID           string    `json:"chain_id"`
+	Method            string    `json:"method"`
+	Path              string    `json:"path"`
+	RouteName         string    `json:"route_name"`
+	StatusCode        uint16    `json:"status_code"`
+	ResponseSize      uint32    `json:"response_size"`
+	LatencyMS         uint32    `json:"latency_ms"`
+	UpstreamLatencyMS uint32    `json:"upstream_latency_ms"`
+	KongLatencyMS     uint32    `json:"kong_latency_ms"`
+	UpstreamHost      string    `json:"upstream_host"`
+	UpstreamStatus    uint16    `json:"upstream_status"`
+	ClientIP          string    `json:"client_ip"` // masked
+	UserAgent         string    `json:"user_agent"`
+	RPCMethod         string    `json:"rpc_method"`
+	RPCID             string    `json:"rpc_id"`
+	ComputeUnits      uint32    `json:"compute_units"`
+	ErrorMessage      string    `json:"error_message,omitempty"`
+	IsError           bool      `json:"is_error"`
+	Metadata          string    `json:"metadata,omitempty"` // JSON
+}
+
+// RequestsPage is one page of a request search
+type RequestsPage struct {
+	OrganizationID string         `json:"organization_id"`
+	Period         Period         `json:"period"`
+	Requests       []RequestTrace `json:"requests"`
+	NextCursor     string         `json:"next_cursor,omitempty"`
+}
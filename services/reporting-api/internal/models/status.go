@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Incident is a period of degraded performance or outage of a chain
+type Incident struct {
+	ID               string     `json:"id"`
+	ChainSlug        string     `json:"chain_slug"`
+	ChainName        string     `json:"chain_name"`
+	Status           string     `json:"status"` // degraded, outage (worst state reached)
+	Title            string     `json:"title"`
+	StartedAt        time.Time  `json:"started_at"`
+	ResolvedAt       *time.Time `json:"resolved_at,omitempty"`
+	PeakErrorRatePct float64    `json:"peak_error_rate_pct"`
+	PeakP95LatencyMS float64    `json:"peak_p95_latency_ms"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// ChainStatus is the public status of one chain
+type ChainStatus struct {
+	Slug           string    `json:"slug"`
+	Name           string    `json:"name"`
+	Status         string    `json:"status"` // operational, degraded, outage, unknown
+	Reason         string    `json:"reason,omitempty"`
+	RequestCount   uint64    `json:"request_count"`
+	ErrorRatePct   float64   `json:"error_rate_pct"`
+	P95LatencyMS   float64   `json:"p95_latency_ms"`
+	UptimePct90d   float64   `json:"uptime_pct_90d"`
+	ActiveIncident *Incident `json:"active_incident,omitempty"`
+}
+
+// StatusPage is the public status page document
+type StatusPage struct {
+	Status          string        `json:"status"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+	Chains          []ChainStatus `json:"chains"`
+	ActiveIncidents []Incident    `json:"active_incidents"`
+}
@@ -10,12 +10,61 @@ type UsageSummary struct {
 	ByChain        []ChainUsage   `json:"by_chain"`
 	TopMethods     []MethodUsage  `json:"top_methods,omitempty"`
 	DailyBreakdown []DailyUsage   `json:"daily_breakdown,omitempty"`
+
+	Comparison *UsageComparison `json:"comparison,omitempty"`
+}
+
+// UsageComparison holds the same aggregation over an earlier window and the
+// change from it
+type UsageComparison struct {
+	Compare string            `json:"compare"` // previous_period, previous_year
+	Period  Period            `json:"period"`
+	Summary SummaryMetrics    `json:"summary"`
+	Deltas  UsageDeltas       `json:"deltas"`
+	ByChain []ChainComparison `json:"by_chain"`
+}
+
+// UsageDeltas is the change of each compared metric
+type UsageDeltas struct {
+	Requests     MetricDelta `json:"requests"`
+	ComputeUnits MetricDelta `json:"compute_units"`
+	EgressGB     MetricDelta `json:"egress_gb"`
+	ErrorRatePct MetricDelta `json:"error_rate_pct"`
+	LatencyP95MS MetricDelta `json:"latency_p95_ms"`
+}
+
+// MetricDelta compares a metric with its previous value. ChangePct is nil
+// when the previous value is 0.
+type MetricDelta struct {
+	Current   float64  `json:"current"`
+	Previous  float64  `json:"previous"`
+	Change    float64  `json:"change"`
+	ChangePct *float64 `json:"change_pct"`
+}
+
+// ChainComparison is the change of one chain's usage; chains used in only one
+// of the windows compare with zero
+type ChainComparison struct {
+	ChainSlug string      `json:"chain_slug"`
+	ChainType string      `json:"chain_type"`
+	Deltas    UsageDeltas `json:"deltas"`
 }
 
-// Period represents a time range
+// Period represents a time range; Timezone is the IANA zone of its day
+// boundaries and time buckets
 type Period struct {
-	Start time.Time `json:"start"`
-	End   time.Time `json:"end"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Timezone string    `json:"timezone"`
+}
+
+// NewPeriod returns the period between start and end in the time zone of start
+func NewPeriod(start, end time.Time) Period {
+	return Period{
+		Start:    start,
+		End:      end,
+		Timezone: start.Location().String(),
+	}
 }
 
 // SummaryMetrics contains aggregated metrics
@@ -85,12 +134,128 @@ type APIKeyUsage struct {
 	LastUsed       *time.Time     `json:"last_used,omitempty"`
 }
 
+// KeyUsage is an API key of an organization with its usage over a period
+type KeyUsage struct {
+	ID            string         `json:"id"`
+	KeyPrefix     string         `json:"key_prefix"`
+	Name          string         `json:"name"`
+	ConsumerID    string         `json:"consumer_id"`
+	Status        string         `json:"status"` // active, revoked, expired
+	ExpiresAt     *time.Time     `json:"expires_at,omitempty"`
+	AllowedChains []string       `json:"allowed_chains"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Summary       SummaryMetrics `json:"summary"`
+	ByChain       []ChainUsage   `json:"by_chain"`
+	LastUsed      *time.Time     `json:"last_used,omitempty"`
+}
+
 // UsageQueryParams contains common query parameters
 type UsageQueryParams struct {
 	StartDate   time.Time
 	EndDate     time.Time
 	ChainSlug   string
-	Aggregation string // hour, day, month
+	Aggregation string // minute, hour, day, week, month
+	Metrics     []string
+	GroupBy     []string
+	Filters     map[string][]string // dimension -> accepted values
 	Limit       int
 	Offset      int
 }
+
+// UsageTimeseries is usage bucketed by time, optionally split into one series
+// per group
+type UsageTimeseries struct {
+	OrganizationID string              `json:"organization_id"`
+	Period         Period              `json:"period"`
+	Granularity    string              `json:"granularity"`
+	Source         string              `json:"source"`
+	Metrics        []string            `json:"metrics"`
+	GroupBy        []string            `json:"group_by,omitempty"`
+	Filters        map[string][]string `json:"filters,omitempty"`
+	Series         []TimeseriesSeries  `json:"series"`
+	Truncated      bool                `json:"truncated,omitempty"`
+}
+
+// TimeseriesSeries is the points of one group; Group is empty without
+// group_by. Buckets without traffic are omitted.
+type TimeseriesSeries struct {
+	Group  map[string]string `json:"group,omitempty"`
+	Points []TimeseriesPoint `json:"points"`
+}
+
+// UsageBreakdown is usage over a period grouped by any combination of
+// dimensions
+type UsageBreakdown struct {
+	OrganizationID string              `json:"organization_id"`
+	Period         Period              `json:"period"`
+	Source         string              `json:"source"`
+	Metrics        []string            `json:"metrics"`
+	GroupBy        []string            `json:"group_by,omitempty"`
+	Filters        map[string][]string `json:"filters,omitempty"`
+	Groups         []UsageGroup        `json:"groups"`
+	Truncated      bool                `json:"truncated,omitempty"`
+}
+
+// UsageGroup holds the requested metrics of one combination of dimension
+// values; Group is empty without group_by
+type UsageGroup struct {
+	Group   map[string]string  `json:"group,omitempty"`
+	Metrics map[string]float64 `json:"metrics"`
+}
+
+// TimeseriesPoint holds the requested metrics of one bucket
+type TimeseriesPoint struct {
+	Timestamp time.Time          `json:"timestamp"`
+	Metrics   map[string]float64 `json:"metrics"`
+}
+
+// RateLimitEvent is a rejected request as stored in ClickHouse rate_limit_events
+type RateLimitEvent struct {
+	Timestamp      time.Time `json:"timestamp"`
+	OrganizationID string    `json:"organization_id"`
+	ConsumerID     string    `json:"consumer_id"`
+	PlanSlug       string    `json:"plan_slug"`
+	LimitType      string    `json:"limit_type"` // second, minute, hour, day
+	LimitValue     uint32    `json:"limit_value"`
+	CurrentCount   uint32    `json:"current_count"`
+	ClientIP       string    `json:"client_ip"`
+	Metadata       string    `json:"metadata,omitempty"` // JSON
+}
+
+// RateLimitReport explains why an organization was throttled
+type RateLimitReport struct {
+	OrganizationID  string                 `json:"organization_id"`
+	Period          Period                 `json:"period"`
+	Interval        string                 `json:"interval"` // hour, day
+	TotalRejections uint64                 `json:"total_rejections"`
+	ByLimitType     []RateLimitTypeSummary `json:"by_limit_type"`
+	ByConsumer      []RateLimitConsumer    `json:"by_consumer"`
+	Timeseries      []RateLimitBucket      `json:"timeseries"`
+}
+
+// RateLimitTypeSummary aggregates rejections of one window
+type RateLimitTypeSummary struct {
+	LimitType      string  `json:"limit_type"`
+	Rejections     uint64  `json:"rejections"`
+	LimitValue     uint32  `json:"limit_value"`
+	PeakUsageRatio float64 `json:"peak_usage_ratio"` // max current_count / limit_value
+}
+
+// RateLimitConsumer aggregates rejections of one consumer
+type RateLimitConsumer struct {
+	ConsumerID     string    `json:"consumer_id"`
+	Rejections     uint64    `json:"rejections"`
+	PeakUsageRatio float64   `json:"peak_usage_ratio"`
+	LastRejectedAt time.Time `json:"last_rejected_at"`
+}
+
+// RateLimitBucket is the rejection count of one window and consumer in one interval
+type RateLimitBucket struct {
+	Timestamp        time.Time `json:"timestamp"`
+	LimitType        string    `json:"limit_type"`
+	ConsumerID       string    `json:"consumer_id"`
+	Rejections       uint64    `json:"rejections"`
+	PeakCurrentCount uint32    `json:"peak_current_count"`
+	LimitValue       uint32    `json:"limit_value"`
+	PeakUsageRatio   float64   `json:"peak_usage_ratio"`
+}
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrPeriodClosed is returned when a subscription period was invoiced by
+// another run in the meantime
+var ErrPeriodClosed = errors.New("subscription period already closed")
+
+// billableSubscriptionColumns selects a subscription with its plan's pricing
+// for scanBillableSubscription
+const billableSubscriptionColumns = `
+	s.id,
+	s.organization_id,
+	COALESCE(s.billing_period, 'monthly') as billing_period,
+	s.current_period_start,
+	s.current_period_end,
+	COALESCE(s.cancel_at_period_end, false) as cancel_at_period_end,
+	p.id,
+	p.slug,
+	p.name,
+	COALESCE(p.price_monthly, 0)::float8 as price_monthly,
+	COALESCE(p.price_yearly, 0)::float8 as price_yearly,
+	COALESCE(p.currency, 'USD') as currency,
+	p.included_compute_units,
+	p.included_requests,
+	p.included_egress_gb::float8,
+	p.overage_per_million_compute_units::float8,
+	p.overage_per_million_requests::float8,
+	p.overage_per_gb::float8
+`
+
+func scanBillableSubscription(row pgx.Row) (models.BillableSubscription, error) {
+	var sub models.BillableSubscription
+	pricing := &sub.Pricing
+	err := row.Scan(
+		&sub.ID,
+		&sub.OrganizationID,
+		&sub.BillingPeriod,
+		&sub.CurrentPeriodStart,
+		&sub.CurrentPeriodEnd,
+		&sub.CancelAtPeriodEnd,
+		&pricing.PlanID,
+		&pricing.PlanSlug,
+		&pricing.PlanName,
+		&pricing.PriceMonthly,
+		&pricing.PriceYearly,
+		&pricing.Currency,
+		&pricing.IncludedComputeUnits,
+		&pricing.IncludedRequests,
+		&pricing.IncludedEgressGB,
+		&pricing.OveragePerMillionComputeUnits,
+		&pricing.OveragePerMillionRequests,
+		&pricing.OveragePerGB,
+	)
+	return sub, err
+}
+
+// ListDueSubscriptions retrieves active subscriptions whose current period
+// ended at or before now, with their plan's pricing. Empty orgID covers every
+// organization.
+func (r *PostgresRepository) ListDueSubscriptions(ctx context.Context, orgID string, now time.Time) ([]models.BillableSubscription, error) {
+	query := `
+		SELECT ` + billableSubscriptionColumns + `
+		FROM subscriptions s
+		JOIN plans p ON s.plan_id = p.id
+		WHERE s.status = 'active'
+		  AND s.current_period_start IS NOT NULL
+		  AND s.current_period_end <= $1
+		  AND ($2 = '' OR s.organization_id::text = $2)
+		ORDER BY s.current_period_end, s.id
+	`
+
+	rows, err := r.pool.Query(ctx, query, now, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []models.BillableSubscription
+	for rows.Next() {
+		sub, err := scanBillableSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan due subscription row: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
+
+// GetBillableSubscription retrieves an organization's active subscription
+// with its plan's pricing, or nil when it has none with a current period
+func (r *PostgresRepository) GetBillableSubscription(ctx context.Context, orgID string) (*models.BillableSubscription, error) {
+	query := `
+		SELECT ` + billableSubscriptionColumns + `
+		FROM subscriptions s
+		JOIN plans p ON s.plan_id = p.id
+		WHERE s.organization_id = $1
+		  AND s.status = 'active'
+		  AND s.current_period_start IS NOT NULL
+		  AND s.current_period_end IS NOT NULL
+		ORDER BY s.current_period_end DESC
+		LIMIT 1
+	`
+
+	sub, err := scanBillableSubscription(r.pool.QueryRow(ctx, query, orgID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get billable subscription: %w", err)
+	}
+
+	return &sub, nil
+}
+
+// CloseSubscriptionPeriod writes a draft invoice for the subscription's
+// current period and moves the subscription to its next period, or cancels
+// it when it was set to cancel at period end. The invoice number is taken
+// from the numberPrefix counter in the same transaction, so numbers have no
+// gaps. It returns ErrPeriodClosed when the period was already closed.
+func (r *PostgresRepository) CloseSubscriptionPeriod(ctx context.Context, sub models.BillableSubscription, invoice *models.Invoice, numberPrefix string, nextStart, nextEnd time.Time) error {
+	lineItems, err := json.Marshal(invoice.LineItems)
+	if err != nil {
+		return fmt.Errorf("failed to encode invoice line items: %w", err)
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin invoice transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Guarded on the period start so concurrent runs close a period once
+	var tag pgconn.CommandTag
+	if sub.CancelAtPeriodEnd {
+		tag, err = tx.Exec(ctx, `
+			UPDATE subscriptions
+			SET status = 'canceled',
+			    canceled_at = COALESCE(canceled_at, NOW())
+			WHERE id = $1
+			  AND current_period_start = $2
+			  AND status = 'active'
+		`, sub.ID, sub.CurrentPeriodStart)
+	} else {
+		tag, err = tx.Exec(ctx, `
+			UPDATE subscriptions
+			SET current_period_start = $3,
+			    current_period_end = $4
+			WHERE id = $1
+			  AND current_period_start = $2
+			  AND status = 'active'
+		`, sub.ID, sub.CurrentPeriodStart, nextStart, nextEnd)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to advance subscription period: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPeriodClosed
+	}
+
+	var number int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO invoice_number_sequences (prefix, last_value)
+		VALUES ($1, 1)
+		ON CONFLICT (prefix) DO UPDATE
+		SET last_value = invoice_number_sequences.last_value + 1,
+		    updated_at = NOW()
+		RETURNING last_value
+	`, numberPrefix).Scan(&number)
+	if err != nil {
+		return fmt.Errorf("failed to allocate invoice number: %w", err)
+	}
+	invoice.InvoiceNumber = fmt.Sprintf("%s-%06d", numberPrefix, number)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO invoices (
+			organization_id,
+			subscription_id,
+			invoice_number,
+			subtotal,
+			tax,
+			total,
+			currency,
+			status,
+			period_start,
+			period_end,
+			due_date,
+			line_items,
+			rating_rule_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, 'draft', $8, $9, $10, $11, $12)
+		RETURNING id, status, created_at
+	`,
+		invoice.OrganizationID,
+		invoice.SubscriptionID,
+		invoice.InvoiceNumber,
+		invoice.Subtotal,
+		invoice.Tax,
+		invoice.Total,
+		invoice.Currency,
+		invoice.PeriodStart,
+		invoice.PeriodEnd,
+		invoice.DueDate,
+		lineItems,
+		invoice.RatingRuleID,
+	).Scan(&invoice.ID, &invoice.Status, &invoice.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert invoice: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit invoice: %w", err)
+	}
+
+	return nil
+}
+
+// GetRatingRules retrieves the version of a plan's rating rules effective at
+// the given time, or nil when the plan has none
+func (r *PostgresRepository) GetRatingRules(ctx context.Context, planID string, at time.Time) (*models.RatingRules, error) {
+	query := `
+		SELECT id, plan_id, version, COALESCE(description, ''), effective_from, definition
+		FROM rating_rules
+		WHERE plan_id = $1
+		  AND effective_from <= $2
+		ORDER BY effective_from DESC, version DESC
+		LIMIT 1
+	`
+
+	var rules models.RatingRules
+	var definition []byte
+	err := r.pool.QueryRow(ctx, query, planID, at).Scan(
+		&rules.ID,
+		&rules.PlanID,
+		&rules.Version,
+		&rules.Description,
+		&rules.EffectiveFrom,
+		&definition,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get rating rules: %w", err)
+	}
+
+	if err := json.Unmarshal(definition, &rules.Definition); err != nil {
+		return nil, fmt.Errorf("failed to decode rating rules %s version %d: %w", rules.PlanID, rules.Version, err)
+	}
+
+	return &rules, nil
+}
+
+// GetBillableUsage retrieves an organization's usage per chain from
+// usage_daily. Days are UTC; the period covers the days from start up to,
+// but not including, the day of end. With byMethod usage is also split by
+// RPC method, read from org_method_daily, which is kept as long as
+// usage_daily.
+func (r *ClickHouseRepository) GetBillableUsage(ctx context.Context, orgID string, startDate, endDate time.Time, byMethod bool) ([]models.BillableUsage, error) {
+	query := `
+		SELECT
+			chain_slug,
+			'' AS rpc_method,
+			sumMerge(request_count) AS requests,
+			sumMerge(compute_units_used) AS compute_units,
+			sumMerge(total_response_size) AS egress_bytes
+		FROM usage_daily
+		WHERE organization_id = ?
+		  AND date >= toDate(?)
+		  AND date < toDate(?)
+		GROUP BY chain_slug
+		ORDER BY chain_slug
+	`
+	if byMethod {
+		query = `
+			SELECT
+				chain_slug,
+				rpc_method,
+				sum(request_count) AS requests,
+				sum(compute_units_used) AS compute_units,
+				sum(total_response_size) AS egress_bytes
+			FROM org_method_daily
+			WHERE organization_id = ?
+			  AND date >= toDate(?)
+			  AND date < toDate(?)
+			GROUP BY chain_slug, rpc_method
+			ORDER BY chain_slug, rpc_method
+		`
+	}
+
+	rows, err := r.conn.Query(ctx, query, orgID, startDate.UTC(), endDate.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billable usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []models.BillableUsage
+	for rows.Next() {
+		var u models.BillableUsage
+		if err := rows.Scan(&u.ChainSlug, &u.Method, &u.Requests, &u.ComputeUnits, &u.EgressBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan billable usage row: %w", err)
+		}
+		usage = append(usage, u)
+	}
+
+	return usage, rows.Err()
+}
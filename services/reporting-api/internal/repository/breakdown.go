@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// Limits of dimensional queries. Group counts multiply, so grouping and
+// filters are capped to keep result sizes predictable.
+const (
+	maxGroupByDimensions = 3
+	maxFilterValues      = 100
+
+	// MaxBreakdownGroups caps the groups returned by GetUsageBreakdown
+	MaxBreakdownGroups = 1000
+)
+
+// usageDimension is a group_by or filter option and the usage tables having
+// its column
+type usageDimension struct {
+	column  string
+	sources map[string]bool
+}
+
+var allSources = map[string]bool{SourceRequestsRaw: true, SourceUsageHourly: true, SourceUsageDaily: true}
+
+// usageDimensions is the allowlist of dimensions. Only these column names
+// reach the SQL text; filter values are always bound as parameters.
+var usageDimensions = map[string]usageDimension{
+	"chain":      {"chain_slug", allSources},
+	"chain_type": {"chain_type", allSources},
+	"consumer":   {"consumer_id", allSources},
+	"api_key":    {"api_key_prefix", allSources},
+	"plan":       {"plan_slug", allSources},
+	"method":     {"rpc_method", map[string]bool{SourceRequestsRaw: true, SourceUsageHourly: true}},
+	"route":      {"route_name", map[string]bool{SourceRequestsRaw: true, SourceUsageHourly: true}},
+}
+
+const usageDimensionNames = "chain, chain_type, consumer, api_key, plan, method, route"
+
+// ValidateUsageDimensions checks group-by dimensions and filters against the
+// allowlist and the cardinality limits
+func ValidateUsageDimensions(groupBy []string, filters map[string][]string) error {
+	if len(groupBy) > maxGroupByDimensions {
+		return fmt.Errorf("group_by takes at most %d dimensions", maxGroupByDimensions)
+	}
+	seen := make(map[string]bool, len(groupBy))
+	for _, dimension := range groupBy {
+		if _, ok := usageDimensions[dimension]; !ok {
+			return fmt.Errorf("group_by must be a comma-separated list of %s", usageDimensionNames)
+		}
+		if seen[dimension] {
+			return fmt.Errorf("group_by lists %s more than once", dimension)
+		}
+		seen[dimension] = true
+	}
+
+	for dimension, values := range filters {
+		if _, ok := usageDimensions[dimension]; !ok {
+			return fmt.Errorf("filters must be on one of %s", usageDimensionNames)
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("filter on %s needs at least one value", dimension)
+		}
+		if len(values) > maxFilterValues {
+			return fmt.Errorf("filter on %s takes at most %d values", dimension, maxFilterValues)
+		}
+	}
+
+	return nil
+}
+
+// UsageSource picks the rollup for a range starting at startDate:
+// usage_hourly within its retention and usage_daily beyond. It fails when a
+// grouped or filtered dimension is not kept that far back.
+func UsageSource(groupBy []string, filters map[string][]string, startDate, now time.Time) (string, error) {
+	source := SourceUsageDaily
+	if now.Sub(startDate) <= sourceRetention[SourceUsageHourly] {
+		source = SourceUsageHourly
+	}
+
+	for _, dimension := range groupBy {
+		if !usageDimensions[dimension].sources[source] {
+			return "", fmt.Errorf("group_by %s is only available for the last 90 days", dimension)
+		}
+	}
+	for dimension := range filters {
+		if !usageDimensions[dimension].sources[source] {
+			return "", fmt.Errorf("filtering on %s is only available for the last 90 days", dimension)
+		}
+	}
+
+	return source, nil
+}
+
+// dimensionColumns returns the columns of allowlisted dimensions
+func dimensionColumns(dimensions []string) []string {
+	columns := make([]string, len(dimensions))
+	for i, dimension := range dimensions {
+		columns[i] = usageDimensions[dimension].column
+	}
+	return columns
+}
+
+// dimensionFilters returns the WHERE conditions and arguments of filters in
+// a stable order
+func dimensionFilters(filters map[string][]string) ([]string, []interface{}) {
+	dimensions := make([]string, 0, len(filters))
+	for dimension := range filters {
+		dimensions = append(dimensions, dimension)
+	}
+	sort.Strings(dimensions)
+
+	conditions := make([]string, 0, len(dimensions))
+	args := make([]interface{}, 0, len(dimensions))
+	for _, dimension := range dimensions {
+		conditions = append(conditions, "has(?, "+usageDimensions[dimension].column+")")
+		args = append(args, filters[dimension])
+	}
+	return conditions, args
+}
+
+// metricExpr returns a metric's expression over source. Ratios and quantiles
+// of empty groups are not finite and JSON cannot encode them, so those are 0.
+func metricExpr(name, source string) (string, error) {
+	metric, ok := timeseriesMetrics[name]
+	if !ok {
+		return "", fmt.Errorf("invalid usage metric: %s", name)
+	}
+	expr := metric.aggregate
+	if source == SourceRequestsRaw {
+		expr = metric.raw
+	}
+	return "ifNotFinite(toFloat64(" + expr + "), 0)", nil
+}
+
+// groupMap pairs dimensions with a row's values; nil without dimensions
+func groupMap(dimensions, values []string) map[string]string {
+	if len(dimensions) == 0 {
+		return nil
+	}
+	group := make(map[string]string, len(dimensions))
+	for i, dimension := range dimensions {
+		group[dimension] = values[i]
+	}
+	return group
+}
+
+// sameGroup reports whether group holds values for dimensions
+func sameGroup(group map[string]string, dimensions, values []string) bool {
+	for i, dimension := range dimensions {
+		if group[dimension] != values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// GetUsageBreakdown aggregates an organization's usage over the period by
+// params.GroupBy, ordered by the first metric. params must have passed
+// ValidateUsageDimensions and UsageSource. Without dimensions it returns a
+// single group of totals.
+func (r *ClickHouseRepository) GetUsageBreakdown(ctx context.Context, orgID string, params models.UsageQueryParams) (*models.UsageBreakdown, error) {
+	if len(params.Metrics) == 0 {
+		return nil, fmt.Errorf("at least one usage metric is required")
+	}
+	source, err := UsageSource(params.GroupBy, params.Filters, params.StartDate, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	columns := dimensionColumns(params.GroupBy)
+	selects := append([]string{}, columns...)
+	for i, name := range params.Metrics {
+		expr, err := metricExpr(name, source)
+		if err != nil {
+			return nil, err
+		}
+		selects = append(selects, fmt.Sprintf("%s AS m%d", expr, i))
+	}
+
+	conditions := []string{"organization_id = ?"}
+	args := []interface{}{orgID}
+	if source == SourceUsageHourly {
+		conditions = append(conditions, "hour >= toStartOfHour(?)", "hour <= ?")
+	} else {
+		conditions = append(conditions, "date >= toDate(?)", "date <= toDate(?)")
+	}
+	args = append(args, params.StartDate, params.EndDate)
+	filterConditions, filterArgs := dimensionFilters(params.Filters)
+	conditions = append(conditions, filterConditions...)
+	args = append(args, filterArgs...)
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(selects, ", "), source, strings.Join(conditions, " AND "))
+	if len(columns) > 0 {
+		// One extra row tells whether the result was truncated
+		query += fmt.Sprintf(" GROUP BY %s ORDER BY m0 DESC, %s LIMIT ?", strings.Join(columns, ", "), strings.Join(columns, ", "))
+		args = append(args, params.Limit+1)
+	}
+
+	rows, err := r.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage breakdown: %w", err)
+	}
+	defer rows.Close()
+
+	breakdown := &models.UsageBreakdown{
+		OrganizationID: orgID,
+		Period:         models.NewPeriod(params.StartDate, params.EndDate),
+		Source:         source,
+		Metrics:        params.Metrics,
+		GroupBy:        params.GroupBy,
+		Filters:        params.Filters,
+		Groups:         []models.UsageGroup{},
+	}
+
+	group := make([]string, len(params.GroupBy))
+	values := make([]float64, len(params.Metrics))
+	dest := make([]interface{}, 0, len(group)+len(values))
+	for i := range group {
+		dest = append(dest, &group[i])
+	}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+
+	for rows.Next() {
+		if len(columns) > 0 && len(breakdown.Groups) == params.Limit {
+			breakdown.Truncated = true
+			break
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan usage breakdown row: %w", err)
+		}
+
+		usage := models.UsageGroup{
+			Group:   groupMap(params.GroupBy, group),
+			Metrics: make(map[string]float64, len(values)),
+		}
+		for i, name := range params.Metrics {
+			usage.Metrics[name] = values[i]
+		}
+		breakdown.Groups = append(breakdown.Groups, usage)
+	}
+
+	return breakdown, rows.Err()
+}
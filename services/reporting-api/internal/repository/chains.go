@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// chainHealthMetrics aggregates chain_health_minutely rows; must stay in sync
+// with scanChainHealth. Rows of one minute may not be merged yet, so every
+// metric is built from sums and quantile states rather than stored rates.
+const chainHealthMetrics = `
+	sum(request_count) AS requests,
+	sum(error_count) AS errors,
+	ifNull(sum(error_count) / nullIf(sum(request_count), 0) * 100, 0) AS error_rate_pct,
+	ifNull(sum(latency_sum_ms) / nullIf(sum(request_count), 0), 0) AS avg_latency,
+	toFloat64(quantilesMerge(0.95, 0.99)(latency_quantiles)[1]) AS p95_latency,
+	toFloat64(quantilesMerge(0.95, 0.99)(latency_quantiles)[2]) AS p99_latency,
+	uniqExactIf(upstream_host, healthy_count > 0) AS healthy_upstreams,
+	uniqExact(upstream_host) AS total_upstreams`
+
+func scanChainHealth(rows driver.Rows, key *string, health *models.ChainHealth) error {
+	dest := []interface{}{}
+	if key != nil {
+		dest = append(dest, key)
+	}
+	dest = append(dest,
+		&health.Timestamp,
+		&health.RequestCount,
+		&health.ErrorCount,
+		&health.ErrorRatePct,
+		&health.AvgLatencyMS,
+		&health.P95LatencyMS,
+		&health.P99LatencyMS,
+		&health.HealthyUpstreams,
+		&health.TotalUpstreams,
+	)
+	return rows.Scan(dest...)
+}
+
+// GetChainHealthSummary returns the health of every chain with traffic since
+// the given time, keyed by chain slug
+func (r *ClickHouseRepository) GetChainHealthSummary(ctx context.Context, since time.Time) (map[string]models.ChainHealth, error) {
+	query := `
+		SELECT
+			chain_slug,
+			max(timestamp) AS last_seen,
+			` + chainHealthMetrics + `
+		FROM chain_health_minutely
+		WHERE timestamp >= ?
+		GROUP BY chain_slug
+	`
+
+	rows, err := r.conn.Query(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain health summary: %w", err)
+	}
+	defer rows.Close()
+
+	summary := make(map[string]models.ChainHealth)
+	for rows.Next() {
+		var slug string
+		var health models.ChainHealth
+		if err := scanChainHealth(rows, &slug, &health); err != nil {
+			return nil, fmt.Errorf("failed to scan chain health row: %w", err)
+		}
+		summary[slug] = health
+	}
+
+	return summary, rows.Err()
+}
+
+// GetChainHealthSeries returns a chain's health in buckets of bucketMinutes
+func (r *ClickHouseRepository) GetChainHealthSeries(ctx context.Context, chainSlug string, startTime, endTime time.Time, bucketMinutes int) ([]models.ChainHealth, error) {
+	query := `
+		SELECT
+			toStartOfInterval(timestamp, toIntervalMinute(?)) AS bucket,
+			` + chainHealthMetrics + `
+		FROM chain_health_minutely
+		WHERE chain_slug = ?
+		  AND timestamp >= ?
+		  AND timestamp <= ?
+		GROUP BY bucket
+		ORDER BY bucket ASC
+	`
+
+	rows, err := r.conn.Query(ctx, query, bucketMinutes, chainSlug, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain health series: %w", err)
+	}
+	defer rows.Close()
+
+	series := []models.ChainHealth{}
+	for rows.Next() {
+		var health models.ChainHealth
+		if err := scanChainHealth(rows, nil, &health); err != nil {
+			return nil, fmt.Errorf("failed to scan chain health row: %w", err)
+		}
+		series = append(series, health)
+	}
+
+	return series, rows.Err()
+}
+
+// GetUpstreamHealth returns the health of each upstream host of a chain with
+// traffic since the given time, keyed by host
+func (r *ClickHouseRepository) GetUpstreamHealth(ctx context.Context, chainSlug string, since time.Time) (map[string]models.ChainHealth, error) {
+	query := `
+		SELECT
+			upstream_host,
+			max(timestamp) AS last_seen,
+			` + chainHealthMetrics + `
+		FROM chain_health_minutely
+		WHERE chain_slug = ?
+		  AND timestamp >= ?
+		GROUP BY upstream_host
+	`
+
+	rows, err := r.conn.Query(ctx, query, chainSlug, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upstream health: %w", err)
+	}
+	defer rows.Close()
+
+	upstreams := make(map[string]models.ChainHealth)
+	for rows.Next() {
+		var host string
+		var health models.ChainHealth
+		if err := scanChainHealth(rows, &host, &health); err != nil {
+			return nil, fmt.Errorf("failed to scan upstream health row: %w", err)
+		}
+		upstreams[host] = health
+	}
+
+	return upstreams, rows.Err()
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
@@ -57,23 +58,45 @@ func (r *ClickHouseRepository) Ping(ctx context.Context) error {
 	return r.conn.Ping(ctx)
 }
 
+var zoneNamePattern = regexp.MustCompile(`^[A-Za-z0-9_+\-/]+$`)
+
+// zoneOf returns the time zone of t as a quoted literal for ClickHouse
+// functions such as toStartOfDay(ts, 'Europe/Istanbul')
+func zoneOf(t time.Time) string {
+	name := t.Location().String()
+	if name == "Local" || !zoneNamePattern.MatchString(name) {
+		name = "UTC"
+	}
+	return "'" + name + "'"
+}
+
+// usageRangeSource returns the table and time condition of a range query.
+// usage_daily holds UTC days, so ranges in other zones read usage_hourly.
+func usageRangeSource(startDate time.Time) (string, string) {
+	if zoneOf(startDate) == "'UTC'" {
+		return "usage_daily", "date >= ? AND date <= ?"
+	}
+	return "usage_hourly", "hour >= ? AND hour <= ?"
+}
+
 // GetUsageSummary retrieves aggregated usage data for an organization
 func (r *ClickHouseRepository) GetUsageSummary(ctx context.Context, orgID string, startDate, endDate time.Time) (*models.UsageSummary, error) {
-	query := `
+	table, timeRange := usageRangeSource(startDate)
+	query := fmt.Sprintf(`
 		SELECT
 			sumMerge(request_count) AS total_requests,
 			sumMerge(compute_units_used) AS total_compute_units,
 			sumMerge(total_response_size) / 1024.0 / 1024.0 / 1024.0 AS total_egress_gb,
 			sumMerge(error_count) AS error_count,
-			(sumMerge(error_count) / nullIf(sumMerge(request_count), 0)) * 100 AS error_rate_pct,
-			toFloat64(arrayElement(quantilesMerge(0.50, 0.95, 0.99)(latency_ms_quantiles), 2)) AS latency_p95,
-			toFloat64(arrayElement(quantilesMerge(0.50, 0.95, 0.99)(latency_ms_quantiles), 3)) AS latency_p99
-		FROM usage_daily
+			ifNull((sumMerge(error_count) / nullIf(sumMerge(request_count), 0)) * 100, 0) AS error_rate_pct,
+			ifNotFinite(toFloat64(arrayElement(quantilesMerge(0.50, 0.95, 0.99)(latency_ms_quantiles), 2)), 0) AS latency_p95,
+			ifNotFinite(toFloat64(arrayElement(quantilesMerge(0.50, 0.95, 0.99)(latency_ms_quantiles), 3)), 0) AS latency_p99
+		FROM %s
 		WHERE organization_id = ?
-		  AND date >= ?
-		  AND date <= ?
-	`
+		  AND %s
+	`, table, timeRange)
 
+	// Windows without traffic report zeros rather than NULL and NaN
 	var summary models.SummaryMetrics
 	err := r.conn.QueryRow(ctx, query, orgID, startDate, endDate).Scan(
 		&summary.TotalRequests,
@@ -94,17 +117,15 @@ func (r *ClickHouseRepository) GetUsageSummary(ctx context.Context, orgID string
 
 	return &models.UsageSummary{
 		OrganizationID: orgID,
-		Period: models.Period{
-			Start: startDate,
-			End:   endDate,
-		},
-		Summary: summary,
+		Period:         models.NewPeriod(startDate, endDate),
+		Summary:        summary,
 	}, nil
 }
 
 // GetUsageByChain retrieves usage data broken down by chain
 func (r *ClickHouseRepository) GetUsageByChain(ctx context.Context, orgID string, startDate, endDate time.Time) ([]models.ChainUsage, error) {
-	query := `
+	table, timeRange := usageRangeSource(startDate)
+	query := fmt.Sprintf(`
 		SELECT
 			chain_slug,
 			chain_type,
@@ -114,14 +135,13 @@ func (r *ClickHouseRepository) GetUsageByChain(ctx context.Context, orgID string
 			sumMerge(error_count) AS error_count,
 			(sumMerge(error_count) / nullIf(sumMerge(request_count), 0)) * 100 AS error_rate_pct,
 			toFloat64(arrayElement(quantilesMerge(0.50, 0.95, 0.99)(latency_ms_quantiles), 2)) AS avg_latency_p95
-		FROM usage_daily
+		FROM %s
 		WHERE organization_id = ?
-		  AND date >= ?
-		  AND date <= ?
+		  AND %s
 		GROUP BY chain_slug, chain_type
 		ORDER BY requests DESC
 		LIMIT 50
-	`
+	`, table, timeRange)
 
 	rows, err := r.conn.Query(ctx, query, orgID, startDate, endDate)
 	if err != nil {
@@ -199,22 +219,28 @@ func (r *ClickHouseRepository) GetUsageByMethod(ctx context.Context, orgID strin
 
 // GetDailyUsage retrieves daily aggregated usage
 func (r *ClickHouseRepository) GetDailyUsage(ctx context.Context, orgID string, startDate, endDate time.Time) ([]models.DailyUsage, error) {
-	query := `
+	// Days in other zones are rebuilt from hourly rows
+	table, timeRange := usageRangeSource(startDate)
+	dayExpr := "date"
+	if table == "usage_hourly" {
+		dayExpr = "toStartOfDay(hour, " + zoneOf(startDate) + ")"
+	}
+
+	query := fmt.Sprintf(`
 		SELECT
-			date,
+			%s AS day,
 			sumMerge(request_count) AS requests,
 			sumMerge(compute_units_used) AS compute_units,
 			sumMerge(total_response_size) / 1024.0 / 1024.0 / 1024.0 AS egress_gb,
 			sumMerge(error_count) AS error_count,
 			(sumMerge(error_count) / nullIf(sumMerge(request_count), 0)) * 100 AS error_rate_pct,
 			(sumMerge(status_2xx_count) / nullIf(sumMerge(request_count), 0)) * 100 AS success_rate
-		FROM usage_daily
+		FROM %s
 		WHERE organization_id = ?
-		  AND date >= ?
-		  AND date <= ?
-		GROUP BY date
-		ORDER BY date ASC
-	`
+		  AND %s
+		GROUP BY day
+		ORDER BY day ASC
+	`, dayExpr, table, timeRange)
 
 	rows, err := r.conn.Query(ctx, query, orgID, startDate, endDate)
 	if err != nil {
@@ -296,61 +322,3 @@ func (r *ClickHouseRepository) GetHourlyUsage(ctx context.Context, orgID string,
 
 	return hourlyUsage, rows.Err()
 }
-
-// GetAPIKeyUsage retrieves usage for a specific API key
-func (r *ClickHouseRepository) GetAPIKeyUsage(ctx context.Context, keyPrefix string, startDate, endDate time.Time) (*models.APIKeyUsage, error) {
-	// First get the organization_id from the key prefix
-	orgQuery := `
-		SELECT DISTINCT organization_id
-		FROM usage_daily
-		WHERE api_key_prefix = ?
-		LIMIT 1
-	`
-
-	var orgID string
-	if err := r.conn.QueryRow(ctx, orgQuery, keyPrefix).Scan(&orgID); err != nil {
-		return nil, fmt.Errorf("failed to get organization for key: %w", err)
-	}
-
-	// Get summary for this key
-	summaryQuery := `
-		SELECT
-			sumMerge(request_count) AS total_requests,
-			sumMerge(compute_units_used) AS total_compute_units,
-			sumMerge(total_response_size) / 1024.0 / 1024.0 / 1024.0 AS total_egress_gb,
-			sumMerge(error_count) AS error_count,
-			(sumMerge(error_count) / nullIf(sumMerge(request_count), 0)) * 100 AS error_rate_pct,
-			toFloat64(arrayElement(quantilesMerge(0.50, 0.95, 0.99)(latency_ms_quantiles), 2)) AS latency_p95
-		FROM usage_daily
-		WHERE api_key_prefix = ?
-		  AND date >= ?
-		  AND date <= ?
-	`
-
-	var summary models.SummaryMetrics
-	err := r.conn.QueryRow(ctx, summaryQuery, keyPrefix, startDate, endDate).Scan(
-		&summary.TotalRequests,
-		&summary.TotalComputeUnits,
-		&summary.TotalEgressGB,
-		&summary.ErrorCount,
-		&summary.ErrorRatePct,
-		&summary.AvgLatencyP95MS,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get key usage summary: %w", err)
-	}
-
-	if summary.TotalRequests > 0 {
-		summary.SuccessRate = 100.0 - summary.ErrorRatePct
-	}
-
-	return &models.APIKeyUsage{
-		KeyPrefix:      keyPrefix,
-		OrganizationID: orgID,
-		Period: models.Period{
-			Start: startDate,
-			End:   endDate,
-		},
-		Summary: summary,
-	}, nil
-}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// CostFilter narrows a cost report; empty fields match everything
+type CostFilter struct {
+	OrganizationID string
+	ChainSlug      string
+	PlanSlug       string
+}
+
+// GetChainCosts retrieves estimated usage cost per chain per UTC day from
+// chain_org_usage, ordered by day and then by cost
+func (r *ClickHouseRepository) GetChainCosts(ctx context.Context, startDate, endDate time.Time, filter CostFilter) (*models.ChainCosts, error) {
+	conditions := []string{"date >= toDate(?)", "date <= toDate(?)"}
+	args := []interface{}{startDate, endDate}
+	if filter.OrganizationID != "" {
+		conditions = append(conditions, "organization_id = ?")
+		args = append(args, filter.OrganizationID)
+	}
+	if filter.ChainSlug != "" {
+		conditions = append(conditions, "chain_slug = ?")
+		args = append(args, filter.ChainSlug)
+	}
+	if filter.PlanSlug != "" {
+		conditions = append(conditions, "plan_slug = ?")
+		args = append(args, filter.PlanSlug)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			date,
+			chain_slug,
+			sum(request_count) AS requests,
+			sum(compute_units_used) AS compute_units,
+			sum(total_response_size) / 1024.0 / 1024.0 / 1024.0 AS egress_gb,
+			sum(estimated_cost) AS estimated_cost
+		FROM chain_org_usage
+		WHERE %s
+		GROUP BY date, chain_slug
+		ORDER BY date, estimated_cost DESC, chain_slug
+	`, strings.Join(conditions, " AND "))
+
+	rows, err := r.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain costs: %w", err)
+	}
+	defer rows.Close()
+
+	costs := &models.ChainCosts{
+		Period:         models.NewPeriod(startDate, endDate),
+		OrganizationID: filter.OrganizationID,
+		ChainSlug:      filter.ChainSlug,
+		PlanSlug:       filter.PlanSlug,
+		Currency:       "USD",
+		ByChain:        make(map[string]float64),
+		Days:           []models.ChainCostDay{},
+	}
+	for rows.Next() {
+		var day models.ChainCostDay
+		if err := rows.Scan(&day.Date, &day.ChainSlug, &day.Requests, &day.ComputeUnits, &day.EgressGB, &day.EstimatedCost); err != nil {
+			return nil, fmt.Errorf("failed to scan chain cost row: %w", err)
+		}
+		costs.TotalCost += day.EstimatedCost
+		costs.ByChain[day.ChainSlug] += day.EstimatedCost
+		day.EstimatedCost = roundCost(day.EstimatedCost)
+		costs.Days = append(costs.Days, day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chain cost rows: %w", err)
+	}
+
+	// Totals are summed before rounding so they carry no rounding error
+	costs.TotalCost = roundCost(costs.TotalCost)
+	for chain, cost := range costs.ByChain {
+		costs.ByChain[chain] = roundCost(cost)
+	}
+
+	return costs, nil
+}
+
+// SyncChainPrices writes prices into ClickHouse chain_prices as one sync
+// and reloads chain_prices_dict, so usage ingested from then on is priced
+// with them
+func (r *ClickHouseRepository) SyncChainPrices(ctx context.Context, prices []models.ChainPrice, syncedAt time.Time) error {
+	batch, err := r.conn.PrepareBatch(ctx, `
+		INSERT INTO chain_prices (
+			chain_slug, plan_slug,
+			price_per_million_compute_units, price_per_million_requests, price_per_gb,
+			synced_at
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare chain prices batch: %w", err)
+	}
+
+	for _, p := range prices {
+		if err := batch.Append(
+			p.ChainSlug, p.PlanSlug,
+			p.PricePerMillionComputeUnits, p.PricePerMillionRequests, p.PricePerGB,
+			syncedAt,
+		); err != nil {
+			return fmt.Errorf("failed to append chain price: %w", err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send chain prices: %w", err)
+	}
+
+	// Without the reload the dictionary picks the prices up within its lifetime
+	if err := r.conn.Exec(ctx, "SYSTEM RELOAD DICTIONARY chain_prices_dict"); err != nil {
+		return fmt.Errorf("failed to reload chain prices dictionary: %w", err)
+	}
+
+	return nil
+}
+
+// GetChainPrices returns the chain_prices list prices
+func (r *PostgresRepository) GetChainPrices(ctx context.Context) ([]models.ChainPrice, error) {
+	query := `
+		SELECT
+			chain_slug,
+			plan_slug,
+			price_per_million_compute_units::float8,
+			price_per_million_requests::float8,
+			price_per_gb::float8
+		FROM chain_prices
+		ORDER BY chain_slug, plan_slug
+	`
+
+	rows, err := r.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []models.ChainPrice
+	for rows.Next() {
+		var price models.ChainPrice
+		if err := rows.Scan(
+			&price.ChainSlug,
+			&price.PlanSlug,
+			&price.PricePerMillionComputeUnits,
+			&price.PricePerMillionRequests,
+			&price.PricePerGB,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan chain price row: %w", err)
+		}
+		prices = append(prices, price)
+	}
+
+	return prices, rows.Err()
+}
+
+// roundCost keeps four decimals: a day of light usage costs fractions of a cent
+func roundCost(cost float64) float64 {
+	return math.Round(cost*1e4) / 1e4
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCursor is returned for pagination cursors that fail to decode
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// keysetCursor is the (timestamp_ms, request_id) position of the last row of
+// a page ordered newest first. request_id breaks ties between rows sharing a
+// millisecond.
+type keysetCursor struct {
+	timestampMs int64
+	requestID   string
+}
+
+func (c keysetCursor) encode() string {
+	raw := strconv.FormatInt(c.timestampMs, 10) + ":" + c.requestID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// condition selects rows strictly after the cursor
+func (c keysetCursor) condition() (string, []interface{}) {
+	return `(timestamp_ms < fromUnixTimestamp64Milli(?)
+			OR (timestamp_ms = fromUnixTimestamp64Milli(?) AND request_id < ?))`,
+		[]interface{}{c.timestampMs, c.timestampMs, c.requestID}
+}
+
+func decodeKeysetCursor(cursor string) (*keysetCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ts, requestID, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	ms, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &keysetCursor{timestampMs: ms, requestID: requestID}, nil
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// normalizedErrorMessage groups messages differing only in identifiers:
+// UUIDs, hex values (addresses, hashes) and numbers become placeholders
+const normalizedErrorMessage = `replaceRegexpAll(
+	replaceRegexpAll(
+		replaceRegexpAll(error_message, '[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}', '<uuid>'),
+		'0x[0-9a-fA-F]+', '<hex>'),
+	'[0-9]+', '<n>')`
+
+// errorConditions builds the shared WHERE clause of errors queries
+func errorConditions(orgID string, startDate, endDate time.Time, filter models.ErrorFilter) (string, []interface{}) {
+	conditions := []string{"organization_id = ?", "timestamp >= ?", "timestamp <= ?"}
+	args := []interface{}{orgID, startDate, endDate}
+
+	if filter.ChainSlug != "" {
+		conditions = append(conditions, "chain_slug = ?")
+		args = append(args, filter.ChainSlug)
+	}
+	if filter.RPCMethod != "" {
+		conditions = append(conditions, "rpc_method = ?")
+		args = append(args, filter.RPCMethod)
+	}
+	if filter.ErrorType != "" {
+		conditions = append(conditions, "error_type = ?")
+		args = append(args, filter.ErrorType)
+	}
+	if filter.StatusCode != 0 {
+		conditions = append(conditions, "status_code = ?")
+		args = append(args, filter.StatusCode)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// GetErrors returns the top error groups and one page of raw errors, newest
+// first. cursor is the NextCursor of the previous page, empty for the first.
+func (r *ClickHouseRepository) GetErrors(ctx context.Context, orgID string, startDate, endDate time.Time, filter models.ErrorFilter, topN, limit int, cursor string) (*models.ErrorsPage, error) {
+	var after *keysetCursor
+	if cursor != "" {
+		var err error
+		if after, err = decodeKeysetCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	where, args := errorConditions(orgID, startDate, endDate, filter)
+
+	page := &models.ErrorsPage{
+		OrganizationID: orgID,
+		Period:         models.NewPeriod(startDate, endDate),
+		TopErrors:      []models.ErrorGroup{},
+		Errors:         []models.ErrorEvent{},
+	}
+
+	countQuery := "SELECT count() FROM errors WHERE " + where
+	if err := r.conn.QueryRow(ctx, countQuery, args...).Scan(&page.TotalErrors); err != nil {
+		return nil, fmt.Errorf("failed to count errors: %w", err)
+	}
+
+	groupQuery := fmt.Sprintf(`
+		SELECT
+			%s AS pattern,
+			error_type,
+			count() AS occurrences,
+			min(timestamp) AS first_seen,
+			max(timestamp) AS last_seen,
+			argMax(error_message, timestamp_ms) AS sample_message,
+			argMax(request_id, timestamp_ms) AS sample_request_id,
+			groupUniqArray(10)(status_code) AS status_codes,
+			groupUniqArray(10)(chain_slug) AS chains,
+			groupUniqArray(10)(rpc_method) AS rpc_methods
+		FROM errors
+		WHERE %s
+		GROUP BY pattern, error_type
+		ORDER BY occurrences DESC
+		LIMIT ?
+	`, normalizedErrorMessage, where)
+
+	rows, err := r.conn.Query(ctx, groupQuery, append(args, topN)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get error groups: %w", err)
+	}
+	for rows.Next() {
+		var group models.ErrorGroup
+		if err := rows.Scan(
+			&group.Pattern,
+			&group.ErrorType,
+			&group.Count,
+			&group.FirstSeen,
+			&group.LastSeen,
+			&group.SampleMessage,
+			&group.SampleRequestID,
+			&group.StatusCodes,
+			&group.Chains,
+			&group.RPCMethods,
+		); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan error group row: %w", err)
+		}
+		page.TopErrors = append(page.TopErrors, group)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read error group rows: %w", err)
+	}
+
+	// Keyset pagination on (timestamp_ms, request_id), newest first
+	pageWhere, pageArgs := where, append([]interface{}{}, args...)
+	if after != nil {
+		condition, cursorArgs := after.condition()
+		pageWhere += " AND " + condition
+		pageArgs = append(pageArgs, cursorArgs...)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	pageQuery := `
+		SELECT
+			timestamp_ms,
+			request_id,
+			consumer_id,
+			chain_slug,
+			error_type,
+			error_message,
+			method,
+			path,
+			status_code,
+			rpc_method
+		FROM errors
+		WHERE ` + pageWhere + `
+		ORDER BY timestamp_ms DESC, request_id DESC
+		LIMIT ?
+	`
+
+	rows, err = r.conn.Query(ctx, pageQuery, append(pageArgs, limit+1)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get errors: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.ErrorEvent
+		if err := rows.Scan(
+			&event.Timestamp,
+			&event.RequestID,
+			&event.ConsumerID,
+			&event.ChainSlug,
+			&event.ErrorType,
+			&event.ErrorMessage,
+			&event.Method,
+			&event.Path,
+			&event.StatusCode,
+			&event.RPCMethod,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan error row: %w", err)
+		}
+		page.Errors = append(page.Errors, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read error rows: %w", err)
+	}
+
+	if len(page.Errors) > limit {
+		page.Errors = page.Errors[:limit]
+		last := page.Errors[limit-1]
+		page.NextCursor = keysetCursor{
+			timestampMs: last.Timestamp.UnixMilli(),
+			requestID:   last.RequestID,
+		}.encode()
+	}
+
+	return page, nil
+}
+
+// GetErrorsByRequestID returns every error recorded for a request between
+// startDate and endDate, including the stack and metadata omitted from
+// listings. errors is ordered by timestamp, so the range bounds the scan.
+func (r *ClickHouseRepository) GetErrorsByRequestID(ctx context.Context, orgID, requestID string, startDate, endDate time.Time) ([]models.ErrorEvent, error) {
+	query := `
+		SELECT
+			timestamp_ms,
+			request_id,
+			consumer_id,
+			chain_slug,
+			error_type,
+			error_message,
+			error_stack,
+			method,
+			path,
+			status_code,
+			rpc_method,
+			metadata
+		FROM errors
+		WHERE timestamp >= ?
+		  AND timestamp <= ?
+		  AND organization_id = ?
+		  AND request_id = ?
+		ORDER BY timestamp_ms ASC
+	`
+
+	rows, err := r.conn.Query(ctx, query, startDate, endDate, orgID, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get errors by request id: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.ErrorEvent
+	for rows.Next() {
+		var event models.ErrorEvent
+		if err := rows.Scan(
+			&event.Timestamp,
+			&event.RequestID,
+			&event.ConsumerID,
+			&event.ChainSlug,
+			&event.ErrorType,
+			&event.ErrorMessage,
+			&event.ErrorStack,
+			&event.Method,
+			&event.Path,
+			&event.StatusCode,
+			&event.RPCMethod,
+			&event.Metadata,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan error row: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,312 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// ExpensiveMethodRetention is the TTL of expensive_method_usage
+const ExpensiveMethodRetention = 7 * 24 * time.Hour
+
+// The ExpensiveMethodAbuse alert in monitoring/alerts/rate-limits.yml fires
+// when a consumer sends more than 10 req/s to a debug_/trace_ method over 5
+// minutes, for 10 minutes. Keep these in sync with the rule.
+const (
+	expensiveAlertMethods    = "^(debug|trace)_"
+	expensiveAlertRatePerSec = 10
+	expensiveAlertWindow     = 5 * time.Minute
+	expensiveAlertFor        = 10 * time.Minute
+)
+
+// GetExpensiveMethodReport audits an organization's calls recorded in
+// expensive_method_usage (methods of 10+ compute units)
+func (r *ClickHouseRepository) GetExpensiveMethodReport(ctx context.Context, orgID string, startDate, endDate time.Time, limit int) (*models.ExpensiveMethodReport, error) {
+	report := &models.ExpensiveMethodReport{
+		OrganizationID: orgID,
+		Period:         models.NewPeriod(startDate, endDate),
+		Usage:          []models.ExpensiveMethodUsage{},
+		SlowestCalls:   []models.ExpensiveCall{},
+		Alerts:         []models.ExpensiveMethodAlert{},
+	}
+
+	// Peak rate is the busiest alert-sized window of each group
+	usageQuery := `
+		SELECT
+			consumer_id,
+			chain_slug,
+			rpc_method,
+			sum(calls) AS total_calls,
+			sum(cu) AS compute_units,
+			sum(errors) AS error_count,
+			sum(latency_sum) / total_calls AS avg_latency_ms,
+			max(p95) AS p95_latency_ms,
+			max(max_latency) AS max_latency_ms,
+			max(calls) / ? AS peak_rate_per_sec,
+			min(first_seen) AS first_seen,
+			max(last_seen) AS last_seen
+		FROM (
+			SELECT
+				consumer_id,
+				chain_slug,
+				rpc_method,
+				toStartOfFiveMinutes(timestamp) AS window,
+				count() AS calls,
+				sum(compute_units) AS cu,
+				countIf(status_code >= 400) AS errors,
+				sum(latency_ms) AS latency_sum,
+				quantile(0.95)(latency_ms) AS p95,
+				max(latency_ms) AS max_latency,
+				min(timestamp) AS first_seen,
+				max(timestamp) AS last_seen
+			FROM expensive_method_usage
+			WHERE organization_id = ?
+			  AND timestamp >= ?
+			  AND timestamp <= ?
+			GROUP BY consumer_id, chain_slug, rpc_method, window
+		)
+		GROUP BY consumer_id, chain_slug, rpc_method
+		ORDER BY compute_units DESC
+		LIMIT ?
+	`
+
+	rows, err := r.conn.Query(ctx, usageQuery, expensiveAlertWindow.Seconds(), orgID, startDate, endDate, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expensive method usage: %w", err)
+	}
+	for rows.Next() {
+		var usage models.ExpensiveMethodUsage
+		if err := rows.Scan(
+			&usage.ConsumerID,
+			&usage.ChainSlug,
+			&usage.Method,
+			&usage.Calls,
+			&usage.ComputeUnits,
+			&usage.ErrorCount,
+			&usage.AvgLatencyMS,
+			&usage.P95LatencyMS,
+			&usage.MaxLatencyMS,
+			&usage.PeakRatePerSec,
+			&usage.FirstSeen,
+			&usage.LastSeen,
+		); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan expensive method usage row: %w", err)
+		}
+		report.Usage = append(report.Usage, usage)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read expensive method usage rows: %w", err)
+	}
+
+	totalsQuery := `
+		SELECT count(), sum(compute_units)
+		FROM expensive_method_usage
+		WHERE organization_id = ?
+		  AND timestamp >= ?
+		  AND timestamp <= ?
+	`
+	if err := r.conn.QueryRow(ctx, totalsQuery, orgID, startDate, endDate).Scan(&report.TotalCalls, &report.TotalComputeUnits); err != nil {
+		return nil, fmt.Errorf("failed to get expensive method totals: %w", err)
+	}
+
+	slowestQuery := `
+		SELECT
+			timestamp,
+			consumer_id,
+			chain_slug,
+			rpc_method,
+			compute_units,
+			latency_ms,
+			status_code
+		FROM expensive_method_usage
+		WHERE organization_id = ?
+		  AND timestamp >= ?
+		  AND timestamp <= ?
+		ORDER BY latency_ms DESC
+		LIMIT ?
+	`
+
+	rows, err = r.conn.Query(ctx, slowestQuery, orgID, startDate, endDate, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get slowest expensive calls: %w", err)
+	}
+	for rows.Next() {
+		var call models.ExpensiveCall
+		if err := rows.Scan(
+			&call.Timestamp,
+			&call.ConsumerID,
+			&call.ChainSlug,
+			&call.Method,
+			&call.ComputeUnits,
+			&call.LatencyMS,
+			&call.StatusCode,
+		); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan expensive call row: %w", err)
+		}
+		report.SlowestCalls = append(report.SlowestCalls, call)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read expensive call rows: %w", err)
+	}
+
+	alerts, err := r.getExpensiveMethodAlerts(ctx, orgID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	report.Alerts = alerts
+
+	return report, nil
+}
+
+// getExpensiveMethodAlerts finds the periods in which the ExpensiveMethodAbuse
+// condition held. Prometheus evaluates a sliding 5 minute rate; fixed 5 minute
+// windows approximate it, so borderline periods may differ from the alerts
+// actually sent.
+func (r *ClickHouseRepository) getExpensiveMethodAlerts(ctx context.Context, orgID string, startDate, endDate time.Time) ([]models.ExpensiveMethodAlert, error) {
+	query := `
+		SELECT
+			consumer_id,
+			rpc_method,
+			toStartOfFiveMinutes(timestamp) AS window,
+			count() AS calls
+		FROM expensive_method_usage
+		WHERE organization_id = ?
+		  AND timestamp >= ?
+		  AND timestamp <= ?
+		  AND match(rpc_method, ?)
+		GROUP BY consumer_id, rpc_method, window
+		HAVING calls > ?
+		ORDER BY consumer_id, rpc_method, window
+	`
+
+	threshold := uint64(expensiveAlertRatePerSec * expensiveAlertWindow.Seconds())
+	rows, err := r.conn.Query(ctx, query, orgID, startDate, endDate, expensiveAlertMethods, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expensive method alerts: %w", err)
+	}
+	defer rows.Close()
+
+	// Merge consecutive windows over the threshold into periods and keep
+	// those lasting as long as the alert's "for" clause
+	alerts := []models.ExpensiveMethodAlert{}
+	var current *models.ExpensiveMethodAlert
+	closeCurrent := func() {
+		if current != nil && current.End.Sub(current.Start) >= expensiveAlertFor {
+			alerts = append(alerts, *current)
+		}
+		current = nil
+	}
+	for rows.Next() {
+		var consumerID, method string
+		var window time.Time
+		var calls uint64
+		if err := rows.Scan(&consumerID, &method, &window, &calls); err != nil {
+			return nil, fmt.Errorf("failed to scan expensive method alert row: %w", err)
+		}
+
+		rate := float64(calls) / expensiveAlertWindow.Seconds()
+		if current != nil && current.ConsumerID == consumerID && current.Method == method && current.End.Equal(window) {
+			current.End = window.Add(expensiveAlertWindow)
+			current.Calls += calls
+			if rate > current.PeakRatePerSec {
+				current.PeakRatePerSec = rate
+			}
+			continue
+		}
+
+		closeCurrent()
+		current = &models.ExpensiveMethodAlert{
+			ConsumerID:     consumerID,
+			Method:         method,
+			Start:          window,
+			End:            window.Add(expensiveAlertWindow),
+			Calls:          calls,
+			PeakRatePerSec: rate,
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read expensive method alert rows: %w", err)
+	}
+	closeCurrent()
+
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Start.After(alerts[j].Start) })
+
+	return alerts, nil
+}
+
+// GetExpensiveMethodShares ranks organizations by the share of their traffic
+// going to expensive methods. Organizations with fewer than minCalls
+// expensive calls are left out.
+func (r *ClickHouseRepository) GetExpensiveMethodShares(ctx context.Context, startDate, endDate time.Time, minCalls uint64, limit int) ([]models.ExpensiveMethodShare, error) {
+	query := `
+		SELECT
+			e.organization_id,
+			e.calls,
+			e.cu,
+			t.requests,
+			t.cu,
+			if(t.requests > 0, e.calls / t.requests * 100, 0) AS call_share_pct,
+			if(t.cu > 0, e.cu / t.cu * 100, 0) AS compute_unit_share_pct,
+			e.consumers,
+			e.top_method
+		FROM (
+			SELECT
+				organization_id,
+				count() AS calls,
+				sum(compute_units) AS cu,
+				uniqExact(consumer_id) AS consumers,
+				topK(1)(rpc_method)[1] AS top_method
+			FROM expensive_method_usage
+			WHERE timestamp >= ?
+			  AND timestamp <= ?
+			GROUP BY organization_id
+			HAVING calls >= ?
+		) AS e
+		LEFT JOIN (
+			SELECT
+				organization_id,
+				sumMerge(request_count) AS requests,
+				sumMerge(compute_units_used) AS cu
+			FROM usage_hourly
+			WHERE hour >= toStartOfHour(?)
+			  AND hour <= ?
+			GROUP BY organization_id
+		) AS t ON t.organization_id = e.organization_id
+		ORDER BY call_share_pct DESC, e.calls DESC
+		LIMIT ?
+	`
+
+	rows, err := r.conn.Query(ctx, query, startDate, endDate, minCalls, startDate, endDate, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expensive method shares: %w", err)
+	}
+	defer rows.Close()
+
+	shares := []models.ExpensiveMethodShare{}
+	for rows.Next() {
+		var share models.ExpensiveMethodShare
+		if err := rows.Scan(
+			&share.OrganizationID,
+			&share.ExpensiveCalls,
+			&share.ExpensiveComputeUnits,
+			&share.TotalRequests,
+			&share.TotalComputeUnits,
+			&share.CallSharePct,
+			&share.ComputeUnitSharePct,
+			&share.Consumers,
+			&share.TopMethod,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan expensive method share row: %w", err)
+		}
+		shares = append(shares, share)
+	}
+
+	return shares, rows.Err()
+}
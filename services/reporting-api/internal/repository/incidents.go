@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// incidentColumns must stay in sync with scanIncident
+const incidentColumns = `
+	i.id,
+	c.slug,
+	COALESCE(c.display_name, c.name) as chain_name,
+	i.status,
+	i.title,
+	i.started_at,
+	i.resolved_at,
+	COALESCE(i.peak_error_rate_pct, 0)::float8 as peak_error_rate_pct,
+	COALESCE(i.peak_p95_latency_ms, 0)::float8 as peak_p95_latency_ms,
+	i.updated_at`
+
+func scanIncident(row pgx.Row) (models.Incident, error) {
+	var incident models.Incident
+	err := row.Scan(
+		&incident.ID,
+		&incident.ChainSlug,
+		&incident.ChainName,
+		&incident.Status,
+		&incident.Title,
+		&incident.StartedAt,
+		&incident.ResolvedAt,
+		&incident.PeakErrorRatePct,
+		&incident.PeakP95LatencyMS,
+		&incident.UpdatedAt,
+	)
+	return incident, err
+}
+
+func (r *PostgresRepository) queryIncidents(ctx context.Context, query string, args ...interface{}) ([]models.Incident, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incidents: %w", err)
+	}
+	defer rows.Close()
+
+	incidents := []models.Incident{}
+	for rows.Next() {
+		incident, err := scanIncident(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan incident row: %w", err)
+		}
+		incidents = append(incidents, incident)
+	}
+
+	return incidents, rows.Err()
+}
+
+// GetOpenIncidents returns the ongoing incidents
+func (r *PostgresRepository) GetOpenIncidents(ctx context.Context) ([]models.Incident, error) {
+	query := `
+		SELECT ` + incidentColumns + `
+		FROM status_incidents i
+		JOIN chains c ON c.id = i.chain_id
+		WHERE i.resolved_at IS NULL
+		ORDER BY i.started_at DESC
+	`
+	return r.queryIncidents(ctx, query)
+}
+
+// ListIncidents returns incidents started or still open since the given
+// time, newest first
+func (r *PostgresRepository) ListIncidents(ctx context.Context, since time.Time, limit int) ([]models.Incident, error) {
+	query := `
+		SELECT ` + incidentColumns + `
+		FROM status_incidents i
+		JOIN chains c ON c.id = i.chain_id
+		WHERE i.started_at >= $1 OR i.resolved_at IS NULL
+		ORDER BY i.started_at DESC
+		LIMIT $2
+	`
+	return r.queryIncidents(ctx, query, since, limit)
+}
+
+// OpenIncident records a new incident for a chain. It returns nil without an
+// error when the chain already has an open incident.
+func (r *PostgresRepository) OpenIncident(ctx context.Context, chainSlug, status, title string, startedAt time.Time, errorRatePct, p95LatencyMS float64) (*models.Incident, error) {
+	query := `
+		WITH inserted AS (
+			INSERT INTO status_incidents (chain_id, status, title, started_at, peak_error_rate_pct, peak_p95_latency_ms)
+			SELECT id, $2::varchar, $3::varchar, $4::timestamptz, $5::numeric, $6::numeric
+			FROM chains
+			WHERE slug = $1
+			ON CONFLICT (chain_id) WHERE resolved_at IS NULL DO NOTHING
+			RETURNING *
+		), transition AS (
+			INSERT INTO status_incident_transitions (incident_id, status, changed_at)
+			SELECT id, status, started_at
+			FROM inserted
+		)
+		SELECT ` + incidentColumns + `
+		FROM inserted i
+		JOIN chains c ON c.id = i.chain_id
+	`
+
+	incident, err := scanIncident(r.pool.QueryRow(ctx, query, chainSlug, status, title, startedAt, errorRatePct, p95LatencyMS))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open incident: %w", err)
+	}
+
+	return &incident, nil
+}
+
+// UpdateIncident records new observations of an open incident. The status
+// only escalates (degraded to outage) and peaks only grow.
+func (r *PostgresRepository) UpdateIncident(ctx context.Context, incidentID, status, title string, errorRatePct, p95LatencyMS float64) error {
+	query := `
+		UPDATE status_incidents
+		SET status = CASE WHEN status = 'outage' THEN status ELSE $2 END,
+		    title = CASE WHEN status = 'outage' AND $2 <> 'outage' THEN title ELSE $3 END,
+		    peak_error_rate_pct = GREATEST(peak_error_rate_pct, $4),
+		    peak_p95_latency_ms = GREATEST(peak_p95_latency_ms, $5)
+		WHERE id = $1
+		  AND resolved_at IS NULL
+	`
+
+	if _, err := r.pool.Exec(ctx, query, incidentID, status, title, errorRatePct, p95LatencyMS); err != nil {
+		return fmt.Errorf("failed to update incident: %w", err)
+	}
+
+	return nil
+}
+
+// RecordIncidentState records the state observed for an open incident's
+// chain (degraded, outage or operational) when it differs from the last
+// recorded one. Unlike the incident status it may also improve.
+func (r *PostgresRepository) RecordIncidentState(ctx context.Context, incidentID, state string, observedAt time.Time) error {
+	query := `
+		INSERT INTO status_incident_transitions (incident_id, status, changed_at)
+		SELECT i.id, $2::varchar, $3::timestamptz
+		FROM status_incidents i
+		WHERE i.id = $1
+		  AND i.resolved_at IS NULL
+		  AND $2::varchar IS DISTINCT FROM (
+			SELECT t.status
+			FROM status_incident_transitions t
+			WHERE t.incident_id = i.id
+			ORDER BY t.changed_at DESC
+			LIMIT 1
+		  )
+	`
+
+	if _, err := r.pool.Exec(ctx, query, incidentID, state, observedAt); err != nil {
+		return fmt.Errorf("failed to record incident state: %w", err)
+	}
+
+	return nil
+}
+
+// ResolveIncident closes an open incident
+func (r *PostgresRepository) ResolveIncident(ctx context.Context, incidentID string, resolvedAt time.Time) error {
+	query := `
+		UPDATE status_incidents
+		SET resolved_at = GREATEST($2, started_at)
+		WHERE id = $1
+		  AND resolved_at IS NULL
+	`
+
+	if _, err := r.pool.Exec(ctx, query, incidentID, resolvedAt); err != nil {
+		return fmt.Errorf("failed to resolve incident: %w", err)
+	}
+
+	return nil
+}
+
+// GetOutageSeconds returns the outage time of each chain since the given
+// time, keyed by chain slug. Only the intervals an incident spent in outage
+// count; each lasts until the next transition or the incident's resolution.
+func (r *PostgresRepository) GetOutageSeconds(ctx context.Context, since time.Time) (map[string]float64, error) {
+	query := `
+		WITH intervals AS (
+			SELECT
+				i.chain_id,
+				t.status,
+				t.changed_at AS started_at,
+				COALESCE(
+					LEAD(t.changed_at) OVER (PARTITION BY t.incident_id ORDER BY t.changed_at),
+					i.resolved_at,
+					NOW()
+				) AS ended_at
+			FROM status_incident_transitions t
+			JOIN status_incidents i ON i.id = t.incident_id
+			WHERE i.status = 'outage'
+			  AND COALESCE(i.resolved_at, NOW()) > $1
+		)
+		SELECT
+			c.slug,
+			SUM(EXTRACT(EPOCH FROM (
+				o.ended_at - GREATEST(o.started_at, $1)
+			)))::float8 as outage_seconds
+		FROM intervals o
+		JOIN chains c ON c.id = o.chain_id
+		WHERE o.status = 'outage'
+		  AND o.ended_at > $1
+		GROUP BY c.slug
+	`
+
+	rows, err := r.pool.Query(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outage durations: %w", err)
+	}
+	defer rows.Close()
+
+	outages := make(map[string]float64)
+	for rows.Next() {
+		var slug string
+		var seconds float64
+		if err := rows.Scan(&slug, &seconds); err != nil {
+			return nil, fmt.Errorf("failed to scan outage row: %w", err)
+		}
+		outages[slug] = seconds
+	}
+
+	return outages, rows.Err()
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// GetAPIKeyUsage retrieves usage for one API key of an organization
+func (r *ClickHouseRepository) GetAPIKeyUsage(ctx context.Context, orgID, keyPrefix string, startDate, endDate time.Time) (*models.APIKeyUsage, error) {
+	usage, err := r.getKeyUsage(ctx, orgID, []string{keyPrefix}, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	if keyUsage, ok := usage[keyPrefix]; ok {
+		return keyUsage, nil
+	}
+
+	return &models.APIKeyUsage{
+		KeyPrefix:      keyPrefix,
+		OrganizationID: orgID,
+		Period:         models.NewPeriod(startDate, endDate),
+		ByChain:        []models.ChainUsage{},
+	}, nil
+}
+
+// GetOrganizationKeyUsage retrieves usage per API key prefix of an
+// organization. Keys that were never used are absent from the map.
+func (r *ClickHouseRepository) GetOrganizationKeyUsage(ctx context.Context, orgID string, startDate, endDate time.Time) (map[string]*models.APIKeyUsage, error) {
+	return r.getKeyUsage(ctx, orgID, nil, startDate, endDate)
+}
+
+// getKeyUsage aggregates usage by key prefix, restricted to keyPrefixes
+// unless empty. LastUsed is exact while requests_raw keeps the key's last
+// request and has day precision beyond.
+func (r *ClickHouseRepository) getKeyUsage(ctx context.Context, orgID string, keyPrefixes []string, startDate, endDate time.Time) (map[string]*models.APIKeyUsage, error) {
+	keyCondition := ""
+	keyArgs := []interface{}{}
+	if len(keyPrefixes) > 0 {
+		keyCondition = "AND has(?, api_key_prefix)"
+		keyArgs = append(keyArgs, keyPrefixes)
+	}
+	table, timeRange := usageRangeSource(startDate)
+	args := append([]interface{}{orgID, startDate, endDate}, keyArgs...)
+
+	summaryQuery := fmt.Sprintf(`
+		SELECT
+			api_key_prefix,
+			sumMerge(request_count) AS total_requests,
+			sumMerge(compute_units_used) AS total_compute_units,
+			sumMerge(total_response_size) / 1024.0 / 1024.0 / 1024.0 AS total_egress_gb,
+			sumMerge(error_count) AS error_count,
+			ifNull((sumMerge(error_count) / nullIf(sumMerge(request_count), 0)) * 100, 0) AS error_rate_pct,
+			ifNotFinite(toFloat64(arrayElement(quantilesMerge(0.50, 0.95, 0.99)(latency_ms_quantiles), 2)), 0) AS latency_p95
+		FROM %s
+		WHERE organization_id = ?
+		  AND %s
+		  %s
+		GROUP BY api_key_prefix
+	`, table, timeRange, keyCondition)
+
+	rows, err := r.conn.Query(ctx, summaryQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key usage summary: %w", err)
+	}
+	usage := make(map[string]*models.APIKeyUsage)
+	for rows.Next() {
+		keyUsage := &models.APIKeyUsage{
+			OrganizationID: orgID,
+			Period:         models.NewPeriod(startDate, endDate),
+			ByChain:        []models.ChainUsage{},
+		}
+		summary := &keyUsage.Summary
+		if err := rows.Scan(
+			&keyUsage.KeyPrefix,
+			&summary.TotalRequests,
+			&summary.TotalComputeUnits,
+			&summary.TotalEgressGB,
+			&summary.ErrorCount,
+			&summary.ErrorRatePct,
+			&summary.AvgLatencyP95MS,
+		); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan key usage row: %w", err)
+		}
+		if summary.TotalRequests > 0 {
+			summary.SuccessRate = 100.0 - summary.ErrorRatePct
+		}
+		usage[keyUsage.KeyPrefix] = keyUsage
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key usage rows: %w", err)
+	}
+
+	chainQuery := fmt.Sprintf(`
+		SELECT
+			api_key_prefix,
+			chain_slug,
+			chain_type,
+			sumMerge(request_count) AS requests,
+			sumMerge(compute_units_used) AS compute_units,
+			sumMerge(total_response_size) / 1024.0 / 1024.0 / 1024.0 AS egress_gb,
+			sumMerge(error_count) AS error_count,
+			(sumMerge(error_count) / nullIf(sumMerge(request_count), 0)) * 100 AS error_rate_pct,
+			toFloat64(arrayElement(quantilesMerge(0.50, 0.95, 0.99)(latency_ms_quantiles), 2)) AS avg_latency_p95
+		FROM %s
+		WHERE organization_id = ?
+		  AND %s
+		  %s
+		GROUP BY api_key_prefix, chain_slug, chain_type
+		ORDER BY requests DESC
+	`, table, timeRange, keyCondition)
+
+	rows, err = r.conn.Query(ctx, chainQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key usage by chain: %w", err)
+	}
+	for rows.Next() {
+		var keyPrefix string
+		var chain models.ChainUsage
+		if err := rows.Scan(
+			&keyPrefix,
+			&chain.ChainSlug,
+			&chain.ChainType,
+			&chain.Requests,
+			&chain.ComputeUnits,
+			&chain.EgressGB,
+			&chain.ErrorCount,
+			&chain.ErrorRatePct,
+			&chain.AvgLatencyP95,
+		); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan key chain usage row: %w", err)
+		}
+		if keyUsage, ok := usage[keyPrefix]; ok {
+			keyUsage.ByChain = append(keyUsage.ByChain, chain)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key chain usage rows: %w", err)
+	}
+
+	// Last use is independent of the period, so keys idle in it get one too
+	lastUsedQuery := fmt.Sprintf(`
+		SELECT api_key_prefix, max(last_used)
+		FROM (
+			SELECT api_key_prefix, toDateTime(max(timestamp), 'UTC') AS last_used
+			FROM requests_raw
+			WHERE organization_id = ? %[1]s
+			GROUP BY api_key_prefix
+			UNION ALL
+			SELECT api_key_prefix, toDateTime(max(date), 'UTC') AS last_used
+			FROM usage_daily
+			WHERE organization_id = ? %[1]s
+			GROUP BY api_key_prefix
+		)
+		GROUP BY api_key_prefix
+	`, keyCondition)
+	lastUsedArgs := append(append([]interface{}{orgID}, keyArgs...), orgID)
+	lastUsedArgs = append(lastUsedArgs, keyArgs...)
+
+	rows, err = r.conn.Query(ctx, lastUsedQuery, lastUsedArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key last use: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var keyPrefix string
+		var lastUsed time.Time
+		if err := rows.Scan(&keyPrefix, &lastUsed); err != nil {
+			return nil, fmt.Errorf("failed to scan key last use row: %w", err)
+		}
+		keyUsage, ok := usage[keyPrefix]
+		if !ok {
+			keyUsage = &models.APIKeyUsage{
+				KeyPrefix:      keyPrefix,
+				OrganizationID: orgID,
+				Period:         models.NewPeriod(startDate, endDate),
+				ByChain:        []models.ChainUsage{},
+			}
+			usage[keyPrefix] = keyUsage
+		}
+		keyUsage.LastUsed = &lastUsed
+	}
+
+	return usage, rows.Err()
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// methodSortColumns maps the sort options of GetMethodAnalytics to the
+// aggregates they order by
+var methodSortColumns = map[string]string{
+	"requests":      "requests",
+	"compute_units": "compute_units",
+	"error_rate":    "error_count / requests",
+	"latency":       "p95_latency_ms",
+	"consumers":     "unique_consumers",
+}
+
+// ValidMethodSort reports whether sortBy is accepted by GetMethodAnalytics
+func ValidMethodSort(sortBy string) bool {
+	_, ok := methodSortColumns[sortBy]
+	return ok
+}
+
+// GetMethodAnalytics ranks RPC methods across all organizations from
+// chain_method_daily. Empty chainSlug covers every chain; a non-empty methods
+// list restricts the ranking to those method names.
+func (r *ClickHouseRepository) GetMethodAnalytics(ctx context.Context, startDate, endDate time.Time, chainSlug string, methods []string, sortBy string, limit int) (*models.MethodAnalytics, error) {
+	orderBy, ok := methodSortColumns[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid method sort: %s", sortBy)
+	}
+
+	conditions := []string{"date >= toDate(?)", "date <= toDate(?)", "rpc_method != ''"}
+	args := []interface{}{startDate, endDate}
+	if chainSlug != "" {
+		conditions = append(conditions, "chain_slug = ?")
+		args = append(args, chainSlug)
+	}
+	where := strings.Join(conditions, " AND ")
+
+	analytics := &models.MethodAnalytics{
+		Period:    models.NewPeriod(startDate, endDate),
+		ChainSlug: chainSlug,
+		SortBy:    sortBy,
+		Methods:   []models.MethodStats{},
+	}
+
+	// Totals cover every method so shares stay comparable across filters
+	totalsQuery := "SELECT sum(request_count), sum(total_compute_units) FROM chain_method_daily WHERE " + where
+	if err := r.conn.QueryRow(ctx, totalsQuery, args...).Scan(&analytics.TotalRequests, &analytics.TotalComputeUnits); err != nil {
+		return nil, fmt.Errorf("failed to get method totals: %w", err)
+	}
+
+	if len(methods) > 0 {
+		where += " AND has(?, rpc_method)"
+		args = append(args, methods)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			chain_slug,
+			rpc_method,
+			sum(request_count) AS requests,
+			uniqMerge(unique_consumers) AS unique_consumers,
+			uniqMerge(unique_organizations) AS unique_organizations,
+			sum(total_compute_units) AS compute_units,
+			sum(error_count) AS error_count,
+			avgMerge(latency_ms_avg) AS avg_latency_ms,
+			quantileMerge(0.95)(latency_ms_p95) AS p95_latency_ms
+		FROM chain_method_daily
+		WHERE %s
+		GROUP BY chain_slug, rpc_method
+		ORDER BY %s DESC, requests DESC
+		LIMIT ?
+	`, where, orderBy)
+
+	rows, err := r.conn.Query(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get method analytics: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stats models.MethodStats
+		if err := rows.Scan(
+			&stats.ChainSlug,
+			&stats.Method,
+			&stats.Requests,
+			&stats.UniqueConsumers,
+			&stats.UniqueOrganizations,
+			&stats.ComputeUnits,
+			&stats.ErrorCount,
+			&stats.AvgLatencyMS,
+			&stats.P95LatencyMS,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan method analytics row: %w", err)
+		}
+		if stats.Requests > 0 {
+			stats.AvgComputeUnits = float64(stats.ComputeUnits) / float64(stats.Requests)
+			stats.ErrorRatePct = float64(stats.ErrorCount) / float64(stats.Requests) * 100
+		}
+		if analytics.TotalComputeUnits > 0 {
+			stats.ComputeUnitsSharePct = float64(stats.ComputeUnits) / float64(analytics.TotalComputeUnits) * 100
+		}
+		analytics.Methods = append(analytics.Methods, stats)
+	}
+
+	return analytics, rows.Err()
+}
+
+// GetMethodTimeseries returns an organization's usage of one RPC method from
+// usage_hourly. interval must be "hour" or "day"; empty chainSlug covers
+// every chain.
+func (r *ClickHouseRepository) GetMethodTimeseries(ctx context.Context, orgID, method, chainSlug string, startDate, endDate time.Time, interval string) ([]models.MethodBucket, error) {
+	bucketExpr := "hour"
+	if interval == "day" {
+		bucketExpr = "toStartOfDay(hour, " + zoneOf(startDate) + ")"
+	}
+
+	conditions := []string{"organization_id = ?", "rpc_method = ?", "hour >= ?", "hour <= ?"}
+	args := []interface{}{orgID, method, startDate, endDate}
+	if chainSlug != "" {
+		conditions = append(conditions, "chain_slug = ?")
+		args = append(args, chainSlug)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			%s AS bucket,
+			sumMerge(request_count) AS requests,
+			sumMerge(compute_units_used) AS compute_units,
+			sumMerge(error_count) AS error_count,
+			avgMerge(latency_ms_avg) AS avg_latency_ms,
+			toFloat64(arrayElement(quantilesMerge(0.50, 0.95, 0.99)(latency_ms_quantiles), 2)) AS p95_latency_ms
+		FROM usage_hourly
+		WHERE %s
+		GROUP BY bucket
+		ORDER BY bucket ASC
+	`, bucketExpr, strings.Join(conditions, " AND "))
+
+	rows, err := r.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get method timeseries: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []models.MethodBucket{}
+	for rows.Next() {
+		var bucket models.MethodBucket
+		if err := rows.Scan(
+			&bucket.Timestamp,
+			&bucket.Requests,
+			&bucket.ComputeUnits,
+			&bucket.ErrorCount,
+			&bucket.AvgLatencyMS,
+			&bucket.P95LatencyMS,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan method timeseries row: %w", err)
+		}
+		if bucket.Requests > 0 {
+			bucket.ErrorRatePct = float64(bucket.ErrorCount) / float64(bucket.Requests) * 100
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
+
+// GetMethodComputeUnits returns the method_compute_units catalog keyed by
+// chain type, then method name
+func (r *PostgresRepository) GetMethodComputeUnits(ctx context.Context) (map[string]map[string]models.MethodComputeUnits, error) {
+	query := `
+		SELECT
+			chain_type,
+			method_name,
+			compute_units,
+			COALESCE(is_expensive, false) as is_expensive,
+			COALESCE(requires_archive, false) as requires_archive,
+			COALESCE(requires_trace, false) as requires_trace,
+			COALESCE(description, '') as description
+		FROM method_compute_units
+	`
+
+	rows, err := r.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get method compute units: %w", err)
+	}
+	defer rows.Close()
+
+	units := make(map[string]map[string]models.MethodComputeUnits)
+	for rows.Next() {
+		var m models.MethodComputeUnits
+		if err := rows.Scan(
+			&m.ChainType,
+			&m.Method,
+			&m.ComputeUnits,
+			&m.IsExpensive,
+			&m.RequiresArchive,
+			&m.RequiresTrace,
+			&m.Description,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan method compute units row: %w", err)
+		}
+		if units[m.ChainType] == nil {
+			units[m.ChainType] = make(map[string]models.MethodComputeUnits)
+		}
+		units[m.ChainType][m.Method] = m
+	}
+
+	return units, rows.Err()
+}
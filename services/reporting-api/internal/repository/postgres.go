@@ -2,10 +2,15 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/url"
 
 	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
 	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -118,25 +123,297 @@ func (r *PostgresRepository) GetOrganizationBySlug(ctx context.Context, slug str
 	return &org, nil
 }
 
-// ValidateAPIKey checks if an API key is valid (simple auth for Phase 6)
-func (r *PostgresRepository) ValidateAPIKey(ctx context.Context, keyPrefix string) (bool, string, error) {
+// ResolveAPIKey finds the active API key whose key_hash is the SHA-256 hash
+// of the presented key, through the unique idx_api_keys_key_hash index.
+// Returns nil without an error when no key matches. Keys without a stored
+// key_hash never match, since their prefix alone is not a secret.
+func (r *PostgresRepository) ResolveAPIKey(ctx context.Context, apiKey string) (*models.APIKey, error) {
 	query := `
-		SELECT organization_id
+		SELECT
+			id,
+			organization_id,
+			consumer_id,
+			unkey_key_id,
+			key_prefix,
+			COALESCE(name, '') as name,
+			status
 		FROM api_keys
-		WHERE key_prefix = $1
+		WHERE key_hash = $1
 		  AND status = 'active'
 		  AND (expires_at IS NULL OR expires_at > NOW())
+	`
+
+	var key models.APIKey
+	err := r.pool.QueryRow(ctx, query, HashAPIKey(apiKey)).Scan(
+		&key.ID,
+		&key.OrganizationID,
+		&key.ConsumerID,
+		&key.UnkeyKeyID,
+		&key.KeyPrefix,
+		&key.Name,
+		&key.Status,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve api key: %w", err)
+	}
+
+	return &key, nil
+}
+
+// HashAPIKey returns the hex SHA-256 stored in api_keys.key_hash for a key
+func HashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// ErrAmbiguousKeyPrefix is returned when keys of several organizations
+// share a key prefix
+var ErrAmbiguousKeyPrefix = errors.New("key prefix belongs to several organizations")
+
+// GetAPIKeyOrganization returns the organization that owns a key prefix.
+// Prefixes are not unique: it returns ErrAmbiguousKeyPrefix when keys of
+// several organizations share the prefix.
+func (r *PostgresRepository) GetAPIKeyOrganization(ctx context.Context, keyPrefix string) (string, error) {
+	query := `
+		SELECT DISTINCT organization_id
+		FROM api_keys
+		WHERE key_prefix = $1
+		LIMIT 2
+	`
+
+	rows, err := r.pool.Query(ctx, query, keyPrefix)
+	if err != nil {
+		return "", fmt.Errorf("failed to get organization for key prefix: %w", err)
+	}
+	defer rows.Close()
+
+	var owners []string
+	for rows.Next() {
+		var orgID string
+		if err := rows.Scan(&orgID); err != nil {
+			return "", fmt.Errorf("failed to scan key prefix organization: %w", err)
+		}
+		owners = append(owners, orgID)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to get organization for key prefix: %w", err)
+	}
+
+	switch len(owners) {
+	case 0:
+		return "", fmt.Errorf("failed to get organization for key prefix: %w", pgx.ErrNoRows)
+	case 1:
+		return owners[0], nil
+	default:
+		return "", ErrAmbiguousKeyPrefix
+	}
+}
+
+// OrganizationHasAPIKey reports whether orgID owns a key with keyPrefix
+func (r *PostgresRepository) OrganizationHasAPIKey(ctx context.Context, orgID, keyPrefix string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM api_keys
+			WHERE organization_id = $1
+			  AND key_prefix = $2
+		)
+	`
+
+	var exists bool
+	if err := r.pool.QueryRow(ctx, query, orgID, keyPrefix).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check api key organization: %w", err)
+	}
+
+	return exists, nil
+}
+
+// ListAPIKeys retrieves all API keys of an organization, including revoked
+// ones. Active keys past expires_at are reported as expired.
+func (r *PostgresRepository) ListAPIKeys(ctx context.Context, orgID string) ([]models.APIKey, error) {
+	query := `
+		SELECT
+			id,
+			organization_id,
+			consumer_id,
+			unkey_key_id,
+			key_prefix,
+			COALESCE(name, '') as name,
+			COALESCE(description, '') as description,
+			CASE WHEN status = 'active' AND expires_at <= NOW() THEN 'expired' ELSE status END as status,
+			last_used_at,
+			COALESCE(usage_count, 0) as usage_count,
+			expires_at,
+			COALESCE(allowed_chains, '["*"]') as allowed_chains,
+			created_at,
+			revoked_at
+		FROM api_keys
+		WHERE organization_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.pool.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var key models.APIKey
+		if err := rows.Scan(
+			&key.ID,
+			&key.OrganizationID,
+			&key.ConsumerID,
+			&key.UnkeyKeyID,
+			&key.KeyPrefix,
+			&key.Name,
+			&key.Description,
+			&key.Status,
+			&key.LastUsedAt,
+			&key.UsageCount,
+			&key.ExpiresAt,
+			&key.AllowedChains,
+			&key.CreatedAt,
+			&key.RevokedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan api key row: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// GetUser retrieves a user by id. Returns nil without an error when not found.
+func (r *PostgresRepository) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	query := `
+		SELECT
+			id,
+			organization_id,
+			email,
+			COALESCE(name, '') as name,
+			role,
+			status,
+			created_at
+		FROM users
+		WHERE id::text = $1
 		LIMIT 1
 	`
 
-	var orgID string
-	err := r.pool.QueryRow(ctx, query, keyPrefix).Scan(&orgID)
+	var user models.User
+	err := r.pool.QueryRow(ctx, query, userID).Scan(
+		&user.ID,
+		&user.OrganizationID,
+		&user.Email,
+		&user.Name,
+		&user.Role,
+		&user.Status,
+		&user.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
-		// Key not found or expired
-		return false, "", nil
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	return true, orgID, nil
+	return &user, nil
+}
+
+// GetOrganizationPlan retrieves the plan of an organization's active subscription.
+// Returns nil without an error when the organization has no active subscription.
+func (r *PostgresRepository) GetOrganizationPlan(ctx context.Context, orgID string) (*models.Plan, error) {
+	query := `
+		SELECT
+			p.id,
+			p.name,
+			p.slug,
+			p.rate_limit_per_minute,
+			COALESCE(p.rate_limit_per_hour, 0) as rate_limit_per_hour,
+			COALESCE(p.rate_limit_per_day, 0) as rate_limit_per_day,
+			COALESCE(p.burst_multiplier, 1.0)::float8 as burst_multiplier,
+			COALESCE(p.price_monthly, 0)::float8 as price_monthly,
+			COALESCE(p.price_yearly, 0)::float8 as price_yearly,
+			COALESCE(p.currency, 'USD') as currency,
+			p.is_active
+		FROM subscriptions s
+		JOIN plans p ON s.plan_id = p.id
+		WHERE s.organization_id = $1
+		  AND s.status = 'active'
+		ORDER BY s.current_period_end DESC NULLS LAST
+		LIMIT 1
+	`
+
+	var plan models.Plan
+	err := r.pool.QueryRow(ctx, query, orgID).Scan(
+		&plan.ID,
+		&plan.Name,
+		&plan.Slug,
+		&plan.RateLimitPerMinute,
+		&plan.RateLimitPerHour,
+		&plan.RateLimitPerDay,
+		&plan.BurstMultiplier,
+		&plan.PriceMonthly,
+		&plan.PriceYearly,
+		&plan.Currency,
+		&plan.IsActive,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization plan: %w", err)
+	}
+
+	return &plan, nil
+}
+
+// GetPlanChainLimits retrieves a plan's per-chain rate limits
+func (r *PostgresRepository) GetPlanChainLimits(ctx context.Context, planID string) ([]models.PlanChainLimit, error) {
+	query := `
+		SELECT
+			c.slug,
+			COALESCE(pcl.rate_limit_per_second, 0),
+			COALESCE(pcl.rate_limit_per_minute, 0),
+			COALESCE(pcl.rate_limit_per_hour, 0),
+			COALESCE(pcl.rate_limit_per_day, 0),
+			COALESCE(pcl.compute_units_per_second, 0),
+			COALESCE(pcl.compute_units_per_day, 0),
+			COALESCE(pcl.is_override, false)
+		FROM plan_chain_limits pcl
+		JOIN chains c ON c.id = pcl.chain_id
+		WHERE pcl.plan_id = $1
+	`
+
+	rows, err := r.pool.Query(ctx, query, planID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan chain limits: %w", err)
+	}
+	defer rows.Close()
+
+	var limits []models.PlanChainLimit
+	for rows.Next() {
+		var limit models.PlanChainLimit
+		if err := rows.Scan(
+			&limit.ChainSlug,
+			&limit.RateLimitPerSecond,
+			&limit.RateLimitPerMinute,
+			&limit.RateLimitPerHour,
+			&limit.RateLimitPerDay,
+			&limit.ComputeUnitsPerSecond,
+			&limit.ComputeUnitsPerDay,
+			&limit.IsOverride,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan plan chain limit row: %w", err)
+		}
+		limits = append(limits, limit)
+	}
+
+	return limits, rows.Err()
 }
 
 // ListOrganizations retrieves all active organizations (admin only)
@@ -185,3 +462,157 @@ func (r *PostgresRepository) ListOrganizations(ctx context.Context, limit, offse
 
 	return orgs, rows.Err()
 }
+
+// chainColumns must stay in sync with scanChain
+const chainColumns = `
+	c.id,
+	c.name,
+	c.slug,
+	c.chain_type,
+	COALESCE(c.chain_id, '') as chain_id,
+	COALESCE(c.display_name, c.name) as display_name,
+	COALESCE(c.block_time_seconds, 0) as block_time_seconds,
+	c.native_currency,
+	COALESCE(c.supports_websocket, false) as supports_websocket,
+	COALESCE(c.supports_archive, false) as supports_archive,
+	COALESCE(c.supports_trace, false) as supports_trace,
+	COALESCE(c.is_active, false) as is_active,
+	COALESCE(c.is_testnet, false) as is_testnet,
+	COUNT(e.id) FILTER (WHERE e.is_active) as endpoint_count,
+	COUNT(e.id) FILTER (WHERE e.is_active AND e.is_healthy) as healthy_endpoint_count`
+
+func scanChain(row pgx.Row) (models.Chain, error) {
+	var chain models.Chain
+	err := row.Scan(
+		&chain.ID,
+		&chain.Name,
+		&chain.Slug,
+		&chain.ChainType,
+		&chain.ChainID,
+		&chain.DisplayName,
+		&chain.BlockTimeSeconds,
+		&chain.NativeCurrency,
+		&chain.SupportsWebsocket,
+		&chain.SupportsArchive,
+		&chain.SupportsTrace,
+		&chain.IsActive,
+		&chain.IsTestnet,
+		&chain.EndpointCount,
+		&chain.HealthyEndpointCount,
+	)
+	return chain, err
+}
+
+// ListChains returns the active chains with their endpoint counts
+func (r *PostgresRepository) ListChains(ctx context.Context, includeTestnets bool) ([]models.Chain, error) {
+	query := `
+		SELECT ` + chainColumns + `
+		FROM chains c
+		LEFT JOIN rpc_endpoints e ON c.id = e.chain_id
+		WHERE c.is_active = true
+		  AND ($1 OR c.is_testnet = false)
+		GROUP BY c.id
+		ORDER BY c.is_testnet, c.name
+	`
+
+	rows, err := r.pool.Query(ctx, query, includeTestnets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chains: %w", err)
+	}
+	defer rows.Close()
+
+	var chains []models.Chain
+	for rows.Next() {
+		chain, err := scanChain(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan chain row: %w", err)
+		}
+		chains = append(chains, chain)
+	}
+
+	return chains, rows.Err()
+}
+
+// GetChainBySlug returns an active chain, or nil if it does not exist
+func (r *PostgresRepository) GetChainBySlug(ctx context.Context, slug string) (*models.Chain, error) {
+	query := `
+		SELECT ` + chainColumns + `
+		FROM chains c
+		LEFT JOIN rpc_endpoints e ON c.id = e.chain_id
+		WHERE c.slug = $1
+		  AND c.is_active = true
+		GROUP BY c.id
+	`
+
+	chain, err := scanChain(r.pool.QueryRow(ctx, query, slug))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain: %w", err)
+	}
+
+	return &chain, nil
+}
+
+// ListRPCEndpoints returns the upstream nodes of a chain by priority
+func (r *PostgresRepository) ListRPCEndpoints(ctx context.Context, chainSlug string) ([]models.RPCEndpoint, error) {
+	query := `
+		SELECT
+			e.id,
+			e.name,
+			e.url,
+			e.endpoint_type,
+			COALESCE(e.is_archive, false) as is_archive,
+			COALESCE(e.supports_trace, false) as supports_trace,
+			COALESCE(e.weight, 100) as weight,
+			COALESCE(e.priority, 100) as priority,
+			COALESCE(e.is_healthy, false) as is_healthy,
+			e.last_health_check,
+			COALESCE(e.health_check_failures, 0) as health_check_failures,
+			COALESCE(e.avg_latency_ms, 0) as avg_latency_ms,
+			COALESCE(e.is_active, false) as is_active,
+			COALESCE(e.provider, '') as provider
+		FROM rpc_endpoints e
+		JOIN chains c ON c.id = e.chain_id
+		WHERE c.slug = $1
+		ORDER BY e.priority, e.weight DESC, e.name
+	`
+
+	rows, err := r.pool.Query(ctx, query, chainSlug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rpc endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var endpoints []models.RPCEndpoint
+	for rows.Next() {
+		var endpoint models.RPCEndpoint
+		if err := rows.Scan(
+			&endpoint.ID,
+			&endpoint.Name,
+			&endpoint.URL,
+			&endpoint.EndpointType,
+			&endpoint.IsArchive,
+			&endpoint.SupportsTrace,
+			&endpoint.Weight,
+			&endpoint.Priority,
+			&endpoint.IsHealthy,
+			&endpoint.LastHealthCheck,
+			&endpoint.HealthCheckFailures,
+			&endpoint.AvgLatencyMS,
+			&endpoint.IsActive,
+			&endpoint.Provider,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan rpc endpoint row: %w", err)
+		}
+		// chain_health_minutely identifies upstreams by host name, without
+		// the URL's scheme, port or path
+		if u, err := url.Parse(endpoint.URL); err == nil {
+			endpoint.Host = u.Hostname()
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, rows.Err()
+}
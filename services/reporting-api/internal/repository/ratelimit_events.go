@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"go.uber.org/zap"
+)
+
+// RateLimitEventWriter writes rate limit events to ClickHouse asynchronously.
+// Events are buffered in a bounded channel and inserted in batches; when the
+// buffer is full new events are dropped so request handling never blocks.
+type RateLimitEventWriter struct {
+	repo          *ClickHouseRepository
+	logger        *zap.Logger
+	events        chan models.RateLimitEvent
+	batchSize     int
+	flushInterval time.Duration
+	dropped       atomic.Uint64
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewRateLimitEventWriter starts a writer flushing every EventFlushInterval
+// seconds or every EventBatchSize events, whichever comes first
+func NewRateLimitEventWriter(repo *ClickHouseRepository, cfg *config.RateLimitConfig, logger *zap.Logger) *RateLimitEventWriter {
+	w := &RateLimitEventWriter{
+		repo:          repo,
+		logger:        logger,
+		events:        make(chan models.RateLimitEvent, cfg.EventBufferSize),
+		batchSize:     cfg.EventBatchSize,
+		flushInterval: time.Duration(cfg.EventFlushInterval) * time.Second,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+// RecordRateLimitEvent queues an event without blocking
+func (w *RateLimitEventWriter) RecordRateLimitEvent(event models.RateLimitEvent) {
+	select {
+	case w.events <- event:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+// Close flushes buffered events and stops the writer.
+// Events recorded after Close are not written.
+func (w *RateLimitEventWriter) Close() {
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+func (w *RateLimitEventWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.RateLimitEvent, 0, w.batchSize)
+	for {
+		select {
+		case event := <-w.events:
+			batch = append(batch, event)
+			if len(batch) >= w.batchSize {
+				batch = w.flush(batch)
+			}
+		case <-ticker.C:
+			batch = w.flush(batch)
+		case <-w.stop:
+			// Drain whatever is still buffered before the final flush
+			for {
+				select {
+				case event := <-w.events:
+					batch = append(batch, event)
+					if len(batch) >= w.batchSize {
+						batch = w.flush(batch)
+					}
+				default:
+					w.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush inserts the batch and returns it emptied for reuse
+func (w *RateLimitEventWriter) flush(batch []models.RateLimitEvent) []models.RateLimitEvent {
+	if dropped := w.dropped.Swap(0); dropped > 0 {
+		w.logger.Warn("Rate limit event buffer full, events dropped", zap.Uint64("dropped", dropped))
+	}
+	if len(batch) == 0 {
+		return batch
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := w.repo.InsertRateLimitEvents(ctx, batch); err != nil {
+		// The batch is lost; retrying would let a ClickHouse outage grow memory
+		w.logger.Error("Failed to write rate limit events",
+			zap.Int("events", len(batch)),
+			zap.Error(err),
+		)
+	}
+
+	return batch[:0]
+}
+
+// InsertRateLimitEvents writes events to rate_limit_events in one batch
+func (r *ClickHouseRepository) InsertRateLimitEvents(ctx context.Context, events []models.RateLimitEvent) error {
+	batch, err := r.conn.PrepareBatch(ctx, `
+		INSERT INTO rate_limit_events (
+			timestamp, timestamp_ms,
+			organization_id, consumer_id, plan_slug,
+			limit_type, limit_value, current_count,
+			client_ip, metadata
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare rate limit events batch: %w", err)
+	}
+
+	for _, e := range events {
+		if err := batch.Append(
+			e.Timestamp, e.Timestamp,
+			e.OrganizationID, e.ConsumerID, e.PlanSlug,
+			e.LimitType, e.LimitValue, e.CurrentCount,
+			e.ClientIP, e.Metadata,
+		); err != nil {
+			return fmt.Errorf("failed to append rate limit event: %w", err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send rate limit events: %w", err)
+	}
+
+	return nil
+}
+
+// GetRateLimitReport aggregates an organization's rate limit rejections.
+// interval must be "hour" or "day".
+func (r *ClickHouseRepository) GetRateLimitReport(ctx context.Context, orgID string, startDate, endDate time.Time, interval string) (*models.RateLimitReport, error) {
+	bucketExpr := "toStartOfHour(timestamp, " + zoneOf(startDate) + ")"
+	if interval == "day" {
+		bucketExpr = "toStartOfDay(timestamp, " + zoneOf(startDate) + ")"
+	}
+
+	report := &models.RateLimitReport{
+		OrganizationID: orgID,
+		Period:         models.NewPeriod(startDate, endDate),
+		Interval:       interval,
+		ByLimitType:    []models.RateLimitTypeSummary{},
+		ByConsumer:     []models.RateLimitConsumer{},
+		Timeseries:     []models.RateLimitBucket{},
+	}
+
+	// Limit values can change with the plan; report the latest one
+	typeQuery := `
+		SELECT
+			limit_type,
+			count() AS rejections,
+			argMax(limit_value, timestamp_ms) AS limit_value,
+			max(if(limit_value > 0, current_count / limit_value, 0)) AS peak_usage_ratio
+		FROM rate_limit_events
+		WHERE organization_id = ?
+		  AND timestamp >= ?
+		  AND timestamp <= ?
+		GROUP BY limit_type
+		ORDER BY rejections DESC
+	`
+
+	rows, err := r.conn.Query(ctx, typeQuery, orgID, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate limit events by type: %w", err)
+	}
+	for rows.Next() {
+		var summary models.RateLimitTypeSummary
+		if err := rows.Scan(
+			&summary.LimitType,
+			&summary.Rejections,
+			&summary.LimitValue,
+			&summary.PeakUsageRatio,
+		); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan rate limit type row: %w", err)
+		}
+		report.TotalRejections += summary.Rejections
+		report.ByLimitType = append(report.ByLimitType, summary)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rate limit type rows: %w", err)
+	}
+
+	consumerQuery := `
+		SELECT
+			consumer_id,
+			count() AS rejections,
+			max(if(limit_value > 0, current_count / limit_value, 0)) AS peak_usage_ratio,
+			max(timestamp) AS last_rejected_at
+		FROM rate_limit_events
+		WHERE organization_id = ?
+		  AND timestamp >= ?
+		  AND timestamp <= ?
+		GROUP BY consumer_id
+		ORDER BY rejections DESC
+		LIMIT 100
+	`
+
+	rows, err = r.conn.Query(ctx, consumerQuery, orgID, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate limit events by consumer: %w", err)
+	}
+	for rows.Next() {
+		var consumer models.RateLimitConsumer
+		if err := rows.Scan(
+			&consumer.ConsumerID,
+			&consumer.Rejections,
+			&consumer.PeakUsageRatio,
+			&consumer.LastRejectedAt,
+		); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan rate limit consumer row: %w", err)
+		}
+		report.ByConsumer = append(report.ByConsumer, consumer)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rate limit consumer rows: %w", err)
+	}
+
+	timeseriesQuery := fmt.Sprintf(`
+		SELECT
+			%s AS bucket,
+			limit_type,
+			consumer_id,
+			count() AS rejections,
+			max(current_count) AS peak_current_count,
+			max(limit_value) AS limit_value,
+			max(if(limit_value > 0, current_count / limit_value, 0)) AS peak_usage_ratio
+		FROM rate_limit_events
+		WHERE organization_id = ?
+		  AND timestamp >= ?
+		  AND timestamp <= ?
+		GROUP BY bucket, limit_type, consumer_id
+		ORDER BY bucket ASC, rejections DESC
+	`, bucketExpr)
+
+	rows, err = r.conn.Query(ctx, timeseriesQuery, orgID, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate limit timeseries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket models.RateLimitBucket
+		if err := rows.Scan(
+			&bucket.Timestamp,
+			&bucket.LimitType,
+			&bucket.ConsumerID,
+			&bucket.Rejections,
+			&bucket.PeakCurrentCount,
+			&bucket.LimitValue,
+			&bucket.PeakUsageRatio,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan rate limit timeseries row: %w", err)
+		}
+		report.Timeseries = append(report.Timeseries, bucket)
+	}
+
+	return report, rows.Err()
+}
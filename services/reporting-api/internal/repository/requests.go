@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/pkg/utils"
+)
+
+// requestColumns must stay in sync with scanRequestTrace
+const requestColumns = `
+	timestamp_ms,
+	request_id,
+	organization_id,
+	consumer_id,
+	api_key_prefix,
+	plan_slug,
+	chain_slug,
+	chain_type,
+	chain_id,
+	method,
+	path,
+	route_name,
+	status_code,
+	response_size,
+	latency_ms,
+	upstream_latency_ms,
+	kong_latency_ms,
+	upstream_host,
+	upstream_status,
+	client_ip,
+	user_agent,
+	rpc_method,
+	rpc_id,
+	compute_units,
+	error_message,
+	is_error,
+	metadata`
+
+// scanRequestTrace scans a requestColumns row; the client IP is masked here
+// so an unmasked address never leaves the repository
+func scanRequestTrace(rows driver.Rows) (models.RequestTrace, error) {
+	var trace models.RequestTrace
+	var isError uint8
+	err := rows.Scan(
+		&trace.Timestamp,
+		&trace.RequestID,
+		&trace.OrganizationID,
+		&trace.ConsumerID,
+		&trace.APIKeyPrefix,
+		&trace.PlanSlug,
+		&trace.ChainSlug,
+		&trace.ChainType,
+		&trace.ChainID,
+		&trace.Method,
+		&trace.Path,
+		&trace.RouteName,
+		&trace.StatusCode,
+		&trace.ResponseSize,
+		&trace.LatencyMS,
+		&trace.UpstreamLatencyMS,
+		&trace.KongLatencyMS,
+		&trace.UpstreamHost,
+		&trace.UpstreamStatus,
+		&trace.ClientIP,
+		&trace.UserAgent,
+		&trace.RPCMethod,
+		&trace.RPCID,
+		&trace.ComputeUnits,
+		&trace.ErrorMessage,
+		&isError,
+		&trace.Metadata,
+	)
+	trace.IsError = isError == 1
+	trace.ClientIP = utils.MaskIP(trace.ClientIP)
+	return trace, err
+}
+
+// GetRequest returns a request by id, or nil if it is unknown or has expired.
+// A non-empty orgID restricts the lookup to that organization.
+func (r *ClickHouseRepository) GetRequest(ctx context.Context, requestID, orgID string) (*models.RequestTrace, error) {
+	query := "SELECT " + requestColumns + " FROM requests_raw WHERE request_id = ?"
+	args := []interface{}{requestID}
+	if orgID != "" {
+		query += " AND organization_id = ?"
+		args = append(args, orgID)
+	}
+	query += " ORDER BY timestamp_ms DESC LIMIT 1"
+
+	rows, err := r.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get request: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	trace, err := scanRequestTrace(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan request row: %w", err)
+	}
+
+	return &trace, nil
+}
+
+// SearchRequests returns one page of an organization's requests, newest
+// first. cursor is the NextCursor of the previous page, empty for the first.
+func (r *ClickHouseRepository) SearchRequests(ctx context.Context, orgID string, startDate, endDate time.Time, filter models.RequestFilter, limit int, cursor string) (*models.RequestsPage, error) {
+	conditions := []string{"organization_id = ?", "timestamp >= ?", "timestamp <= ?"}
+	args := []interface{}{orgID, startDate, endDate}
+
+	if filter.ChainSlug != "" {
+		conditions = append(conditions, "chain_slug = ?")
+		args = append(args, filter.ChainSlug)
+	}
+	if filter.RPCMethod != "" {
+		conditions = append(conditions, "rpc_method = ?")
+		args = append(args, filter.RPCMethod)
+	}
+	if filter.StatusCode != 0 {
+		conditions = append(conditions, "status_code = ?")
+		args = append(args, filter.StatusCode)
+	}
+	if filter.StatusClass != 0 {
+		conditions = append(conditions, "intDiv(status_code, 100) = ?")
+		args = append(args, filter.StatusClass)
+	}
+	if filter.MinLatencyMS > 0 {
+		conditions = append(conditions, "latency_ms >= ?")
+		args = append(args, filter.MinLatencyMS)
+	}
+	if cursor != "" {
+		after, err := decodeKeysetCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		condition, cursorArgs := after.condition()
+		conditions = append(conditions, condition)
+		args = append(args, cursorArgs...)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	query := "SELECT " + requestColumns + `
+		FROM requests_raw
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY timestamp_ms DESC, request_id DESC
+		LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := r.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search requests: %w", err)
+	}
+	defer rows.Close()
+
+	page := &models.RequestsPage{
+		OrganizationID: orgID,
+		Period:         models.NewPeriod(startDate, endDate),
+		Requests:       []models.RequestTrace{},
+	}
+	for rows.Next() {
+		trace, err := scanRequestTrace(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan request row: %w", err)
+		}
+		page.Requests = append(page.Requests, trace)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read request rows: %w", err)
+	}
+
+	if len(page.Requests) > limit {
+		page.Requests = page.Requests[:limit]
+		last := page.Requests[limit-1]
+		page.NextCursor = keysetCursor{
+			timestampMs: last.Timestamp.UnixMilli(),
+			requestID:   last.RequestID,
+		}.encode()
+	}
+
+	return page, nil
+}
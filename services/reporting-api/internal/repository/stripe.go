@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// ListStripeMeterExports retrieves the metered Stripe prices of active
+// subscriptions billed through Stripe, with their export watermarks. Empty
+// orgID covers every organization.
+func (r *PostgresRepository) ListStripeMeterExports(ctx context.Context, orgID string) ([]models.StripeMeterExport, error) {
+	query := `
+		SELECT
+			s.id,
+			s.organization_id,
+			s.stripe_subscription_id,
+			s.current_period_start,
+			sp.meter,
+			sp.stripe_price_id,
+			w.exported_through
+		FROM subscriptions s
+		JOIN stripe_prices sp ON sp.plan_id = s.plan_id
+		LEFT JOIN stripe_usage_watermarks w ON w.subscription_id = s.id AND w.meter = sp.meter
+		WHERE s.status = 'active'
+		  AND s.stripe_subscription_id IS NOT NULL
+		  AND s.current_period_start IS NOT NULL
+		  AND ($1 = '' OR s.organization_id::text = $1)
+		ORDER BY s.organization_id, s.id, sp.meter
+	`
+
+	rows, err := r.pool.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stripe meter exports: %w", err)
+	}
+	defer rows.Close()
+
+	var exports []models.StripeMeterExport
+	for rows.Next() {
+		var export models.StripeMeterExport
+		if err := rows.Scan(
+			&export.SubscriptionID,
+			&export.OrganizationID,
+			&export.StripeSubscriptionID,
+			&export.CurrentPeriodStart,
+			&export.Meter,
+			&export.StripePriceID,
+			&export.ExportedThrough,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan stripe meter export row: %w", err)
+		}
+		exports = append(exports, export)
+	}
+
+	return exports, rows.Err()
+}
+
+// AdvanceStripeWatermark records that a subscription meter's usage was
+// reported up to through. The watermark never moves back, so an overlapping
+// run that finished earlier cannot undo a later one.
+func (r *PostgresRepository) AdvanceStripeWatermark(ctx context.Context, subscriptionID, meter string, through time.Time) error {
+	query := `
+		INSERT INTO stripe_usage_watermarks (subscription_id, meter, exported_through)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (subscription_id, meter) DO UPDATE
+		SET exported_through = EXCLUDED.exported_through,
+		    updated_at = NOW()
+		WHERE stripe_usage_watermarks.exported_through < EXCLUDED.exported_through
+	`
+
+	if _, err := r.pool.Exec(ctx, query, subscriptionID, meter, through); err != nil {
+		return fmt.Errorf("failed to advance stripe watermark: %w", err)
+	}
+
+	return nil
+}
+
+// UpsertStripeInvoice stores an invoice received from Stripe for the
+// subscription with stripeSubscriptionID, keyed by invoice.StripeInvoiceID.
+// A paid invoice stays paid when an earlier event arrives late. It returns
+// false when no subscription has that Stripe id.
+func (r *PostgresRepository) UpsertStripeInvoice(ctx context.Context, stripeSubscriptionID string, invoice *models.Invoice) (bool, error) {
+	lineItems, err := json.Marshal(invoice.LineItems)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode invoice line items: %w", err)
+	}
+
+	query := `
+		INSERT INTO invoices (
+			organization_id,
+			subscription_id,
+			invoice_number,
+			subtotal,
+			tax,
+			total,
+			currency,
+			status,
+			period_start,
+			period_end,
+			due_date,
+			paid_at,
+			line_items,
+			stripe_invoice_id
+		)
+		SELECT s.organization_id, s.id, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+		FROM subscriptions s
+		WHERE s.stripe_subscription_id = $1
+		ON CONFLICT (stripe_invoice_id) DO UPDATE
+		SET status = CASE WHEN invoices.status = 'paid' THEN invoices.status ELSE EXCLUDED.status END,
+		    paid_at = COALESCE(invoices.paid_at, EXCLUDED.paid_at),
+		    subtotal = EXCLUDED.subtotal,
+		    tax = EXCLUDED.tax,
+		    total = EXCLUDED.total,
+		    due_date = EXCLUDED.due_date,
+		    line_items = EXCLUDED.line_items,
+		    updated_at = NOW()
+		RETURNING id, organization_id, subscription_id, status, paid_at, created_at
+	`
+
+	err = r.pool.QueryRow(ctx, query,
+		stripeSubscriptionID,
+		invoice.InvoiceNumber,
+		invoice.Subtotal,
+		invoice.Tax,
+		invoice.Total,
+		invoice.Currency,
+		invoice.Status,
+		invoice.PeriodStart,
+		invoice.PeriodEnd,
+		invoice.DueDate,
+		invoice.PaidAt,
+		lineItems,
+		invoice.StripeInvoiceID,
+	).Scan(
+		&invoice.ID,
+		&invoice.OrganizationID,
+		&invoice.SubscriptionID,
+		&invoice.Status,
+		&invoice.PaidAt,
+		&invoice.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to upsert stripe invoice: %w", err)
+	}
+
+	return true, nil
+}
+
+// GetUsageBuckets retrieves an organization's usage per UTC hour or day
+// from usage_hourly, for hours from start up to but not including end.
+// Buckets without usage are absent.
+func (r *ClickHouseRepository) GetUsageBuckets(ctx context.Context, orgID string, startDate, endDate time.Time, granularity string) ([]models.BillableUsageBucket, error) {
+	if time.Since(startDate) > sourceRetention[SourceUsageHourly] {
+		return nil, fmt.Errorf("usage_hourly does not keep usage from %s", startDate.UTC().Format(time.RFC3339))
+	}
+
+	bucketExpr := "hour"
+	if granularity == "day" {
+		bucketExpr = "toStartOfDay(hour, 'UTC')"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			%s AS bucket,
+			sumMerge(request_count) AS requests,
+			sumMerge(compute_units_used) AS compute_units,
+			sumMerge(total_response_size) AS egress_bytes
+		FROM usage_hourly
+		WHERE organization_id = ?
+		  AND hour >= ?
+		  AND hour < ?
+		GROUP BY bucket
+		ORDER BY bucket
+	`, bucketExpr)
+
+	rows, err := r.conn.Query(ctx, query, orgID, startDate.UTC(), endDate.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage buckets: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []models.BillableUsageBucket
+	for rows.Next() {
+		var bucket models.BillableUsageBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Requests, &bucket.ComputeUnits, &bucket.EgressBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan usage bucket row: %w", err)
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
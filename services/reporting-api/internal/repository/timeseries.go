@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// Usage tables, finest first. Each is kept for its retention below.
+const (
+	SourceRequestsRaw = "requests_raw"
+	SourceUsageHourly = "usage_hourly"
+	SourceUsageDaily  = "usage_daily"
+)
+
+// ZonedUsageRetention bounds ranges in zones other than UTC: usage_daily
+// holds UTC days, so those are rebuilt from usage_hourly
+const ZonedUsageRetention = 90 * 24 * time.Hour
+
+var sourceRetention = map[string]time.Duration{
+	SourceRequestsRaw: 14 * 24 * time.Hour,
+	SourceUsageHourly: ZonedUsageRetention,
+	SourceUsageDaily:  540 * 24 * time.Hour,
+}
+
+// maxTimeseriesRows caps a timeseries query; larger results are truncated
+const maxTimeseriesRows = 50000
+
+// Granularities maps each timeseries granularity to its nominal bucket width
+var Granularities = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+}
+
+// timeseriesMetric is a metric's expression over requests_raw and over the
+// aggregate states of usage_hourly and usage_daily
+type timeseriesMetric struct {
+	raw       string
+	aggregate string
+}
+
+var timeseriesMetrics = map[string]timeseriesMetric{
+	"requests":      {"count()", "sumMerge(request_count)"},
+	"compute_units": {"sum(compute_units)", "sumMerge(compute_units_used)"},
+	"errors":        {"countIf(is_error = 1)", "sumMerge(error_count)"},
+	"error_rate":    {"countIf(is_error = 1) / count() * 100", "sumMerge(error_count) / sumMerge(request_count) * 100"},
+	"egress_bytes":  {"sum(response_size)", "sumMerge(total_response_size)"},
+	"latency_avg":   {"avg(latency_ms)", "avgMerge(latency_ms_avg)"},
+	"latency_p50":   {"quantile(0.50)(latency_ms)", "arrayElement(quantilesMerge(0.50, 0.95, 0.99)(latency_ms_quantiles), 1)"},
+	"latency_p95":   {"quantile(0.95)(latency_ms)", "arrayElement(quantilesMerge(0.50, 0.95, 0.99)(latency_ms_quantiles), 2)"},
+	"latency_p99":   {"quantile(0.99)(latency_ms)", "arrayElement(quantilesMerge(0.50, 0.95, 0.99)(latency_ms_quantiles), 3)"},
+}
+
+// ValidTimeseriesMetric reports whether a metric is accepted by
+// GetUsageTimeseries
+func ValidTimeseriesMetric(metric string) bool {
+	_, ok := timeseriesMetrics[metric]
+	return ok
+}
+
+// UsageTimeseriesSource picks the table for a timeseries: requests_raw for
+// minute granularity, otherwise the rollup chosen by UsageSource. It fails
+// when the granularity or a dimension is not available for that far back.
+func UsageTimeseriesSource(granularity string, groupBy []string, filters map[string][]string, startDate, now time.Time) (string, error) {
+	if _, ok := Granularities[granularity]; !ok {
+		return "", fmt.Errorf("granularity must be one of minute, hour, day, week, month")
+	}
+
+	age := now.Sub(startDate)
+	switch {
+	case granularity == "minute":
+		if age > sourceRetention[SourceRequestsRaw] {
+			return "", fmt.Errorf("minute granularity is only available for the last 14 days")
+		}
+		return SourceRequestsRaw, nil
+	case granularity == "hour" && age > sourceRetention[SourceUsageHourly]:
+		return "", fmt.Errorf("hour granularity is only available for the last 90 days")
+	}
+
+	return UsageSource(groupBy, filters, startDate, now)
+}
+
+// bucketExpr truncates a source's time column to the granularity in zone, a
+// quoted time zone literal
+func bucketExpr(granularity, source, zone string) string {
+	column := "timestamp"
+	switch source {
+	case SourceUsageHourly:
+		column = "hour"
+	case SourceUsageDaily:
+		column = "toDateTime(date, 'UTC')"
+	}
+
+	switch granularity {
+	case "minute":
+		return "toStartOfMinute(" + column + ")"
+	case "hour":
+		return "toStartOfHour(" + column + ", " + zone + ")"
+	case "day":
+		return "toStartOfDay(" + column + ", " + zone + ")"
+	case "week":
+		return "toDateTime(toMonday(" + column + ", " + zone + "), " + zone + ")"
+	default:
+		return "toDateTime(toStartOfMonth(" + column + ", " + zone + "), " + zone + ")"
+	}
+}
+
+// GetUsageTimeseries buckets an organization's usage by params.Aggregation,
+// with one series per combination of params.GroupBy. params must have passed
+// ValidateUsageDimensions and UsageTimeseriesSource.
+func (r *ClickHouseRepository) GetUsageTimeseries(ctx context.Context, orgID string, params models.UsageQueryParams) (*models.UsageTimeseries, error) {
+	source, err := UsageTimeseriesSource(params.Aggregation, params.GroupBy, params.Filters, params.StartDate, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	columns := dimensionColumns(params.GroupBy)
+	selects := append([]string{bucketExpr(params.Aggregation, source, zoneOf(params.StartDate)) + " AS bucket"}, columns...)
+	groupBy := append([]string{"bucket"}, columns...)
+	orderBy := strings.Join(append(columns, "bucket"), ", ")
+	for _, name := range params.Metrics {
+		expr, err := metricExpr(name, source)
+		if err != nil {
+			return nil, err
+		}
+		selects = append(selects, expr)
+	}
+
+	conditions := []string{"organization_id = ?"}
+	args := []interface{}{orgID}
+	switch source {
+	case SourceRequestsRaw:
+		conditions = append(conditions, "timestamp >= ?", "timestamp <= ?")
+	case SourceUsageHourly:
+		conditions = append(conditions, "hour >= toStartOfHour(?)", "hour <= ?")
+	case SourceUsageDaily:
+		conditions = append(conditions, "date >= toDate(?)", "date <= toDate(?)")
+	}
+	args = append(args, params.StartDate, params.EndDate)
+	if params.ChainSlug != "" {
+		conditions = append(conditions, "chain_slug = ?")
+		args = append(args, params.ChainSlug)
+	}
+	filterConditions, filterArgs := dimensionFilters(params.Filters)
+	conditions = append(conditions, filterConditions...)
+	args = append(args, filterArgs...)
+
+	// One extra row tells whether the result was truncated
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s
+		GROUP BY %s
+		ORDER BY %s
+		LIMIT ?
+	`, strings.Join(selects, ", "), source, strings.Join(conditions, " AND "), strings.Join(groupBy, ", "), orderBy)
+	args = append(args, maxTimeseriesRows+1)
+
+	rows, err := r.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage timeseries: %w", err)
+	}
+	defer rows.Close()
+
+	result := &models.UsageTimeseries{
+		OrganizationID: orgID,
+		Period:         models.NewPeriod(params.StartDate, params.EndDate),
+		Granularity:    params.Aggregation,
+		Source:         source,
+		Metrics:        params.Metrics,
+		GroupBy:        params.GroupBy,
+		Filters:        params.Filters,
+		Series:         []models.TimeseriesSeries{},
+	}
+
+	var bucket time.Time
+	group := make([]string, len(params.GroupBy))
+	values := make([]float64, len(params.Metrics))
+	dest := []interface{}{&bucket}
+	for i := range group {
+		dest = append(dest, &group[i])
+	}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+
+	var series *models.TimeseriesSeries
+	rowCount := 0
+	for rows.Next() {
+		if rowCount++; rowCount > maxTimeseriesRows {
+			result.Truncated = true
+			break
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan usage timeseries row: %w", err)
+		}
+
+		// Rows arrive ordered by group, so a new group starts a new series
+		if series == nil || !sameGroup(series.Group, params.GroupBy, group) {
+			result.Series = append(result.Series, models.TimeseriesSeries{
+				Group:  groupMap(params.GroupBy, group),
+				Points: []models.TimeseriesPoint{},
+			})
+			series = &result.Series[len(result.Series)-1]
+		}
+
+		point := models.TimeseriesPoint{
+			Timestamp: bucket,
+			Metrics:   make(map[string]float64, len(values)),
+		}
+		for i, name := range params.Metrics {
+			point.Metrics[name] = values[i]
+		}
+		series.Points = append(series.Points, point)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,167 @@
+package status
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+	"go.uber.org/zap"
+)
+
+// Monitor periodically evaluates chain health and records state changes as
+// incidents. Several replicas may run a monitor: Postgres allows only one
+// open incident per chain.
+type Monitor struct {
+	clickhouseRepo *repository.ClickHouseRepository
+	postgresRepo   *repository.PostgresRepository
+	cfg            *config.StatusConfig
+	logger         *zap.Logger
+
+	// Consecutive operational checks per chain with an open incident
+	recovering map[string]int
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func NewMonitor(ch *repository.ClickHouseRepository, pg *repository.PostgresRepository, cfg *config.StatusConfig, logger *zap.Logger) *Monitor {
+	return &Monitor{
+		clickhouseRepo: ch,
+		postgresRepo:   pg,
+		cfg:            cfg,
+		logger:         logger,
+		recovering:     make(map[string]int),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+}
+
+// Start runs checks every CheckInterval seconds until Close
+func (m *Monitor) Start() {
+	go func() {
+		defer close(m.done)
+
+		ticker := time.NewTicker(time.Duration(m.cfg.CheckInterval) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			m.runCheck()
+
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the monitor and waits for a running check to finish
+func (m *Monitor) Close() {
+	m.once.Do(func() { close(m.stop) })
+	<-m.done
+}
+
+func (m *Monitor) runCheck() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := m.Check(ctx); err != nil {
+		m.logger.Warn("Status check failed", zap.Error(err))
+	}
+}
+
+// Check evaluates every active mainnet chain once
+func (m *Monitor) Check(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	chains, err := m.postgresRepo.ListChains(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	health, err := m.clickhouseRepo.GetChainHealthSummary(ctx, now.Add(-time.Duration(m.cfg.WindowMinutes)*time.Minute))
+	if err != nil {
+		return err
+	}
+
+	open, err := m.postgresRepo.GetOpenIncidents(ctx)
+	if err != nil {
+		return err
+	}
+	openBySlug := make(map[string]models.Incident, len(open))
+	for _, incident := range open {
+		openBySlug[incident.ChainSlug] = incident
+	}
+
+	for _, chain := range chains {
+		var chainHealth *models.ChainHealth
+		if h, ok := health[chain.Slug]; ok {
+			chainHealth = &h
+		}
+		state, reason := Evaluate(m.cfg, chainHealth)
+		incident, hasIncident := openBySlug[chain.Slug]
+
+		var recordErr error
+
+		switch state {
+		case Degraded, Outage:
+			delete(m.recovering, chain.Slug)
+			title := incidentTitle(chain.DisplayName, state, reason)
+			if hasIncident {
+				recordErr = m.postgresRepo.UpdateIncident(ctx, incident.ID, state, title, chainHealth.ErrorRatePct, chainHealth.P95LatencyMS)
+				if recordErr == nil {
+					recordErr = m.postgresRepo.RecordIncidentState(ctx, incident.ID, state, now)
+				}
+				break
+			}
+			var opened *models.Incident
+			opened, recordErr = m.postgresRepo.OpenIncident(ctx, chain.Slug, state, title, now, chainHealth.ErrorRatePct, chainHealth.P95LatencyMS)
+			if recordErr == nil && opened != nil {
+				m.logger.Warn("Incident opened",
+					zap.String("chain", chain.Slug),
+					zap.String("status", state),
+					zap.String("reason", reason),
+				)
+			}
+
+		case Operational:
+			if !hasIncident {
+				delete(m.recovering, chain.Slug)
+				break
+			}
+			// Require several healthy checks so a flapping chain keeps one
+			// incident; the recovering time no longer counts as outage
+			if recordErr = m.postgresRepo.RecordIncidentState(ctx, incident.ID, Operational, now); recordErr != nil {
+				break
+			}
+			m.recovering[chain.Slug]++
+			if m.recovering[chain.Slug] < m.cfg.ResolveAfterChecks {
+				break
+			}
+			delete(m.recovering, chain.Slug)
+			if recordErr = m.postgresRepo.ResolveIncident(ctx, incident.ID, now); recordErr == nil {
+				m.logger.Info("Incident resolved",
+					zap.String("chain", chain.Slug),
+					zap.Duration("duration", now.Sub(incident.StartedAt)),
+				)
+			}
+
+		default:
+			// Without traffic there is nothing to judge; leave incidents as they are
+		}
+
+		if recordErr != nil {
+			m.logger.Warn("Failed to record chain status",
+				zap.String("chain", chain.Slug),
+				zap.Error(recordErr),
+			)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package status
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/repository"
+)
+
+// UptimeWindow is the period behind uptime percentages
+const UptimeWindow = 90 * 24 * time.Hour
+
+// pageCacheTTL keeps the unauthenticated status routes off the databases
+const pageCacheTTL = 30 * time.Second
+
+// MaxIncidents is the most incidents Incidents returns
+const MaxIncidents = 200
+
+// Service builds the public status page
+type Service struct {
+	clickhouseRepo *repository.ClickHouseRepository
+	postgresRepo   *repository.PostgresRepository
+	cfg            *config.StatusConfig
+
+	mu        sync.Mutex
+	page      *models.StatusPage
+	expiresAt time.Time
+
+	incidentsMu        sync.Mutex
+	incidents          []models.Incident
+	incidentsExpiresAt time.Time
+}
+
+func NewService(ch *repository.ClickHouseRepository, pg *repository.PostgresRepository, cfg *config.StatusConfig) *Service {
+	return &Service{
+		clickhouseRepo: ch,
+		postgresRepo:   pg,
+		cfg:            cfg,
+	}
+}
+
+// Page returns the status of every active mainnet chain
+func (s *Service) Page(ctx context.Context) (*models.StatusPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.page != nil && time.Now().Before(s.expiresAt) {
+		return s.page, nil
+	}
+
+	page, err := s.buildPage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.page = page
+	s.expiresAt = time.Now().Add(pageCacheTTL)
+	return page, nil
+}
+
+// Incidents returns up to limit incidents of the uptime window, newest
+// first. Like Page, the list is cached; it holds the latest MaxIncidents.
+func (s *Service) Incidents(ctx context.Context, limit int) ([]models.Incident, error) {
+	s.incidentsMu.Lock()
+	defer s.incidentsMu.Unlock()
+
+	if s.incidents == nil || !time.Now().Before(s.incidentsExpiresAt) {
+		incidents, err := s.postgresRepo.ListIncidents(ctx, time.Now().UTC().Add(-UptimeWindow), MaxIncidents)
+		if err != nil {
+			return nil, err
+		}
+		s.incidents = incidents
+		s.incidentsExpiresAt = time.Now().Add(pageCacheTTL)
+	}
+
+	if limit > len(s.incidents) {
+		limit = len(s.incidents)
+	}
+	return s.incidents[:limit:limit], nil
+}
+
+func (s *Service) buildPage(ctx context.Context) (*models.StatusPage, error) {
+	now := time.Now().UTC()
+
+	chains, err := s.postgresRepo.ListChains(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	health, err := s.clickhouseRepo.GetChainHealthSummary(ctx, now.Add(-time.Duration(s.cfg.WindowMinutes)*time.Minute))
+	if err != nil {
+		return nil, err
+	}
+
+	open, err := s.postgresRepo.GetOpenIncidents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	openBySlug := make(map[string]models.Incident, len(open))
+	for _, incident := range open {
+		openBySlug[incident.ChainSlug] = incident
+	}
+
+	outages, err := s.postgresRepo.GetOutageSeconds(ctx, now.Add(-UptimeWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.StatusPage{
+		Status:          Operational,
+		UpdatedAt:       now,
+		Chains:          make([]models.ChainStatus, 0, len(chains)),
+		ActiveIncidents: open,
+	}
+
+	for _, chain := range chains {
+		status := models.ChainStatus{
+			Slug:         chain.Slug,
+			Name:         chain.DisplayName,
+			UptimePct90d: 100 * (1 - outages[chain.Slug]/UptimeWindow.Seconds()),
+		}
+
+		var chainHealth *models.ChainHealth
+		if h, ok := health[chain.Slug]; ok {
+			chainHealth = &h
+			status.RequestCount = h.RequestCount
+			status.ErrorRatePct = h.ErrorRatePct
+			status.P95LatencyMS = h.P95LatencyMS
+		}
+		status.Status, status.Reason = Evaluate(s.cfg, chainHealth)
+
+		// An open incident keeps the chain flagged until the monitor resolves it
+		if incident, ok := openBySlug[chain.Slug]; ok {
+			status.ActiveIncident = &incident
+			if Worse(incident.Status, status.Status) && status.Status != Unknown {
+				status.Status = Degraded
+			}
+		}
+
+		if Worse(status.Status, page.Status) {
+			page.Status = status.Status
+		}
+		page.Chains = append(page.Chains, status)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,68 @@
+package status
+
+import (
+	"fmt"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// Chain states, ordered by severity
+const (
+	Unknown     = "unknown"
+	Operational = "operational"
+	Degraded    = "degraded"
+	Outage      = "outage"
+)
+
+var severity = map[string]int{
+	Unknown:     0,
+	Operational: 1,
+	Degraded:    2,
+	Outage:      3,
+}
+
+// Worse reports whether state a is more severe than b
+func Worse(a, b string) bool {
+	return severity[a] > severity[b]
+}
+
+// Evaluate derives a chain's state from its recent health. health is nil when
+// the chain had no traffic in the window. The reason explains non-operational
+// states.
+func Evaluate(cfg *config.StatusConfig, health *models.ChainHealth) (string, string) {
+	if health == nil || health.RequestCount == 0 {
+		return Unknown, "no recent traffic"
+	}
+
+	if health.TotalUpstreams > 0 && health.HealthyUpstreams == 0 {
+		return Outage, "no healthy upstreams"
+	}
+
+	// Too few requests make error rates and percentiles noise
+	if health.RequestCount < uint64(cfg.MinRequests) {
+		return Operational, ""
+	}
+
+	switch {
+	case health.ErrorRatePct >= cfg.OutageErrorRatePct:
+		return Outage, fmt.Sprintf("error rate %.1f%%", health.ErrorRatePct)
+	case health.P95LatencyMS >= cfg.OutageP95LatencyMS:
+		return Outage, fmt.Sprintf("p95 latency %.0f ms", health.P95LatencyMS)
+	case health.ErrorRatePct >= cfg.DegradedErrorRatePct:
+		return Degraded, fmt.Sprintf("error rate %.1f%%", health.ErrorRatePct)
+	case health.P95LatencyMS >= cfg.DegradedP95LatencyMS:
+		return Degraded, fmt.Sprintf("p95 latency %.0f ms", health.P95LatencyMS)
+	}
+
+	return Operational, ""
+}
+
+// incidentTitle describes an incident for the status page and feeds
+func incidentTitle(chainName, state, reason string) string {
+	label := "Degraded performance"
+	if state == Outage {
+		label = "Outage"
+	}
+	return fmt.Sprintf("%s: %s (%s)", chainName, label, reason)
+}
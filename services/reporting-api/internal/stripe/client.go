@@ -0,0 +1,133 @@
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+)
+
+// SubscriptionItem is a price of a Stripe subscription
+type SubscriptionItem struct {
+	ID    string `json:"id"`
+	Price struct {
+		ID string `json:"id"`
+	} `json:"price"`
+}
+
+// UsageRecord is a reported quantity of a metered subscription item
+type UsageRecord struct {
+	ID               string `json:"id"`
+	SubscriptionItem string `json:"subscription_item"`
+	Quantity         int64  `json:"quantity"`
+	Timestamp        int64  `json:"timestamp"`
+}
+
+// apiError is the error body of Stripe API responses
+type apiError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Client calls the parts of the Stripe API used for metered billing
+type Client struct {
+	apiURL     string
+	secretKey  string
+	httpClient *http.Client
+}
+
+func NewClient(cfg *config.StripeConfig) *Client {
+	return &Client{
+		apiURL:    strings.TrimRight(cfg.APIURL, "/"),
+		secretKey: cfg.SecretKey,
+		httpClient: &http.Client{
+			Timeout: time.Duration(cfg.Timeout) * time.Second,
+		},
+	}
+}
+
+// ListSubscriptionItems returns the items of a Stripe subscription
+func (c *Client) ListSubscriptionItems(ctx context.Context, subscriptionID string) ([]SubscriptionItem, error) {
+	query := url.Values{"subscription": {subscriptionID}, "limit": {"100"}}
+
+	var page struct {
+		Data []SubscriptionItem `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/subscription_items?"+query.Encode(), nil, "", &page); err != nil {
+		return nil, fmt.Errorf("failed to list stripe subscription items: %w", err)
+	}
+
+	return page.Data, nil
+}
+
+// CreateUsageRecord sets a metered subscription item's usage at timestamp
+// to quantity, replacing what was reported for that timestamp before, so
+// resending a record never counts it twice. Stripe also returns the first
+// result again for a repeated idempotencyKey, but only keeps keys for 24
+// hours. This is Stripe's legacy usage records API, which subscription items
+// with metered prices still use.
+func (c *Client) CreateUsageRecord(ctx context.Context, itemID string, quantity int64, timestamp time.Time, idempotencyKey string) (*UsageRecord, error) {
+	form := url.Values{
+		"quantity":  {strconv.FormatInt(quantity, 10)},
+		"timestamp": {strconv.FormatInt(timestamp.Unix(), 10)},
+		"action":    {"set"},
+	}
+
+	var record UsageRecord
+	path := "/v1/subscription_items/" + url.PathEscape(itemID) + "/usage_records"
+	if err := c.do(ctx, http.MethodPost, path, form, idempotencyKey, &record); err != nil {
+		return nil, fmt.Errorf("failed to create stripe usage record: %w", err)
+	}
+
+	return &record, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.secretKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr apiError
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("stripe returned status %d: %s", resp.StatusCode, apiErr.Error.Message)
+		}
+		return fmt.Errorf("stripe returned status %d", resp.StatusCode)
+	}
+
+	return json.Unmarshal(respBody, out)
+}
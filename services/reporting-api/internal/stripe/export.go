@@ -0,0 +1,269 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/billing"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"go.uber.org/zap"
+)
+
+// UsageSource reads an organization's usage per bucket; the ClickHouse
+// repository implements it
+type UsageSource interface {
+	GetUsageBuckets(ctx context.Context, orgID string, startDate, endDate time.Time, granularity string) ([]models.BillableUsageBucket, error)
+}
+
+// ExportStore lists the subscription meters billed through Stripe and keeps
+// their watermarks; the Postgres repository implements it
+type ExportStore interface {
+	ListStripeMeterExports(ctx context.Context, orgID string) ([]models.StripeMeterExport, error)
+	AdvanceStripeWatermark(ctx context.Context, subscriptionID, meter string, through time.Time) error
+}
+
+// Exporter reports metered usage of Stripe-billed subscriptions to Stripe
+type Exporter struct {
+	usage  UsageSource
+	store  ExportStore
+	client *Client
+	cfg    *config.StripeConfig
+	logger *zap.Logger
+}
+
+func NewExporter(usage UsageSource, store ExportStore, client *Client, cfg *config.StripeConfig, logger *zap.Logger) *Exporter {
+	return &Exporter{
+		usage:  usage,
+		store:  store,
+		client: client,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// ExportedRecord is a usage record reported, or with a dry run to be
+// reported, for one bucket
+type ExportedRecord struct {
+	Timestamp      time.Time `json:"timestamp"`
+	Quantity       int64     `json:"quantity"`
+	IdempotencyKey string    `json:"idempotency_key"`
+}
+
+// ExportResult is the outcome of exporting one subscription meter
+type ExportResult struct {
+	SubscriptionID  string           `json:"subscription_id"`
+	OrganizationID  string           `json:"organization_id"`
+	Meter           string           `json:"meter"`
+	ExportedThrough time.Time        `json:"exported_through"`
+	Records         []ExportedRecord `json:"records"`
+	Unreported      []ExportedRecord `json:"unreported,omitempty"`
+	Err             error            `json:"-"`
+}
+
+// Run reports the usage of every complete, settled bucket after each
+// subscription meter's watermark, for one organization unless orgID is
+// empty. A meter without a watermark starts at the first bucket of the
+// subscription's current period. Records set rather than add to a bucket's
+// usage and the watermark moves past a bucket once Stripe accepted it, so a
+// rerun neither skips nor repeats buckets, even after Stripe forgot the
+// record's idempotency key. Stripe rejects records dated before the current
+// period, so buckets of a closed period that were never sent are skipped and
+// logged as unreported. With dryRun nothing is sent or recorded. A failing meter does not stop the others; its error is in its
+// ExportResult.
+func (e *Exporter) Run(ctx context.Context, orgID string, now time.Time, dryRun bool) ([]ExportResult, error) {
+	exports, err := e.store.ListStripeMeterExports(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketSize := time.Hour
+	if e.cfg.Granularity == "day" {
+		bucketSize = 24 * time.Hour
+	}
+	until := now.UTC().Add(-time.Duration(e.cfg.SettleMinutes) * time.Minute).Truncate(bucketSize)
+
+	// Subscription items are looked up once per Stripe subscription
+	items := make(map[string]map[string]string)
+
+	results := make([]ExportResult, 0, len(exports))
+	for _, export := range exports {
+		result := ExportResult{
+			SubscriptionID: export.SubscriptionID,
+			OrganizationID: export.OrganizationID,
+			Meter:          export.Meter,
+			Records:        []ExportedRecord{},
+		}
+
+		var itemID string
+		if !dryRun {
+			itemID, result.Err = e.subscriptionItem(ctx, items, export)
+		}
+		if result.Err == nil {
+			result.ExportedThrough, result.Err = e.exportMeter(ctx, export, itemID, bucketSize, until, dryRun, &result)
+		}
+		if result.Err != nil {
+			e.logger.Error("Failed to export usage to Stripe",
+				zap.String("subscription_id", export.SubscriptionID),
+				zap.String("meter", export.Meter),
+				zap.Error(result.Err),
+			)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// exportMeter reports one subscription meter's buckets up to until into
+// result and returns the watermark it reached
+func (e *Exporter) exportMeter(ctx context.Context, export models.StripeMeterExport, itemID string, bucketSize time.Duration, until time.Time, dryRun bool, result *ExportResult) (time.Time, error) {
+	periodStart := ceilTime(export.CurrentPeriodStart.UTC(), bucketSize)
+	from := periodStart
+	if export.ExportedThrough != nil {
+		from = export.ExportedThrough.UTC()
+	}
+
+	// The period rolled over before these buckets were sent
+	if from.Before(periodStart) {
+		unreported, err := e.records(ctx, export, from, periodStart)
+		if err != nil {
+			return from, err
+		}
+		result.Unreported = unreported
+		if len(unreported) > 0 && !dryRun {
+			var quantity int64
+			for _, record := range unreported {
+				quantity += record.Quantity
+			}
+			e.logger.Error("Usage of a closed Stripe period was not reported",
+				zap.String("subscription_id", export.SubscriptionID),
+				zap.String("meter", export.Meter),
+				zap.Time("from", from),
+				zap.Time("until", periodStart),
+				zap.Int("records", len(unreported)),
+				zap.Int64("quantity", quantity),
+			)
+		}
+		from = periodStart
+		if !dryRun {
+			if err := e.store.AdvanceStripeWatermark(ctx, export.SubscriptionID, export.Meter, from); err != nil {
+				return from, err
+			}
+		}
+	}
+	if !from.Before(until) {
+		return from, nil
+	}
+
+	records, err := e.records(ctx, export, from, until)
+	if err != nil {
+		return from, err
+	}
+
+	for _, record := range records {
+		if dryRun {
+			result.Records = append(result.Records, record)
+			continue
+		}
+
+		if _, err := e.client.CreateUsageRecord(ctx, itemID, record.Quantity, record.Timestamp, record.IdempotencyKey); err != nil {
+			return from, err
+		}
+		result.Records = append(result.Records, record)
+
+		from = record.Timestamp.Add(bucketSize)
+		if err := e.store.AdvanceStripeWatermark(ctx, export.SubscriptionID, export.Meter, from); err != nil {
+			return from, err
+		}
+	}
+
+	// Empty buckets up to until are done as well
+	if dryRun {
+		return until, nil
+	}
+	if err := e.store.AdvanceStripeWatermark(ctx, export.SubscriptionID, export.Meter, until); err != nil {
+		return from, err
+	}
+
+	e.logger.Info("Usage exported to Stripe",
+		zap.String("subscription_id", export.SubscriptionID),
+		zap.String("meter", export.Meter),
+		zap.Int("records", len(result.Records)),
+		zap.Time("exported_through", until),
+	)
+
+	return until, nil
+}
+
+// records returns the usage records of a meter's non-empty buckets from
+// start up to until
+func (e *Exporter) records(ctx context.Context, export models.StripeMeterExport, start, until time.Time) ([]ExportedRecord, error) {
+	buckets, err := e.usage.GetUsageBuckets(ctx, export.OrganizationID, start, until, e.cfg.Granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []ExportedRecord
+	for _, bucket := range buckets {
+		quantity := meterQuantity(export.Meter, bucket)
+		if quantity == 0 {
+			continue
+		}
+		records = append(records, ExportedRecord{
+			Timestamp:      bucket.Start.UTC(),
+			Quantity:       quantity,
+			IdempotencyKey: fmt.Sprintf("usage-%s-%s-%d", export.SubscriptionID, export.Meter, bucket.Start.Unix()),
+		})
+	}
+	return records, nil
+}
+
+// subscriptionItem returns the Stripe subscription item with the meter's
+// price, caching the subscription's items in items
+func (e *Exporter) subscriptionItem(ctx context.Context, items map[string]map[string]string, export models.StripeMeterExport) (string, error) {
+	byPrice, ok := items[export.StripeSubscriptionID]
+	if !ok {
+		list, err := e.client.ListSubscriptionItems(ctx, export.StripeSubscriptionID)
+		if err != nil {
+			return "", err
+		}
+		byPrice = make(map[string]string, len(list))
+		for _, item := range list {
+			byPrice[item.Price.ID] = item.ID
+		}
+		items[export.StripeSubscriptionID] = byPrice
+	}
+
+	itemID, ok := byPrice[export.StripePriceID]
+	if !ok {
+		return "", fmt.Errorf("stripe subscription %s has no item with price %s", export.StripeSubscriptionID, export.StripePriceID)
+	}
+	return itemID, nil
+}
+
+// meterQuantity returns a bucket's usage in the whole units reported for
+// meter. Egress is reported in bytes, so no bucket rounds away usage; the
+// Stripe price converts the period's total to GB.
+func meterQuantity(meter string, bucket models.BillableUsageBucket) int64 {
+	switch meter {
+	case billing.MeterComputeUnits:
+		return int64(bucket.ComputeUnits)
+	case billing.MeterRequests:
+		return int64(bucket.Requests)
+	case billing.MeterEgressGB:
+		return int64(bucket.EgressBytes)
+	}
+	return 0
+}
+
+// ceilTime rounds t up to a multiple of d, so a bucket only partly in the
+// period is not reported
+func ceilTime(t time.Time, d time.Duration) time.Time {
+	truncated := t.Truncate(d)
+	if truncated.Before(t) {
+		return truncated.Add(d)
+	}
+	return truncated
+}
@@ -0,0 +1,402 @@
+package stripe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/billing"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/stripe/stripestub"
+	"go.uber.org/zap"
+)
+
+const (
+	testSubscriptionID = "sub-1"
+	testStripeSubID    = "sub_stripe_1"
+)
+
+var periodStart = time.Date(2026, 10, 1, 0, 30, 0, 0, time.UTC)
+
+func hourOf(hour int) time.Time {
+	return time.Date(2026, 10, 1, hour, 0, 0, 0, time.UTC)
+}
+
+// fakeUsage serves fixed buckets from start up to but not including end
+type fakeUsage struct {
+	buckets []models.BillableUsageBucket
+}
+
+func (u *fakeUsage) GetUsageBuckets(_ context.Context, _ string, startDate, endDate time.Time, _ string) ([]models.BillableUsageBucket, error) {
+	var buckets []models.BillableUsageBucket
+	for _, bucket := range u.buckets {
+		if !bucket.Start.Before(startDate) && bucket.Start.Before(endDate) {
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets, nil
+}
+
+// fakeExportStore keeps watermarks like AdvanceStripeWatermark: they never
+// move back. failAdvance fails that many upcoming watermark writes.
+type fakeExportStore struct {
+	mu          sync.Mutex
+	exports     []models.StripeMeterExport
+	watermarks  map[string]time.Time
+	failAdvance int
+}
+
+func newFakeExportStore(exports ...models.StripeMeterExport) *fakeExportStore {
+	return &fakeExportStore{exports: exports, watermarks: make(map[string]time.Time)}
+}
+
+func (s *fakeExportStore) ListStripeMeterExports(context.Context, string) ([]models.StripeMeterExport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exports := make([]models.StripeMeterExport, len(s.exports))
+	for i, export := range s.exports {
+		if through, ok := s.watermarks[export.SubscriptionID+"/"+export.Meter]; ok {
+			export.ExportedThrough = &through
+		}
+		exports[i] = export
+	}
+	return exports, nil
+}
+
+func (s *fakeExportStore) AdvanceStripeWatermark(_ context.Context, subscriptionID, meter string, through time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failAdvance > 0 {
+		s.failAdvance--
+		return errors.New("connection reset")
+	}
+	key := subscriptionID + "/" + meter
+	if current, ok := s.watermarks[key]; !ok || current.Before(through) {
+		s.watermarks[key] = through
+	}
+	return nil
+}
+
+func (s *fakeExportStore) watermark(meter string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	through, ok := s.watermarks[testSubscriptionID+"/"+meter]
+	return through, ok
+}
+
+func meterExport(meter, priceID string) models.StripeMeterExport {
+	return models.StripeMeterExport{
+		SubscriptionID:       testSubscriptionID,
+		OrganizationID:       "org-1",
+		StripeSubscriptionID: testStripeSubID,
+		CurrentPeriodStart:   periodStart,
+		Meter:                meter,
+		StripePriceID:        priceID,
+	}
+}
+
+// testUsage has usage before the period's first full hour, an empty hour
+// and an hour that has not settled at 05:10
+func testUsage() *fakeUsage {
+	return &fakeUsage{buckets: []models.BillableUsageBucket{
+		{Start: hourOf(0), ComputeUnits: 7, EgressBytes: 10},
+		{Start: hourOf(1), ComputeUnits: 100, EgressBytes: 1500},
+		{Start: hourOf(2)},
+		{Start: hourOf(3), ComputeUnits: 250},
+		{Start: hourOf(4), ComputeUnits: 40, EgressBytes: 700},
+		{Start: hourOf(5), ComputeUnits: 999, EgressBytes: 5},
+	}}
+}
+
+type exportFixture struct {
+	stub       *stripestub.Server
+	store      *fakeExportStore
+	exporter   *Exporter
+	unitsItem  string
+	egressItem string
+}
+
+func newExportFixture(t *testing.T, exports ...models.StripeMeterExport) *exportFixture {
+	t.Helper()
+	stub := stripestub.NewServer()
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	cfg := &config.StripeConfig{
+		APIURL:        server.URL,
+		SecretKey:     "sk_test_123",
+		Granularity:   "hour",
+		SettleMinutes: 0,
+		Timeout:       5,
+	}
+	store := newFakeExportStore(exports...)
+	return &exportFixture{
+		stub:       stub,
+		store:      store,
+		exporter:   NewExporter(testUsage(), store, NewClient(cfg), cfg, zap.NewNop()),
+		unitsItem:  stub.AddSubscriptionItem(testStripeSubID, "price_units"),
+		egressItem: stub.AddSubscriptionItem(testStripeSubID, "price_egress"),
+	}
+}
+
+func quantities(records []stripestub.UsageRecord) []int64 {
+	out := make([]int64, len(records))
+	for i, record := range records {
+		out[i] = record.Quantity
+	}
+	return out
+}
+
+func equalQuantities(a, b []int64) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func TestExporterRun(t *testing.T) {
+	f := newExportFixture(t,
+		meterExport(billing.MeterComputeUnits, "price_units"),
+		meterExport(billing.MeterEgressGB, "price_egress"),
+	)
+	now := hourOf(5).Add(10 * time.Minute)
+
+	results, err := f.exporter.Run(context.Background(), "", now, false)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			t.Fatalf("%s: %v", result.Meter, result.Err)
+		}
+		if !result.ExportedThrough.Equal(hourOf(5)) {
+			t.Errorf("%s exported through %v, want 05:00", result.Meter, result.ExportedThrough)
+		}
+	}
+
+	// Full, settled and non-empty hours of the period only
+	if got, want := quantities(f.stub.UsageRecords(f.unitsItem)), []int64{100, 250, 40}; !equalQuantities(got, want) {
+		t.Errorf("compute unit records = %v, want %v", got, want)
+	}
+	// Egress is reported in bytes, so small hours are not rounded away
+	if got, want := quantities(f.stub.UsageRecords(f.egressItem)), []int64{1500, 700}; !equalQuantities(got, want) {
+		t.Errorf("egress records = %v, want %v", got, want)
+	}
+
+	records := f.stub.UsageRecords(f.unitsItem)
+	if records[0].Timestamp != hourOf(1).Unix() {
+		t.Errorf("first record at %d, want the 01:00 bucket", records[0].Timestamp)
+	}
+	wantKey := fmt.Sprintf("usage-%s-%s-%d", testSubscriptionID, billing.MeterComputeUnits, hourOf(1).Unix())
+	if key := results[0].Records[0].IdempotencyKey; key != wantKey {
+		t.Errorf("idempotency key = %q, want %q", key, wantKey)
+	}
+	if through, _ := f.store.watermark(billing.MeterComputeUnits); !through.Equal(hourOf(5)) {
+		t.Errorf("watermark = %v, want 05:00", through)
+	}
+}
+
+func TestExporterRunIsIdempotent(t *testing.T) {
+	f := newExportFixture(t, meterExport(billing.MeterComputeUnits, "price_units"))
+	now := hourOf(5).Add(10 * time.Minute)
+	ctx := context.Background()
+
+	if _, err := f.exporter.Run(ctx, "", now, false); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	results, err := f.exporter.Run(ctx, "", now, false)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if len(results[0].Records) != 0 || results[0].Err != nil {
+		t.Errorf("rerun exported %+v", results[0])
+	}
+	if got := quantities(f.stub.UsageRecords(f.unitsItem)); !equalQuantities(got, []int64{100, 250, 40}) {
+		t.Errorf("records after rerun = %v", got)
+	}
+
+	// A later run picks up the bucket that has settled since
+	if _, err := f.exporter.Run(ctx, "", now.Add(time.Hour), false); err != nil {
+		t.Fatalf("later Run: %v", err)
+	}
+	if got := quantities(f.stub.UsageRecords(f.unitsItem)); !equalQuantities(got, []int64{100, 250, 40, 999}) {
+		t.Errorf("records after later run = %v", got)
+	}
+}
+
+func TestExporterRunResumesAfterWatermarkFailure(t *testing.T) {
+	f := newExportFixture(t,
+		meterExport(billing.MeterComputeUnits, "price_units"),
+		meterExport(billing.MeterEgressGB, "price_egress"),
+	)
+	now := hourOf(5).Add(10 * time.Minute)
+	ctx := context.Background()
+
+	// Stripe accepts the first record, then its watermark write fails
+	f.store.failAdvance = 1
+	results, err := f.exporter.Run(ctx, "", now, false)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if results[0].Err == nil {
+		t.Fatalf("watermark failure not reported")
+	}
+	if len(results[0].Records) != 1 {
+		t.Errorf("failed meter reported %d accepted records, want 1", len(results[0].Records))
+	}
+	if _, ok := f.store.watermark(billing.MeterComputeUnits); ok {
+		t.Errorf("watermark written despite the failure")
+	}
+	// The other meter is exported regardless
+	if results[1].Err != nil || len(f.stub.UsageRecords(f.egressItem)) != 2 {
+		t.Errorf("egress meter = %+v", results[1])
+	}
+
+	// The rerun resends the accepted bucket with the same idempotency key,
+	// which Stripe does not count twice
+	results, err = f.exporter.Run(ctx, "", now, false)
+	if err != nil || results[0].Err != nil {
+		t.Fatalf("rerun: %v, %v", err, results[0].Err)
+	}
+	if len(results[0].Records) != 3 {
+		t.Errorf("rerun sent %d records, want 3", len(results[0].Records))
+	}
+	if got := quantities(f.stub.UsageRecords(f.unitsItem)); !equalQuantities(got, []int64{100, 250, 40}) {
+		t.Errorf("records after rerun = %v, want each bucket once", got)
+	}
+	if through, _ := f.store.watermark(billing.MeterComputeUnits); !through.Equal(hourOf(5)) {
+		t.Errorf("watermark = %v, want 05:00", through)
+	}
+}
+
+func TestExporterRunReplayAfterIdempotencyKeysExpired(t *testing.T) {
+	f := newExportFixture(t, meterExport(billing.MeterComputeUnits, "price_units"))
+	now := hourOf(5).Add(10 * time.Minute)
+	ctx := context.Background()
+
+	// The first record's watermark write fails, and the rerun comes after
+	// Stripe dropped the idempotency keys
+	f.store.failAdvance = 1
+	if _, err := f.exporter.Run(ctx, "", now, false); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	f.stub.ForgetIdempotencyKeys()
+
+	results, err := f.exporter.Run(ctx, "", now, false)
+	if err != nil || results[0].Err != nil {
+		t.Fatalf("rerun: %v, %v", err, results[0].Err)
+	}
+	if got := quantities(f.stub.UsageRecords(f.unitsItem)); !equalQuantities(got, []int64{100, 250, 40}) {
+		t.Errorf("records after replay = %v, want each bucket once", got)
+	}
+}
+
+func TestExporterRunAfterPeriodRollover(t *testing.T) {
+	// The watermark stopped at 02:00 and the period has since rolled over to
+	// 04:00, before which Stripe takes no records
+	export := meterExport(billing.MeterComputeUnits, "price_units")
+	export.CurrentPeriodStart = hourOf(4)
+	f := newExportFixture(t, export)
+	f.store.watermarks[testSubscriptionID+"/"+billing.MeterComputeUnits] = hourOf(2)
+	f.stub.SetPeriodStart(testStripeSubID, hourOf(4))
+	now := hourOf(5).Add(10 * time.Minute)
+	ctx := context.Background()
+
+	results, err := f.exporter.Run(ctx, "", now, false)
+	if err != nil || results[0].Err != nil {
+		t.Fatalf("Run: %v, %+v", err, results)
+	}
+	unreported := results[0].Unreported
+	if len(unreported) != 1 || unreported[0].Quantity != 250 || !unreported[0].Timestamp.Equal(hourOf(3)) {
+		t.Errorf("unreported = %+v, want the 03:00 bucket", unreported)
+	}
+	if got := quantities(f.stub.UsageRecords(f.unitsItem)); !equalQuantities(got, []int64{40}) {
+		t.Errorf("records = %v, want the current period only", got)
+	}
+	if through, _ := f.store.watermark(billing.MeterComputeUnits); !through.Equal(hourOf(5)) {
+		t.Errorf("watermark = %v, want 05:00", through)
+	}
+
+	// The skipped buckets are reported once
+	results, err = f.exporter.Run(ctx, "", now, false)
+	if err != nil || results[0].Err != nil || len(results[0].Unreported) != 0 {
+		t.Errorf("rerun = %+v, %v", results, err)
+	}
+}
+
+func TestExporterRunMissingPrice(t *testing.T) {
+	f := newExportFixture(t,
+		meterExport(billing.MeterRequests, "price_missing"),
+		meterExport(billing.MeterComputeUnits, "price_units"),
+	)
+
+	results, err := f.exporter.Run(context.Background(), "", hourOf(5).Add(10*time.Minute), false)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if results[0].Err == nil || !strings.Contains(results[0].Err.Error(), "price_missing") {
+		t.Errorf("missing price err = %v", results[0].Err)
+	}
+	if _, ok := f.store.watermark(billing.MeterRequests); ok {
+		t.Errorf("watermark written for a meter without a subscription item")
+	}
+	if results[1].Err != nil || len(results[1].Records) != 3 {
+		t.Errorf("other meter = %+v", results[1])
+	}
+}
+
+func TestExporterDryRun(t *testing.T) {
+	// No subscription item has this price: a dry run does not look it up
+	f := newExportFixture(t,
+		meterExport(billing.MeterComputeUnits, "price_units"),
+		meterExport(billing.MeterRequests, "price_missing"),
+	)
+	now := hourOf(5).Add(10 * time.Minute)
+
+	results, err := f.exporter.Run(context.Background(), "", now, true)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if results[0].Err != nil || results[1].Err != nil {
+		t.Fatalf("dry run errors: %v, %v", results[0].Err, results[1].Err)
+	}
+
+	records := results[0].Records
+	if len(records) != 3 || records[0].Quantity != 100 || !records[0].Timestamp.Equal(hourOf(1)) {
+		t.Errorf("dry run records = %+v", records)
+	}
+	if !results[0].ExportedThrough.Equal(hourOf(5)) {
+		t.Errorf("exported through %v, want 05:00", results[0].ExportedThrough)
+	}
+
+	if got := f.stub.UsageRecords(f.unitsItem); len(got) != 0 {
+		t.Errorf("dry run sent %d records", len(got))
+	}
+	if _, ok := f.store.watermark(billing.MeterComputeUnits); ok {
+		t.Errorf("dry run moved the watermark")
+	}
+}
+
+func TestMeterQuantity(t *testing.T) {
+	bucket := models.BillableUsageBucket{Requests: 12, ComputeUnits: 340, EgressBytes: 1}
+
+	tests := []struct {
+		meter string
+		want  int64
+	}{
+		{billing.MeterRequests, 12},
+		{billing.MeterComputeUnits, 340},
+		{billing.MeterEgressGB, 1},
+		{"storage", 0},
+	}
+	for _, tt := range tests {
+		if got := meterQuantity(tt.meter, bucket); got != tt.want {
+			t.Errorf("meterQuantity(%s) = %d, want %d", tt.meter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Package stripestub fakes the Stripe endpoints used for metered billing, for
+// local development and for running the exporter against httptest.
+package stripestub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UsageRecord is a usage record the stub accepted
+type UsageRecord struct {
+	ID               string `json:"id"`
+	Object           string `json:"object"`
+	SubscriptionItem string `json:"subscription_item"`
+	Quantity         int64  `json:"quantity"`
+	Timestamp        int64  `json:"timestamp"`
+}
+
+type subscriptionItem struct {
+	ID           string `json:"id"`
+	Object       string `json:"object"`
+	Subscription string `json:"subscription"`
+	Price        struct {
+		ID string `json:"id"`
+	} `json:"price"`
+}
+
+// Server keeps subscription items and usage records in memory. Any bearer
+// token is accepted.
+type Server struct {
+	mu          sync.Mutex
+	nextID      int
+	items       map[string]*subscriptionItem
+	records     map[string][]UsageRecord
+	idempotency map[string][]byte
+	periodStart map[string]int64
+}
+
+func NewServer() *Server {
+	return &Server{
+		items:       make(map[string]*subscriptionItem),
+		records:     make(map[string][]UsageRecord),
+		idempotency: make(map[string][]byte),
+		periodStart: make(map[string]int64),
+	}
+}
+
+// AddSubscriptionItem creates an item with priceID on a subscription and
+// returns its id
+func (s *Server) AddSubscriptionItem(subscriptionID, priceID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item := &subscriptionItem{
+		ID:           s.newID("si"),
+		Object:       "subscription_item",
+		Subscription: subscriptionID,
+	}
+	item.Price.ID = priceID
+	s.items[item.ID] = item
+	return item.ID
+}
+
+// SetPeriodStart starts a subscription's current period at start; like
+// Stripe, the stub rejects usage records of its items dated before it
+func (s *Server) SetPeriodStart(subscriptionID string, start time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.periodStart[subscriptionID] = start.Unix()
+}
+
+// ForgetIdempotencyKeys drops every idempotency key, like Stripe does with
+// keys older than 24 hours
+func (s *Server) ForgetIdempotencyKeys() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.idempotency = make(map[string][]byte)
+}
+
+// UsageRecords returns the usage records of a subscription item in the
+// order they were created
+func (s *Server) UsageRecords(itemID string) []UsageRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]UsageRecord(nil), s.records[itemID]...)
+}
+
+// ServeHTTP serves:
+//
+//	GET  /v1/subscription_items?subscription=
+//	POST /v1/subscription_items                                (subscription, price)
+//	POST /v1/subscription_items/{id}/usage_records             (quantity, timestamp, action)
+//	GET  /v1/subscription_items/{id}/usage_record_summaries
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		writeError(w, http.StatusUnauthorized, "authentication_error", "No API key provided")
+		return
+	}
+
+	const prefix = "/v1/subscription_items"
+	if r.URL.Path != prefix && !strings.HasPrefix(r.URL.Path, prefix+"/") {
+		writeError(w, http.StatusNotFound, "invalid_request_error", "Unrecognized request URL")
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		s.listItems(w, r)
+	case path == "" && r.Method == http.MethodPost:
+		s.createItem(w, r)
+	case len(parts) == 2 && parts[1] == "usage_records" && r.Method == http.MethodPost:
+		s.createUsageRecord(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "usage_record_summaries" && r.Method == http.MethodGet:
+		s.usageSummary(w, parts[0])
+	default:
+		writeError(w, http.StatusNotFound, "invalid_request_error", "Unrecognized request URL")
+	}
+}
+
+func (s *Server) listItems(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := r.URL.Query().Get("subscription")
+
+	s.mu.Lock()
+	items := make([]*subscriptionItem, 0)
+	for _, item := range s.items {
+		if item.Subscription == subscriptionID {
+			items = append(items, item)
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object":   "list",
+		"data":     items,
+		"has_more": false,
+	})
+}
+
+func (s *Server) createItem(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "Invalid form body")
+		return
+	}
+	subscriptionID, priceID := r.PostForm.Get("subscription"), r.PostForm.Get("price")
+	if subscriptionID == "" || priceID == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "Missing required param: subscription or price")
+		return
+	}
+
+	id := s.AddSubscriptionItem(subscriptionID, priceID)
+
+	s.mu.Lock()
+	item := s.items[id]
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) createUsageRecord(w http.ResponseWriter, r *http.Request, itemID string) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "Invalid form body")
+		return
+	}
+	quantity, err := strconv.ParseInt(r.PostForm.Get("quantity"), 10, 64)
+	if err != nil || quantity < 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "Invalid quantity")
+		return
+	}
+	timestamp := time.Now().Unix()
+	if value := r.PostForm.Get("timestamp"); value != "" && value != "now" {
+		if timestamp, err = strconv.ParseInt(value, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_error", "Invalid timestamp")
+			return
+		}
+	}
+	action := r.PostForm.Get("action")
+	if action == "" {
+		action = "increment"
+	}
+	if action != "increment" && action != "set" {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "Invalid action")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.Header.Get("Idempotency-Key")
+	if body, ok := s.idempotency[key]; ok && key != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+		return
+	}
+
+	item, ok := s.items[itemID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No such subscription_item: '%s'", itemID))
+		return
+	}
+	if start, ok := s.periodStart[item.Subscription]; ok && timestamp < start {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "Cannot create the usage record with this timestamp because timestamps must be after the subscription's current period start time.")
+		return
+	}
+
+	record := UsageRecord{
+		ID:               s.newID("mbur"),
+		Object:           "usage_record",
+		SubscriptionItem: itemID,
+		Quantity:         quantity,
+		Timestamp:        timestamp,
+	}
+	if action == "set" {
+		// set replaces the usage reported for the same timestamp
+		kept := s.records[itemID][:0]
+		for _, existing := range s.records[itemID] {
+			if existing.Timestamp != timestamp {
+				kept = append(kept, existing)
+			}
+		}
+		s.records[itemID] = kept
+	}
+	s.records[itemID] = append(s.records[itemID], record)
+
+	body, _ := json.Marshal(record)
+	if key != "" {
+		s.idempotency[key] = body
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func (s *Server) usageSummary(w http.ResponseWriter, itemID string) {
+	s.mu.Lock()
+	_, ok := s.items[itemID]
+	var total int64
+	for _, record := range s.records[itemID] {
+		total += record.Quantity
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No such subscription_item: '%s'", itemID))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data": []map[string]interface{}{{
+			"object":            "usage_record_summary",
+			"subscription_item": itemID,
+			"total_usage":       total,
+		}},
+		"has_more": false,
+	})
+}
+
+// newID must be called with mu held
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s_stub%08d", prefix, s.nextID)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, errType, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]string{
+			"type":    errType,
+			"message": message,
+		},
+	})
+}
@@ -0,0 +1,268 @@
+package stripe
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidSignature is returned for events without a valid, recent
+	// Stripe-Signature
+	ErrInvalidSignature = errors.New("invalid stripe signature")
+	// ErrInvalidEvent is returned for signed payloads that are not an event
+	ErrInvalidEvent = errors.New("invalid stripe event")
+)
+
+// zeroDecimalCurrencies are charged in whole units rather than cents
+var zeroDecimalCurrencies = map[string]bool{
+	"bif": true, "clp": true, "djf": true, "gnf": true, "jpy": true,
+	"kmf": true, "krw": true, "mga": true, "pyg": true, "rwf": true,
+	"ugx": true, "vnd": true, "vuv": true, "xaf": true, "xof": true,
+	"xpf": true,
+}
+
+// Event is a Stripe webhook event
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// Invoice is the part of a Stripe invoice stored locally
+type Invoice struct {
+	ID           string `json:"id"`
+	Number       string `json:"number"`
+	Subscription string `json:"subscription"`
+	Parent       struct {
+		SubscriptionDetails struct {
+			Subscription string `json:"subscription"`
+		} `json:"subscription_details"`
+	} `json:"parent"`
+	Status     string `json:"status"`
+	Currency   string `json:"currency"`
+	Subtotal   int64  `json:"subtotal"`
+	Tax        *int64 `json:"tax"`
+	TotalTaxes []struct {
+		Amount int64 `json:"amount"`
+	} `json:"total_taxes"`
+	Total             int64  `json:"total"`
+	PeriodStart       int64  `json:"period_start"`
+	PeriodEnd         int64  `json:"period_end"`
+	DueDate           *int64 `json:"due_date"`
+	StatusTransitions struct {
+		PaidAt *int64 `json:"paid_at"`
+	} `json:"status_transitions"`
+	Lines struct {
+		Data []struct {
+			Description string `json:"description"`
+			Quantity    int64  `json:"quantity"`
+			Amount      int64  `json:"amount"`
+		} `json:"data"`
+	} `json:"lines"`
+}
+
+// SubscriptionID returns the Stripe subscription the invoice bills, which
+// newer API versions move under parent
+func (i *Invoice) SubscriptionID() string {
+	if i.Subscription != "" {
+		return i.Subscription
+	}
+	return i.Parent.SubscriptionDetails.Subscription
+}
+
+// VerifySignature checks a Stripe-Signature header of the form
+// t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<payload>">. The signature must
+// be no older than tolerance at now.
+func VerifySignature(payload []byte, header, secret string, tolerance time.Duration, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := []byte(Sign(payload, secret, time.Unix(unix, 0)))
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// Sign returns the v1 signature Stripe sends for payload at timestamp
+func Sign(payload []byte, secret string, timestamp time.Time) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp.Unix())
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// InvoiceStore stores invoices received from Stripe; the Postgres
+// repository implements it. UpsertStripeInvoice keeps a paid invoice paid
+// and reports false for an unknown Stripe subscription.
+type InvoiceStore interface {
+	UpsertStripeInvoice(ctx context.Context, stripeSubscriptionID string, invoice *models.Invoice) (bool, error)
+}
+
+// Webhooks stores the invoices Stripe reports through webhook events
+type Webhooks struct {
+	invoices  InvoiceStore
+	secret    string
+	tolerance time.Duration
+	logger    *zap.Logger
+}
+
+func NewWebhooks(invoices InvoiceStore, cfg *config.StripeConfig, logger *zap.Logger) *Webhooks {
+	return &Webhooks{
+		invoices:  invoices,
+		secret:    cfg.WebhookSecret,
+		tolerance: time.Duration(cfg.WebhookTolerance) * time.Second,
+		logger:    logger,
+	}
+}
+
+// Handle verifies and processes one webhook delivery. Finalized and paid
+// invoices are upserted by their Stripe id, so redelivered events are
+// harmless. Other events, and invoices of subscriptions not billed through
+// Stripe here, are acknowledged and ignored.
+func (w *Webhooks) Handle(ctx context.Context, payload []byte, signature string, now time.Time) error {
+	if err := VerifySignature(payload, signature, w.secret, w.tolerance, now); err != nil {
+		return err
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil || event.ID == "" {
+		return ErrInvalidEvent
+	}
+
+	switch event.Type {
+	case "invoice.finalized", "invoice.paid", "invoice.payment_succeeded":
+	default:
+		w.logger.Debug("Ignoring Stripe event", zap.String("event_id", event.ID), zap.String("type", event.Type))
+		return nil
+	}
+
+	var stripeInvoice Invoice
+	if err := json.Unmarshal(event.Data.Object, &stripeInvoice); err != nil || stripeInvoice.ID == "" {
+		return ErrInvalidEvent
+	}
+
+	subscriptionID := stripeInvoice.SubscriptionID()
+	if subscriptionID == "" {
+		w.logger.Info("Ignoring Stripe invoice without subscription",
+			zap.String("event_id", event.ID),
+			zap.String("invoice_id", stripeInvoice.ID),
+		)
+		return nil
+	}
+
+	invoice := localInvoice(&stripeInvoice)
+	found, err := w.invoices.UpsertStripeInvoice(ctx, subscriptionID, invoice)
+	if err != nil {
+		return err
+	}
+	if !found {
+		w.logger.Warn("Ignoring Stripe invoice of unknown subscription",
+			zap.String("event_id", event.ID),
+			zap.String("invoice_id", stripeInvoice.ID),
+			zap.String("stripe_subscription_id", subscriptionID),
+		)
+		return nil
+	}
+
+	w.logger.Info("Stripe invoice stored",
+		zap.String("event_id", event.ID),
+		zap.String("type", event.Type),
+		zap.String("invoice_id", invoice.ID),
+		zap.String("stripe_invoice_id", stripeInvoice.ID),
+		zap.String("status", invoice.Status),
+	)
+
+	return nil
+}
+
+// localInvoice converts a Stripe invoice to an invoices row
+func localInvoice(in *Invoice) *models.Invoice {
+	currency := strings.ToLower(in.Currency)
+	amount := func(minor int64) float64 {
+		if zeroDecimalCurrencies[currency] {
+			return float64(minor)
+		}
+		return float64(minor) / 100
+	}
+
+	invoice := &models.Invoice{
+		InvoiceNumber:   in.Number,
+		Subtotal:        amount(in.Subtotal),
+		Total:           amount(in.Total),
+		Currency:        strings.ToUpper(currency),
+		Status:          in.Status,
+		PeriodStart:     time.Unix(in.PeriodStart, 0).UTC(),
+		PeriodEnd:       time.Unix(in.PeriodEnd, 0).UTC(),
+		LineItems:       make([]models.InvoiceLineItem, 0, len(in.Lines.Data)),
+		StripeInvoiceID: &in.ID,
+	}
+	// Drafts have no number yet and finalized invoices always do
+	if invoice.InvoiceNumber == "" {
+		invoice.InvoiceNumber = in.ID
+	}
+	// Newer API versions list taxes in total_taxes instead of tax
+	if in.Tax != nil {
+		invoice.Tax = amount(*in.Tax)
+	} else {
+		var tax int64
+		for _, t := range in.TotalTaxes {
+			tax += t.Amount
+		}
+		invoice.Tax = amount(tax)
+	}
+	if in.DueDate != nil {
+		due := time.Unix(*in.DueDate, 0).UTC()
+		invoice.DueDate = &due
+	}
+	if in.StatusTransitions.PaidAt != nil {
+		paid := time.Unix(*in.StatusTransitions.PaidAt, 0).UTC()
+		invoice.PaidAt = &paid
+	}
+
+	for _, line := range in.Lines.Data {
+		invoice.LineItems = append(invoice.LineItems, models.InvoiceLineItem{
+			Description: line.Description,
+			Quantity:    float64(line.Quantity),
+			Amount:      amount(line.Amount),
+		})
+	}
+
+	return invoice
+}
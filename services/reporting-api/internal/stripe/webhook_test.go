@@ -0,0 +1,267 @@
+package stripe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+	"go.uber.org/zap"
+)
+
+const testWebhookSecret = "whsec_test"
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1"}`)
+	now := time.Unix(1790000000, 0)
+	tolerance := 5 * time.Minute
+	signed := func(at time.Time, secret string) string {
+		return Sign(payload, secret, at)
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		payload []byte
+		wantErr bool
+	}{
+		{"valid", fmt.Sprintf("t=%d,v1=%s", now.Unix(), signed(now, testWebhookSecret)), payload, false},
+		{"spaces after commas", fmt.Sprintf("t=%d, v1=%s", now.Unix(), signed(now, testWebhookSecret)), payload, false},
+		{"at the tolerance", fmt.Sprintf("t=%d,v1=%s", now.Add(-tolerance).Unix(), signed(now.Add(-tolerance), testWebhookSecret)), payload, false},
+		{"too old", fmt.Sprintf("t=%d,v1=%s", now.Add(-tolerance-time.Second).Unix(), signed(now.Add(-tolerance-time.Second), testWebhookSecret)), payload, true},
+		{"too far ahead", fmt.Sprintf("t=%d,v1=%s", now.Add(tolerance+time.Second).Unix(), signed(now.Add(tolerance+time.Second), testWebhookSecret)), payload, true},
+		{"one of several v1 matches", fmt.Sprintf("t=%d,v1=%s,v1=%s,v0=abc", now.Unix(), signed(now, "whsec_old"), signed(now, testWebhookSecret)), payload, false},
+		{"no v1 matches", fmt.Sprintf("t=%d,v1=%s,v1=%s", now.Unix(), signed(now, "whsec_old"), signed(now, "whsec_other")), payload, true},
+		{"wrong secret", fmt.Sprintf("t=%d,v1=%s", now.Unix(), signed(now, "whsec_other")), payload, true},
+		{"timestamp not signed", fmt.Sprintf("t=%d,v1=%s", now.Unix(), signed(now.Add(-time.Second), testWebhookSecret)), payload, true},
+		{"tampered payload", fmt.Sprintf("t=%d,v1=%s", now.Unix(), signed(now, testWebhookSecret)), []byte(`{"id":"evt_2"}`), true},
+		{"only v0", fmt.Sprintf("t=%d,v0=%s", now.Unix(), signed(now, testWebhookSecret)), payload, true},
+		{"missing timestamp", "v1=" + signed(now, testWebhookSecret), payload, true},
+		{"malformed timestamp", "t=yesterday,v1=" + signed(now, testWebhookSecret), payload, true},
+		{"empty header", "", payload, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(tt.payload, tt.header, testWebhookSecret, tolerance, now)
+			if tt.wantErr && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("VerifySignature() = %v, want ErrInvalidSignature", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("VerifySignature() = %v", err)
+			}
+		})
+	}
+}
+
+// fakeInvoiceStore upserts like UpsertStripeInvoice: invoices are keyed by
+// Stripe id, a paid invoice stays paid and keeps its paid_at, and invoices of
+// unknown subscriptions are not stored
+type fakeInvoiceStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]bool
+	invoices      map[string]models.Invoice
+	upserts       int
+}
+
+func newFakeInvoiceStore(stripeSubscriptionIDs ...string) *fakeInvoiceStore {
+	s := &fakeInvoiceStore{subscriptions: make(map[string]bool), invoices: make(map[string]models.Invoice)}
+	for _, id := range stripeSubscriptionIDs {
+		s.subscriptions[id] = true
+	}
+	return s
+}
+
+func (s *fakeInvoiceStore) UpsertStripeInvoice(_ context.Context, stripeSubscriptionID string, invoice *models.Invoice) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.upserts++
+	if !s.subscriptions[stripeSubscriptionID] {
+		return false, nil
+	}
+	stored := *invoice
+	if existing, ok := s.invoices[*invoice.StripeInvoiceID]; ok {
+		if existing.Status == "paid" {
+			stored.Status = existing.Status
+		}
+		if existing.PaidAt != nil {
+			stored.PaidAt = existing.PaidAt
+		}
+	}
+	s.invoices[*invoice.StripeInvoiceID] = stored
+	invoice.Status = stored.Status
+	invoice.PaidAt = stored.PaidAt
+	return true, nil
+}
+
+func (s *fakeInvoiceStore) invoice(stripeInvoiceID string) (models.Invoice, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invoice, ok := s.invoices[stripeInvoiceID]
+	return invoice, ok
+}
+
+func newTestWebhooks(store *fakeInvoiceStore) *Webhooks {
+	return NewWebhooks(store, &config.StripeConfig{WebhookSecret: testWebhookSecret, WebhookTolerance: 300}, zap.NewNop())
+}
+
+// deliver signs payload at now and hands it to w
+func deliver(w *Webhooks, payload string, now time.Time) error {
+	header := fmt.Sprintf("t=%d,v1=%s", now.Unix(), Sign([]byte(payload), testWebhookSecret, now))
+	return w.Handle(context.Background(), []byte(payload), header, now)
+}
+
+func invoiceEvent(eventID, eventType, status string, paidAt int64) string {
+	transitions := `{}`
+	if paidAt != 0 {
+		transitions = fmt.Sprintf(`{"paid_at":%d}`, paidAt)
+	}
+	return fmt.Sprintf(`{"id":%q,"type":%q,"data":{"object":{
+		"id":"in_1","number":"INV-0001","subscription":"sub_stripe_1","status":%q,
+		"currency":"usd","subtotal":4900,"tax":490,"total":5390,
+		"period_start":1788220800,"period_end":1790812800,
+		"status_transitions":%s,
+		"lines":{"data":[{"description":"Pro plan","quantity":1,"amount":4900}]}
+	}}}`, eventID, eventType, status, transitions)
+}
+
+func TestWebhooksHandleInvoice(t *testing.T) {
+	store := newFakeInvoiceStore("sub_stripe_1")
+	w := newTestWebhooks(store)
+	now := time.Unix(1790900000, 0)
+
+	if err := deliver(w, invoiceEvent("evt_1", "invoice.finalized", "open", 0), now); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	invoice, ok := store.invoice("in_1")
+	if !ok {
+		t.Fatalf("invoice not stored")
+	}
+	if invoice.Status != "open" || invoice.PaidAt != nil {
+		t.Errorf("status = %s, paid_at = %v", invoice.Status, invoice.PaidAt)
+	}
+	if invoice.InvoiceNumber != "INV-0001" || invoice.Currency != "USD" {
+		t.Errorf("invoice = %+v", invoice)
+	}
+	if invoice.Subtotal != 49 || invoice.Tax != 4.9 || invoice.Total != 53.9 {
+		t.Errorf("amounts = %v + %v = %v", invoice.Subtotal, invoice.Tax, invoice.Total)
+	}
+	if len(invoice.LineItems) != 1 || invoice.LineItems[0].Amount != 49 {
+		t.Errorf("line items = %+v", invoice.LineItems)
+	}
+	if !invoice.PeriodStart.Equal(time.Unix(1788220800, 0)) {
+		t.Errorf("period start = %v", invoice.PeriodStart)
+	}
+}
+
+func TestWebhooksHandleLateFinalized(t *testing.T) {
+	store := newFakeInvoiceStore("sub_stripe_1")
+	w := newTestWebhooks(store)
+	now := time.Unix(1790900000, 0)
+	paidAt := now.Add(-time.Minute).Unix()
+
+	// Stripe does not order deliveries: paid can arrive before finalized
+	if err := deliver(w, invoiceEvent("evt_2", "invoice.paid", "paid", paidAt), now); err != nil {
+		t.Fatalf("paid: %v", err)
+	}
+	if err := deliver(w, invoiceEvent("evt_1", "invoice.finalized", "open", 0), now.Add(time.Second)); err != nil {
+		t.Fatalf("finalized: %v", err)
+	}
+
+	invoice, _ := store.invoice("in_1")
+	if invoice.Status != "paid" {
+		t.Errorf("status = %s, want paid", invoice.Status)
+	}
+	if invoice.PaidAt == nil || invoice.PaidAt.Unix() != paidAt {
+		t.Errorf("paid_at = %v, want %d", invoice.PaidAt, paidAt)
+	}
+	if store.upserts != 2 {
+		t.Errorf("upserts = %d, want 2", store.upserts)
+	}
+}
+
+func TestWebhooksHandleIgnored(t *testing.T) {
+	now := time.Unix(1790900000, 0)
+
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"other event type", `{"id":"evt_1","type":"customer.created","data":{"object":{"id":"cus_1"}}}`},
+		{"invoice without subscription", `{"id":"evt_1","type":"invoice.paid","data":{"object":{"id":"in_1","status":"paid"}}}`},
+		{"unknown subscription", `{"id":"evt_1","type":"invoice.paid","data":{"object":{"id":"in_1","subscription":"sub_other","status":"paid"}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeInvoiceStore("sub_stripe_1")
+			if err := deliver(newTestWebhooks(store), tt.payload, now); err != nil {
+				t.Errorf("Handle() = %v", err)
+			}
+			if _, ok := store.invoice("in_1"); ok {
+				t.Errorf("invoice stored")
+			}
+		})
+	}
+}
+
+func TestWebhooksHandleParentSubscription(t *testing.T) {
+	store := newFakeInvoiceStore("sub_stripe_1")
+	payload := `{"id":"evt_1","type":"invoice.finalized","data":{"object":{
+		"id":"in_1","status":"open","currency":"jpy","total":5000,
+		"parent":{"subscription_details":{"subscription":"sub_stripe_1"}},
+		"total_taxes":[{"amount":300},{"amount":200}]
+	}}}`
+
+	if err := deliver(newTestWebhooks(store), payload, time.Unix(1790900000, 0)); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	invoice, ok := store.invoice("in_1")
+	if !ok {
+		t.Fatalf("invoice not stored")
+	}
+	// Yen has no minor unit; drafts fall back to the Stripe id as number
+	if invoice.Total != 5000 || invoice.Tax != 500 || invoice.InvoiceNumber != "in_1" {
+		t.Errorf("invoice = %+v", invoice)
+	}
+}
+
+func TestWebhooksHandleRejects(t *testing.T) {
+	store := newFakeInvoiceStore("sub_stripe_1")
+	w := newTestWebhooks(store)
+	now := time.Unix(1790900000, 0)
+	valid := invoiceEvent("evt_1", "invoice.paid", "paid", now.Unix())
+
+	header := fmt.Sprintf("t=%d,v1=%s", now.Unix(), Sign([]byte(valid), "whsec_other", now))
+	if err := w.Handle(context.Background(), []byte(valid), header, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("wrong secret: %v", err)
+	}
+	if err := deliver(w, valid, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("replay setup: %v", err)
+	}
+	// A captured delivery replayed later fails the tolerance check
+	header = fmt.Sprintf("t=%d,v1=%s", now.Add(-time.Hour).Unix(), Sign([]byte(valid), testWebhookSecret, now.Add(-time.Hour)))
+	if err := w.Handle(context.Background(), []byte(valid), header, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("replayed delivery: %v", err)
+	}
+
+	for _, payload := range []string{
+		`not json`,
+		`{"type":"invoice.paid"}`,
+		`{"id":"evt_1","type":"invoice.paid","data":{"object":{"subscription":"sub_stripe_1"}}}`,
+		`{"id":"evt_1","type":"invoice.paid","data":{"object":"in_1"}}`,
+	} {
+		if err := deliver(w, payload, now); !errors.Is(err, ErrInvalidEvent) {
+			t.Errorf("Handle(%s) = %v, want ErrInvalidEvent", payload, err)
+		}
+	}
+	if store.upserts != 1 {
+		t.Errorf("upserts = %d, want only the valid delivery", store.upserts)
+	}
+}
@@ -0,0 +1,218 @@
+package unkey
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/models"
+)
+
+// Cache key prefixes, shared with config/kong-unkey-prefunction.lua. Both
+// index a cached result by its key id so it can be purged by key id.
+const (
+	verifyCachePrefix = "unkey:verify:"
+	keyIDIndexPrefix  = "unkey:keyid:"
+)
+
+// VerifyResult is the keys.verifyKey response
+type VerifyResult struct {
+	Valid       bool      `json:"valid"`
+	Code        string    `json:"code,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	KeyID       string    `json:"keyId,omitempty"`
+	OwnerID     string    `json:"ownerId,omitempty"`
+	Meta        KeyMeta   `json:"meta,omitempty"`
+	Permissions []string  `json:"permissions,omitempty"`
+	Identity    *Identity `json:"identity,omitempty"`
+	Expires     int64     `json:"expires,omitempty"`
+}
+
+// KeyMeta is the metadata attached to keys by scripts/setup-unkey.sh
+type KeyMeta struct {
+	OrganizationID string   `json:"organizationId,omitempty"`
+	Plan           string   `json:"plan,omitempty"`
+	AllowedChains  []string `json:"allowedChains,omitempty"`
+	Scopes         []string `json:"scopes,omitempty"`
+}
+
+// Identity is the Unkey identity a key belongs to
+type Identity struct {
+	ID         string `json:"id"`
+	ExternalID string `json:"externalId"`
+}
+
+// Scopes returns the key's scopes from its permissions and metadata
+func (r *VerifyResult) Scopes() []string {
+	scopes := make([]string, 0, len(r.Permissions)+len(r.Meta.Scopes))
+	scopes = append(scopes, r.Permissions...)
+	scopes = append(scopes, r.Meta.Scopes...)
+	return scopes
+}
+
+// Client verifies API keys against Unkey with a read-through cache
+type Client struct {
+	verifyURL  string
+	rootKey    string
+	apiID      string
+	cache      Cache
+	cacheTTL   time.Duration
+	httpClient *http.Client
+}
+
+// NewClient creates an Unkey client; cache may be nil to disable caching
+func NewClient(cfg *config.UnkeyConfig, cache Cache) *Client {
+	return &Client{
+		verifyURL: cfg.VerifyURL,
+		rootKey:   cfg.RootKey,
+		apiID:     cfg.APIID,
+		cache:     cache,
+		cacheTTL:  time.Duration(cfg.CacheTTL) * time.Second,
+		httpClient: &http.Client{
+			Timeout: time.Duration(cfg.Timeout) * time.Second,
+		},
+	}
+}
+
+// VerifyKey verifies an API key, serving valid results from the cache
+func (c *Client) VerifyKey(ctx context.Context, key string) (*VerifyResult, error) {
+	cacheKey := verifyCacheKey(key)
+
+	if c.cache != nil {
+		if cached, ok, err := c.cache.Get(ctx, cacheKey); err == nil && ok {
+			var result VerifyResult
+			if err := json.Unmarshal(cached, &result); err == nil {
+				return &result, nil
+			}
+		}
+	}
+
+	body, err := c.verify(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var result VerifyResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode unkey response: %w", err)
+	}
+
+	// Only valid results are cached so revoked keys fail fast after a purge
+	if c.cache != nil && result.Valid {
+		// Cache errors are not fatal; the next request verifies again
+		_ = c.cache.Set(ctx, cacheKey, body, c.cacheTTL)
+		if result.KeyID != "" {
+			_ = c.cache.Set(ctx, keyIDIndexPrefix+result.KeyID, []byte(cacheKey), c.cacheTTL)
+		}
+	}
+
+	return &result, nil
+}
+
+// PurgeKey removes a raw key's cached verification result
+func (c *Client) PurgeKey(ctx context.Context, key string) error {
+	if c.cache == nil {
+		return nil
+	}
+	_, err := c.cache.Delete(ctx, verifyCacheKey(key))
+	return err
+}
+
+// PurgeKeyID removes the cached verification result for an Unkey key id,
+// whether this service or Kong cached it, and reports whether there was one.
+// Call it when a key is revoked so the revocation takes effect immediately.
+func (c *Client) PurgeKeyID(ctx context.Context, keyID string) (bool, error) {
+	if c.cache == nil {
+		return false, nil
+	}
+
+	indexKey := keyIDIndexPrefix + keyID
+	cacheKey, ok, err := c.cache.Get(ctx, indexKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read key index: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+
+	deleted, err := c.cache.Delete(ctx, string(cacheKey))
+	if err != nil {
+		return false, fmt.Errorf("failed to purge cached key: %w", err)
+	}
+	if _, err := c.cache.Delete(ctx, indexKey); err != nil {
+		return false, fmt.Errorf("failed to purge key index: %w", err)
+	}
+	return deleted > 0, nil
+}
+
+// ResolveAPIKey verifies a customer API key and returns its metadata.
+// Returns nil without an error for invalid keys.
+func (c *Client) ResolveAPIKey(ctx context.Context, apiKey string) (*models.APIKey, error) {
+	result, err := c.VerifyKey(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Valid || result.Meta.OrganizationID == "" {
+		return nil, nil
+	}
+
+	return &models.APIKey{
+		OrganizationID: result.Meta.OrganizationID,
+		ConsumerID:     result.OwnerID,
+		UnkeyKeyID:     result.KeyID,
+		Plan:           result.Meta.Plan,
+		Scopes:         result.Scopes(),
+		AllowedChains:  result.Meta.AllowedChains,
+		Status:         "active",
+	}, nil
+}
+
+func (c *Client) verify(ctx context.Context, key string) ([]byte, error) {
+	payload := map[string]string{"key": key}
+	if c.apiID != "" {
+		payload["apiId"] = c.apiID
+	}
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.verifyURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create unkey request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.rootKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.rootKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call unkey: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read unkey response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unkey returned status %d", resp.StatusCode)
+	}
+
+	return body, nil
+}
+
+// verifyCacheKey mirrors "unkey:verify:" .. ngx.md5(api_key) in the Kong pre-function
+func verifyCacheKey(key string) string {
+	sum := md5.Sum([]byte(key))
+	return verifyCachePrefix + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,275 @@
+package unkey
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hoodrun/rpc-gateway/reporting-api/internal/config"
+)
+
+const (
+	validKey   = "sk_live_valid"
+	revokedKey = "sk_live_revoked"
+	testKeyID  = "key_123"
+)
+
+// fakeUnkey stands in for keys.verifyKey and counts the calls it receives
+type fakeUnkey struct {
+	*httptest.Server
+	calls  atomic.Int32
+	status atomic.Int32
+}
+
+func newFakeUnkey(t *testing.T) *fakeUnkey {
+	t.Helper()
+	f := &fakeUnkey{}
+	f.status.Store(http.StatusOK)
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.calls.Add(1)
+
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer root_key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Key   string `json:"key"`
+			APIID string `json:"apiId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.APIID != "api_1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if status := int(f.status.Load()); status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		result := VerifyResult{Valid: false, Code: "NOT_FOUND"}
+		if req.Key == validKey {
+			result = VerifyResult{
+				Valid:       true,
+				KeyID:       testKeyID,
+				OwnerID:     "consumer_1",
+				Meta:        KeyMeta{OrganizationID: "org_1", Plan: "pro", Scopes: []string{"usage:read"}},
+				Permissions: []string{"keys:read"},
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func newTestClient(f *fakeUnkey, cache Cache) *Client {
+	return NewClient(&config.UnkeyConfig{
+		Enabled:   true,
+		VerifyURL: f.URL,
+		RootKey:   "root_key",
+		APIID:     "api_1",
+		CacheTTL:  60,
+		Timeout:   5,
+	}, cache)
+}
+
+func TestVerifyKeyCachesValidResults(t *testing.T) {
+	fake := newFakeUnkey(t)
+	client := newTestClient(fake, NewMemoryCache())
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		result, err := client.VerifyKey(ctx, validKey)
+		if err != nil {
+			t.Fatalf("VerifyKey: %v", err)
+		}
+		if !result.Valid || result.KeyID != testKeyID || result.Meta.Plan != "pro" {
+			t.Fatalf("result = %+v", result)
+		}
+		if got := result.Scopes(); len(got) != 2 || got[0] != "keys:read" || got[1] != "usage:read" {
+			t.Errorf("Scopes() = %v", got)
+		}
+	}
+
+	if calls := fake.calls.Load(); calls != 1 {
+		t.Errorf("unkey called %d times, want 1 (miss then hits)", calls)
+	}
+}
+
+func TestVerifyKeyDoesNotCacheInvalidResults(t *testing.T) {
+	fake := newFakeUnkey(t)
+	client := newTestClient(fake, NewMemoryCache())
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		result, err := client.VerifyKey(ctx, revokedKey)
+		if err != nil {
+			t.Fatalf("VerifyKey: %v", err)
+		}
+		if result.Valid {
+			t.Fatalf("revoked key verified as valid")
+		}
+	}
+
+	if calls := fake.calls.Load(); calls != 2 {
+		t.Errorf("unkey called %d times, want 2 (invalid results are not cached)", calls)
+	}
+}
+
+func TestVerifyKeyWithoutCache(t *testing.T) {
+	fake := newFakeUnkey(t)
+	client := newTestClient(fake, nil)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.VerifyKey(ctx, validKey); err != nil {
+			t.Fatalf("VerifyKey: %v", err)
+		}
+	}
+	if calls := fake.calls.Load(); calls != 2 {
+		t.Errorf("unkey called %d times, want 2", calls)
+	}
+	if purged, err := client.PurgeKeyID(ctx, testKeyID); err != nil || purged {
+		t.Errorf("PurgeKeyID without cache = %v, %v", purged, err)
+	}
+}
+
+func TestVerifyKeyUpstreamError(t *testing.T) {
+	fake := newFakeUnkey(t)
+	fake.status.Store(http.StatusServiceUnavailable)
+	cache := NewMemoryCache()
+	client := newTestClient(fake, cache)
+
+	if _, err := client.VerifyKey(context.Background(), validKey); err == nil {
+		t.Fatalf("VerifyKey succeeded against a failing upstream")
+	}
+	if _, ok, _ := cache.Get(context.Background(), verifyCacheKey(validKey)); ok {
+		t.Errorf("failed verification was cached")
+	}
+}
+
+func TestPurgeKeyIDEvictsCachedResult(t *testing.T) {
+	fake := newFakeUnkey(t)
+	cache := NewMemoryCache()
+	client := newTestClient(fake, cache)
+	ctx := context.Background()
+
+	if _, err := client.VerifyKey(ctx, validKey); err != nil {
+		t.Fatalf("VerifyKey: %v", err)
+	}
+	if _, ok, _ := cache.Get(ctx, verifyCacheKey(validKey)); !ok {
+		t.Fatalf("valid result not cached")
+	}
+
+	if purged, err := client.PurgeKeyID(ctx, testKeyID); err != nil || !purged {
+		t.Fatalf("PurgeKeyID = %v, %v", purged, err)
+	}
+	if _, ok, _ := cache.Get(ctx, verifyCacheKey(validKey)); ok {
+		t.Errorf("cached result survived PurgeKeyID")
+	}
+	if _, ok, _ := cache.Get(ctx, keyIDIndexPrefix+testKeyID); ok {
+		t.Errorf("key id index survived PurgeKeyID")
+	}
+
+	// The next request verifies against Unkey again
+	if _, err := client.VerifyKey(ctx, validKey); err != nil {
+		t.Fatalf("VerifyKey: %v", err)
+	}
+	if calls := fake.calls.Load(); calls != 2 {
+		t.Errorf("unkey called %d times, want 2", calls)
+	}
+
+	// Purging an unknown key id is a no-op
+	if purged, err := client.PurgeKeyID(ctx, "key_unknown"); err != nil || purged {
+		t.Errorf("PurgeKeyID of unknown key = %v, %v", purged, err)
+	}
+}
+
+func TestPurgeKeyIDEvictsKongCachedResult(t *testing.T) {
+	fake := newFakeUnkey(t)
+	cache := NewMemoryCache()
+	client := newTestClient(fake, cache)
+	ctx := context.Background()
+
+	// What the Kong pre-function writes on a cache miss
+	cacheKey := verifyCacheKey(validKey)
+	cache.Set(ctx, cacheKey, []byte(`{"valid":true,"keyId":"key_123","meta":{"organizationId":"org_1"}}`), time.Minute)
+	cache.Set(ctx, keyIDIndexPrefix+testKeyID, []byte(cacheKey), time.Minute)
+
+	if purged, err := client.PurgeKeyID(ctx, testKeyID); err != nil || !purged {
+		t.Fatalf("PurgeKeyID = %v, %v", purged, err)
+	}
+	if _, ok, _ := cache.Get(ctx, cacheKey); ok {
+		t.Errorf("Kong's cached result survived PurgeKeyID")
+	}
+
+	// An index whose result is already gone evicts nothing
+	cache.Set(ctx, keyIDIndexPrefix+testKeyID, []byte(cacheKey), time.Minute)
+	if purged, err := client.PurgeKeyID(ctx, testKeyID); err != nil || purged {
+		t.Errorf("PurgeKeyID without a cached result = %v, %v", purged, err)
+	}
+}
+
+func TestPurgeKeyEvictsCachedResult(t *testing.T) {
+	fake := newFakeUnkey(t)
+	client := newTestClient(fake, NewMemoryCache())
+	ctx := context.Background()
+
+	client.VerifyKey(ctx, validKey)
+	if err := client.PurgeKey(ctx, validKey); err != nil {
+		t.Fatalf("PurgeKey: %v", err)
+	}
+	client.VerifyKey(ctx, validKey)
+
+	if calls := fake.calls.Load(); calls != 2 {
+		t.Errorf("unkey called %d times, want 2", calls)
+	}
+}
+
+func TestResolveAPIKey(t *testing.T) {
+	fake := newFakeUnkey(t)
+	client := newTestClient(fake, NewMemoryCache())
+	ctx := context.Background()
+
+	key, err := client.ResolveAPIKey(ctx, validKey)
+	if err != nil {
+		t.Fatalf("ResolveAPIKey: %v", err)
+	}
+	if key == nil || key.OrganizationID != "org_1" || key.UnkeyKeyID != testKeyID || key.ConsumerID != "consumer_1" {
+		t.Fatalf("key = %+v", key)
+	}
+	if key.Plan != "pro" || len(key.Scopes) != 2 || key.Scopes[0] != "keys:read" || key.Scopes[1] != "usage:read" {
+		t.Errorf("plan and scopes not carried: %+v", key)
+	}
+
+	key, err = client.ResolveAPIKey(ctx, revokedKey)
+	if err != nil || key != nil {
+		t.Fatalf("ResolveAPIKey(revoked) = %+v, %v; want nil, nil", key, err)
+	}
+}
+
+func TestVerifyCacheKeyMatchesKong(t *testing.T) {
+	// "unkey:verify:" .. ngx.md5("sk_live_valid") in the Kong pre-function
+	if got, want := verifyCacheKey(validKey), "unkey:verify:da6f4fc475a865a8d529f776858a5217"; got != want {
+		t.Errorf("verifyCacheKey = %q, want %q", got, want)
+	}
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	cache := NewMemoryCache()
+	ctx := context.Background()
+
+	cache.Set(ctx, "a", []byte("1"), time.Millisecond)
+	cache.Set(ctx, "b", []byte("2"), time.Hour)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok, _ := cache.Get(ctx, "a"); ok {
+		t.Errorf("expired entry returned")
+	}
+	if value, ok, _ := cache.Get(ctx, "b"); !ok || string(value) != "2" {
+		t.Errorf("live entry = %q, %v", value, ok)
+	}
+}
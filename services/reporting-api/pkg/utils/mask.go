@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"net"
+	"net/netip"
+)
+
+// MaskIP truncates a client IP to its network so it can be shown without
+// identifying the host: IPv4 to /24, IPv6 to /48. Unparseable input is
+// returned empty.
+func MaskIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		// Tolerate host:port values
+		host, _, splitErr := net.SplitHostPort(ip)
+		if splitErr != nil {
+			return ""
+		}
+		if addr, err = netip.ParseAddr(host); err != nil {
+			return ""
+		}
+	}
+	addr = addr.Unmap()
+
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.String()
+}
@@ -1,39 +1,67 @@
 package utils
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
-// ParseDateRange parses start and end date strings
-func ParseDateRange(startStr, endStr string) (time.Time, time.Time, error) {
-	layout := "2006-01-02"
+// LoadTimezone resolves an IANA time zone name such as Europe/Istanbul.
+// An empty name means UTC.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone: %s", name)
+	}
+	return loc, nil
+}
 
-	var start, end time.Time
-	var err error
+// ParseDateRange parses start and end strings in loc. Each may be a
+// YYYY-MM-DD date, which covers the whole day in loc, or an RFC3339
+// timestamp. Empty values default to the start of the current month and now.
+func ParseDateRange(startStr, endStr string, loc *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(loc)
+	start := StartOfMonth(now)
+	end := now
 
 	if startStr != "" {
-		start, err = time.Parse(layout, startStr)
+		t, _, err := parseDateOrTimestamp(startStr, loc)
 		if err != nil {
-			return time.Time{}, time.Time{}, err
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start: %w", err)
 		}
-	} else {
-		// Default to start of current month
-		now := time.Now().UTC()
-		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		start = t
 	}
 
 	if endStr != "" {
-		end, err = time.Parse(layout, endStr)
+		t, isDate, err := parseDateOrTimestamp(endStr, loc)
 		if err != nil {
-			return time.Time{}, time.Time{}, err
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end: %w", err)
+		}
+		end = t
+		if isDate {
+			// Set to end of day; AddDate keeps DST days correct
+			end = t.AddDate(0, 0, 1).Add(-time.Second)
 		}
-		// Set to end of day
-		end = end.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-	} else {
-		end = time.Now().UTC()
 	}
 
 	return start, end, nil
 }
 
+// parseDateOrTimestamp parses a YYYY-MM-DD date or an RFC3339 timestamp and
+// returns it in loc, reporting whether it was a date
+func parseDateOrTimestamp(s string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%q is neither YYYY-MM-DD nor RFC3339", s)
+	}
+	return t.In(loc), false, nil
+}
+
 // StartOfMonth returns the first moment of the month
 func StartOfMonth(t time.Time) time.Time {
 	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
@@ -63,3 +91,41 @@ func DaysBetween(start, end time.Time) int {
 func FormatDateRange(start, end time.Time) string {
 	return start.Format("2006-01-02") + " to " + end.Format("2006-01-02")
 }
+
+// ComparisonPeriod returns the window a range is compared with. compare is
+// "previous_period" or "previous_year". A range starting on the first of a
+// month is shifted by whole months, so month-to-date compares with the same
+// days of the previous month; other ranges are shifted by their length.
+func ComparisonPeriod(start, end time.Time, compare string) (time.Time, time.Time, error) {
+	switch compare {
+	case "previous_year":
+		return shiftMonths(start, end, -12)
+	case "previous_period":
+		if start.Equal(StartOfMonth(start)) {
+			months := (end.Year()-start.Year())*12 + int(end.Month()-start.Month()) + 1
+			return shiftMonths(start, end, -months)
+		}
+		length := end.Sub(start) + time.Second
+		return start.Add(-length), start.Add(-time.Second), nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("compare must be previous_period or previous_year")
+	}
+}
+
+// shiftMonths moves a range by months. A range ending on the last second of
+// a month keeps ending on the last second of a month, and an end past the
+// shifted month's last day is clamped to it.
+func shiftMonths(start, end time.Time, months int) (time.Time, time.Time, error) {
+	shiftedStart := start.AddDate(0, months, 0)
+	if next := end.Add(time.Second); next.Equal(StartOfMonth(next)) {
+		return shiftedStart, next.AddDate(0, months, 0).Add(-time.Second), nil
+	}
+
+	// AddDate normalizes Feb 30 to Mar 2; clamp to the shifted month instead
+	shiftedEnd := end.AddDate(0, months, 0)
+	monthStart := time.Date(end.Year(), end.Month()+time.Month(months), 1, 0, 0, 0, 0, end.Location())
+	if last := monthStart.AddDate(0, 1, 0).Add(-time.Second); shiftedEnd.After(last) {
+		shiftedEnd = last
+	}
+	return shiftedStart, shiftedEnd, nil
+}